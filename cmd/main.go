@@ -7,9 +7,11 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/pralolik/templgrid/src/container"
 	"github.com/pralolik/templgrid/src/logging"
+	"github.com/pralolik/templgrid/src/redact"
 )
 
 // Version Variables which are related to Version command.
@@ -29,11 +31,12 @@ func main() {
 	var log logging.Logger
 	if cfg.Command != container.VersionCmd {
 		lvl, lvlErr := logging.ParseLevel(cfg.LogLvl)
+		// format is checked by Config.validate
+		format, _ := logging.ParseFormat(cfg.LogFormat)
+		log = redact.NewLog(logging.New(lvl, format, os.Stdout), cfg.Redactor())
 		if lvlErr != nil {
 			log.Error("Error with lvlLog (Info by default): '%v'\n", lvlErr)
 		}
-
-		log = logging.NewStdLog(lvl)
 		log.Debug("Config: %v ", cfg)
 	}
 	switch cfg.Command {
@@ -61,6 +64,10 @@ func runCommand(log logging.Logger, cfg *container.Config) error {
 	}
 	appContainer.Run(ctx)
 
+	closeCtx, closeCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer closeCancel()
+	appContainer.Close(closeCtx)
+
 	return nil
 }
 
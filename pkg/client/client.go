@@ -0,0 +1,296 @@
+// Package client implements typed HTTP client for the templgrid API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pralolik/templgrid/pkg"
+)
+
+const (
+	IdempotencyHeader   = "Idempotency-Key"
+	HMACKeyHeader       = "X-Templgrid-Key"
+	HMACTimestampHeader = "X-Templgrid-Timestamp"
+	HMACNonceHeader     = "X-Templgrid-Nonce"
+	HMACSignatureHeader = "X-Templgrid-Signature"
+
+	defMaxRetries = 3
+	defBackoff    = 200 * time.Millisecond
+	defMaxBackoff = 5 * time.Second
+	defTimeout    = 10 * time.Second
+)
+
+// Error is returned when the API responds with an unsuccessful status.
+type Error struct {
+	StatusCode int
+	Response   pkg.ErrorResponse
+}
+
+func (e *Error) Error() string {
+	if e.Response.Error != "" {
+		return fmt.Sprintf("templgrid: %d response: %s", e.StatusCode, e.Response.Error)
+	}
+	return fmt.Sprintf("templgrid: %d response", e.StatusCode)
+}
+
+// Client sends requests to the templgrid API.
+type Client struct {
+	baseURL    string
+	apiKey     string
+	hmacKey    string
+	hmacSecret []byte
+	httpClient *http.Client
+	maxRetries int
+	backoff    time.Duration
+	maxBackoff time.Duration
+}
+
+// New returns a new instance of Client for the API served on baseURL.
+// Takes variadic options which will be applied to Client.
+func New(baseURL, apiKey string, options ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		apiKey:     apiKey,
+		httpClient: &http.Client{Timeout: defTimeout},
+		maxRetries: defMaxRetries,
+		backoff:    defBackoff,
+		maxBackoff: defMaxBackoff,
+	}
+	for _, option := range options {
+		option(c)
+	}
+
+	return c
+}
+
+type Option func(c *Client)
+
+// WithHTTPClient sets http client used for requests.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) { c.httpClient = httpClient }
+}
+
+// WithRetries sets max retries count and initial backoff between attempts.
+// Backoff is doubled after every attempt up to maxBackoff.
+func WithRetries(maxRetries int, backoff, maxBackoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.backoff = backoff
+		c.maxBackoff = maxBackoff
+	}
+}
+
+// WithHMAC makes client sign requests with HMAC-SHA256 instead of sending the API key.
+// keyName is the name of the key configured on the server.
+func WithHMAC(keyName, secret string) Option {
+	return func(c *Client) {
+		c.hmacKey = keyName
+		c.hmacSecret = []byte(secret)
+	}
+}
+
+type sendOptions struct {
+	idempotencyKey string
+}
+
+type SendOption func(o *sendOptions)
+
+// WithIdempotencyKey sets idempotency key for the request.
+// By default, a random key is generated for every SendEmail call
+// and reused for all its retries.
+func WithIdempotencyKey(key string) SendOption {
+	return func(o *sendOptions) { o.idempotencyKey = key }
+}
+
+// SendEmail pushes email to the templgrid queue.
+func (c *Client) SendEmail(
+	ctx context.Context,
+	email *pkg.TemplgridEmailEntity,
+	options ...SendOption) (*pkg.SuccessfulResponse, error) {
+	opts := sendOptions{}
+	for _, option := range options {
+		option(&opts)
+	}
+	if opts.idempotencyKey == "" {
+		key, err := newRandomHex()
+		if err != nil {
+			return nil, err
+		}
+		opts.idempotencyKey = key
+	}
+
+	body, err := json.Marshal(email)
+	if err != nil {
+		return nil, fmt.Errorf("templgrid: can't marshal email: %w", err)
+	}
+
+	var res pkg.SuccessfulResponse
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set(IdempotencyHeader, opts.idempotencyKey)
+	if err = c.do(ctx, http.MethodPost, "/email", header, body, &res); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// Health returns nil if the service is healthy.
+func (c *Client) Health(ctx context.Context) error {
+	return c.do(ctx, http.MethodGet, "/health", http.Header{}, nil, nil)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, header http.Header, body []byte, out interface{}) error {
+	backoff := c.backoff
+	var err error
+	for attempt := 0; ; attempt++ {
+		var retryAfter time.Duration
+		retryAfter, err = c.attempt(ctx, method, path, header, body, out)
+		if err == nil || !isRetryable(err) || attempt >= c.maxRetries {
+			return err
+		}
+
+		wait := backoff
+		if retryAfter > wait {
+			wait = retryAfter
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+		if backoff > c.maxBackoff {
+			backoff = c.maxBackoff
+		}
+	}
+}
+
+func (c *Client) attempt(
+	ctx context.Context,
+	method, path string,
+	header http.Header,
+	body []byte,
+	out interface{}) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("templgrid: can't create request: %w", err)
+	}
+	req.Header = header.Clone()
+	if err = c.authorize(req, body); err != nil {
+		return 0, err
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, &temporaryError{err: err}
+	}
+	defer res.Body.Close()
+
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return 0, &temporaryError{err: err}
+	}
+
+	if res.StatusCode >= http.StatusBadRequest {
+		apiErr := &Error{StatusCode: res.StatusCode}
+		_ = json.Unmarshal(resBody, &apiErr.Response)
+		return parseRetryAfter(res.Header.Get("Retry-After")), apiErr
+	}
+
+	if out == nil {
+		return 0, nil
+	}
+	if err = json.Unmarshal(resBody, out); err != nil {
+		return 0, fmt.Errorf("templgrid: can't unmarshal response: %w", err)
+	}
+
+	return 0, nil
+}
+
+func (c *Client) authorize(req *http.Request, body []byte) error {
+	if len(c.hmacSecret) == 0 {
+		if c.apiKey != "" {
+			req.Header.Set("Authorization", "Bearer "+c.apiKey)
+		}
+		return nil
+	}
+
+	nonce, err := newRandomHex()
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	bodyHash := sha256.Sum256(body)
+	message := req.Method + "\n" + req.URL.RequestURI() + "\n" + timestamp + "\n" + nonce + "\n" +
+		hex.EncodeToString(bodyHash[:])
+	mac := hmac.New(sha256.New, c.hmacSecret)
+	mac.Write([]byte(message))
+
+	req.Header.Set(HMACKeyHeader, c.hmacKey)
+	req.Header.Set(HMACTimestampHeader, timestamp)
+	req.Header.Set(HMACNonceHeader, nonce)
+	req.Header.Set(HMACSignatureHeader, hex.EncodeToString(mac.Sum(nil)))
+
+	return nil
+}
+
+type temporaryError struct {
+	err error
+}
+
+func (e *temporaryError) Error() string { return "templgrid: " + e.err.Error() }
+func (e *temporaryError) Unwrap() error { return e.err }
+
+func isRetryable(err error) bool {
+	var tmpErr *temporaryError
+	if errors.As(err, &tmpErr) {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests ||
+			apiErr.StatusCode == http.StatusConflict ||
+			apiErr.StatusCode >= http.StatusInternalServerError
+	}
+
+	return false
+}
+
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return time.Until(t)
+	}
+
+	return 0
+}
+
+func newRandomHex() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("templgrid: can't generate random key: %w", err)
+	}
+
+	return hex.EncodeToString(b), nil
+}
@@ -1,25 +1,170 @@
 package pkg
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/url"
+	"time"
 
 	"github.com/sendgrid/sendgrid-go/helpers/mail"
 )
 
 const MaxPersonalizationPerRequest = 1000
 
+// Priority defines the queue lane of the email.
+type Priority string
+
+const (
+	// PriorityHigh is for transactional emails like password resets and 2FA codes.
+	PriorityHigh Priority = "high"
+	// PriorityNormal is used when neither request nor template sets priority.
+	PriorityNormal Priority = "normal"
+	// PriorityBulk is for marketing and other batch sends.
+	PriorityBulk Priority = "bulk"
+)
+
+// Priorities lists all priorities from the highest.
+var Priorities = []Priority{PriorityHigh, PriorityNormal, PriorityBulk}
+
+// Valid reports whether p is known priority, empty priority is valid.
+func (p Priority) Valid() bool {
+	switch p {
+	case "", PriorityHigh, PriorityNormal, PriorityBulk:
+		return true
+	}
+
+	return false
+}
+
 var (
 	ErrIncorrectTemplateName        = fmt.Errorf("incorrect value for %s", "template_name")
 	ErrIncorrectPersonalization     = fmt.Errorf("incorrect value for %s", "send_grid_parameters.personalization")
 	ErrIncorrectPersonalizationFrom = fmt.Errorf("incorrect value for %s", "send_grid_parameters.personalization.*.from")
 	ErrIncorrectPersonalizationTo   = fmt.Errorf("incorrect value for %s", "send_grid_parameters.personalization.*.to")
+	ErrIncorrectDelay               = fmt.Errorf("incorrect value for %s", "delay")
+	ErrIncorrectTimezone            = fmt.Errorf("incorrect value for %s", "recipient_timezone")
+	ErrIncorrectPersonalizationTZ   = fmt.Errorf("incorrect value for %s", "send_grid_parameters.personalization.*.timezone")
+	ErrIncorrectPriority            = fmt.Errorf("incorrect value for %s", "priority")
+	ErrIncorrectSchedule            = fmt.Errorf("%s and %s can't be combined", "send_at", "delay")
+	ErrIncorrectCallbackURL         = fmt.Errorf("incorrect value for %s", "callback_url")
 )
 
 type TemplgridEmailEntity struct {
+	// ID is assigned by templgrid when the email is accepted.
+	ID                 string        `json:"id,omitempty"`
 	TemplateName       string        `json:"template_name"`
 	Locale             string        `json:"locale,omitempty"`
 	EmailParameters    interface{}   `json:"email_parameters"`
 	SendGridParameters mail.SGMailV3 `json:"send_grid_parameters"`
+	// TraceContext holds W3C trace context propagated through the queue,
+	// set by templgrid when the email is accepted.
+	TraceContext map[string]string `json:"trace_context,omitempty"`
+	// SendAt holds the email until the time, past or empty value means send immediately.
+	SendAt *time.Time `json:"send_at,omitempty"`
+	// Delay holds the email for the duration after it is accepted, like "30m".
+	// It is replaced by SendAt when the email is accepted.
+	Delay string `json:"delay,omitempty"`
+	// RecipientTimezone is IANA timezone of the recipients, like "Europe/Berlin",
+	// used to evaluate template quiet hours.
+	RecipientTimezone string `json:"recipient_timezone,omitempty"`
+	// PersonalizationTimezones are timezones of personalizations by index, set from `timezone`
+	// of personalizations in the request, empty values fall back to RecipientTimezone.
+	PersonalizationTimezones []string `json:"personalization_timezones,omitempty"`
+	// Urgent emails are sent during quiet hours.
+	Urgent bool `json:"urgent,omitempty"`
+	// Priority is set from template options if empty.
+	Priority Priority `json:"priority,omitempty"`
+	// CallbackURL receives signed status change events of the email
+	// instead of the webhook of the API key.
+	CallbackURL string `json:"callback_url,omitempty"`
+	// KeyName is the API key which sent the email, set by templgrid when the email is accepted.
+	KeyName string `json:"key_name,omitempty"`
+}
+
+// ValidateCallbackURL checks that rawURL is absolute http or https URL,
+// addresses it resolves to are checked when deliveries are posted.
+func ValidateCallbackURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrIncorrectCallbackURL
+	}
+
+	return nil
+}
+
+// UnmarshalJSON decodes the entity and `timezone` of personalizations, which SendGrid mail object ignores.
+func (t *TemplgridEmailEntity) UnmarshalJSON(data []byte) error {
+	type entity TemplgridEmailEntity
+	if err := json.Unmarshal(data, (*entity)(t)); err != nil {
+		return err
+	}
+	var params struct {
+		SendGridParameters json.RawMessage `json:"send_grid_parameters"`
+	}
+	if err := json.Unmarshal(data, &params); err != nil || len(params.SendGridParameters) == 0 {
+		return err
+	}
+	timezones, err := PersonalizationTimezones(params.SendGridParameters)
+	if err != nil {
+		return err
+	}
+	if timezones != nil {
+		t.PersonalizationTimezones = timezones
+	}
+
+	return nil
+}
+
+// PersonalizationTimezones returns `timezone` of every personalization of SendGrid mail object,
+// nil if none of them has it.
+func PersonalizationTimezones(sendGridParameters []byte) ([]string, error) {
+	var sgMail struct {
+		Personalizations []struct {
+			Timezone string `json:"timezone"`
+		} `json:"personalizations"`
+	}
+	if err := json.Unmarshal(sendGridParameters, &sgMail); err != nil {
+		return nil, err
+	}
+	var timezones []string
+	for i, ps := range sgMail.Personalizations {
+		if ps.Timezone == "" {
+			continue
+		}
+		if timezones == nil {
+			timezones = make([]string, len(sgMail.Personalizations))
+		}
+		timezones[i] = ps.Timezone
+	}
+
+	return timezones, nil
+}
+
+// Timezone returns timezone of the personalization, RecipientTimezone if it has none.
+func (t *TemplgridEmailEntity) Timezone(personalization int) string {
+	if personalization < len(t.PersonalizationTimezones) && t.PersonalizationTimezones[personalization] != "" {
+		return t.PersonalizationTimezones[personalization]
+	}
+
+	return t.RecipientTimezone
+}
+
+// DueAt returns time the email should be sent at, zero time if it is due at now.
+// Delay is expected to be validated before.
+func (t *TemplgridEmailEntity) DueAt(now time.Time) time.Time {
+	due := now
+	if t.SendAt != nil {
+		due = *t.SendAt
+	}
+	if t.Delay != "" {
+		delay, _ := time.ParseDuration(t.Delay)
+		due = now.Add(delay)
+	}
+	if !due.After(now) {
+		return time.Time{}
+	}
+
+	return due.UTC()
 }
 
 func (t *TemplgridEmailEntity) Validate() error {
@@ -27,6 +172,43 @@ func (t *TemplgridEmailEntity) Validate() error {
 		return ErrIncorrectTemplateName
 	}
 
+	if t.Delay != "" {
+		if t.SendAt != nil {
+			return ErrIncorrectSchedule
+		}
+		if delay, err := time.ParseDuration(t.Delay); err != nil || delay < 0 {
+			return ErrIncorrectDelay
+		}
+	}
+
+	if !t.Priority.Valid() {
+		return ErrIncorrectPriority
+	}
+
+	if t.CallbackURL != "" {
+		if err := ValidateCallbackURL(t.CallbackURL); err != nil {
+			return err
+		}
+	}
+
+	if t.RecipientTimezone != "" {
+		if _, err := time.LoadLocation(t.RecipientTimezone); err != nil {
+			return ErrIncorrectTimezone
+		}
+	}
+
+	if len(t.PersonalizationTimezones) > len(t.SendGridParameters.Personalizations) {
+		return ErrIncorrectPersonalizationTZ
+	}
+	for _, timezone := range t.PersonalizationTimezones {
+		if timezone == "" {
+			continue
+		}
+		if _, err := time.LoadLocation(timezone); err != nil {
+			return ErrIncorrectPersonalizationTZ
+		}
+	}
+
 	hasFrom := false
 	if t.SendGridParameters.From != nil && t.SendGridParameters.From.Address != "" {
 		hasFrom = true
@@ -0,0 +1,1068 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: templgrid/v1/templgrid.proto
+
+package templgridv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Email struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	TemplateName string                 `protobuf:"bytes,1,opt,name=template_name,json=templateName,proto3" json:"template_name,omitempty"`
+	Locale       string                 `protobuf:"bytes,2,opt,name=locale,proto3" json:"locale,omitempty"`
+	// Template parameters, the same as email_parameters of the HTTP API.
+	EmailParameters *structpb.Value `protobuf:"bytes,3,opt,name=email_parameters,json=emailParameters,proto3" json:"email_parameters,omitempty"`
+	// SendGrid v3 mail object, the same as send_grid_parameters of the HTTP API,
+	// personalizations may have timezone overriding recipient_timezone.
+	SendGridParameters *structpb.Struct `protobuf:"bytes,4,opt,name=send_grid_parameters,json=sendGridParameters,proto3" json:"send_grid_parameters,omitempty"`
+	// Holds the email until the time, can't be combined with delay.
+	SendAt *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=send_at,json=sendAt,proto3" json:"send_at,omitempty"`
+	// Holds the email for the duration after it is accepted, like "30m".
+	Delay string `protobuf:"bytes,6,opt,name=delay,proto3" json:"delay,omitempty"`
+	// Queue lane: high, normal or bulk, template priority is used if empty.
+	Priority string `protobuf:"bytes,7,opt,name=priority,proto3" json:"priority,omitempty"`
+	// Urgent emails are sent during quiet hours.
+	Urgent bool `protobuf:"varint,8,opt,name=urgent,proto3" json:"urgent,omitempty"`
+	// IANA timezone of the recipients used to evaluate quiet hours, like "Europe/Berlin".
+	RecipientTimezone string `protobuf:"bytes,9,opt,name=recipient_timezone,json=recipientTimezone,proto3" json:"recipient_timezone,omitempty"`
+	// Receives signed status change events instead of the webhook of the API key.
+	CallbackUrl   string `protobuf:"bytes,10,opt,name=callback_url,json=callbackUrl,proto3" json:"callback_url,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Email) Reset() {
+	*x = Email{}
+	mi := &file_templgrid_v1_templgrid_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Email) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Email) ProtoMessage() {}
+
+func (x *Email) ProtoReflect() protoreflect.Message {
+	mi := &file_templgrid_v1_templgrid_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Email.ProtoReflect.Descriptor instead.
+func (*Email) Descriptor() ([]byte, []int) {
+	return file_templgrid_v1_templgrid_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Email) GetTemplateName() string {
+	if x != nil {
+		return x.TemplateName
+	}
+	return ""
+}
+
+func (x *Email) GetLocale() string {
+	if x != nil {
+		return x.Locale
+	}
+	return ""
+}
+
+func (x *Email) GetEmailParameters() *structpb.Value {
+	if x != nil {
+		return x.EmailParameters
+	}
+	return nil
+}
+
+func (x *Email) GetSendGridParameters() *structpb.Struct {
+	if x != nil {
+		return x.SendGridParameters
+	}
+	return nil
+}
+
+func (x *Email) GetSendAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.SendAt
+	}
+	return nil
+}
+
+func (x *Email) GetDelay() string {
+	if x != nil {
+		return x.Delay
+	}
+	return ""
+}
+
+func (x *Email) GetPriority() string {
+	if x != nil {
+		return x.Priority
+	}
+	return ""
+}
+
+func (x *Email) GetUrgent() bool {
+	if x != nil {
+		return x.Urgent
+	}
+	return false
+}
+
+func (x *Email) GetRecipientTimezone() string {
+	if x != nil {
+		return x.RecipientTimezone
+	}
+	return ""
+}
+
+func (x *Email) GetCallbackUrl() string {
+	if x != nil {
+		return x.CallbackUrl
+	}
+	return ""
+}
+
+type FieldError struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Field         string                 `protobuf:"bytes,1,opt,name=field,proto3" json:"field,omitempty"`
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FieldError) Reset() {
+	*x = FieldError{}
+	mi := &file_templgrid_v1_templgrid_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FieldError) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FieldError) ProtoMessage() {}
+
+func (x *FieldError) ProtoReflect() protoreflect.Message {
+	mi := &file_templgrid_v1_templgrid_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FieldError.ProtoReflect.Descriptor instead.
+func (*FieldError) Descriptor() ([]byte, []int) {
+	return file_templgrid_v1_templgrid_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *FieldError) GetField() string {
+	if x != nil {
+		return x.Field
+	}
+	return ""
+}
+
+func (x *FieldError) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type SendEmailRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Email         *Email                 `protobuf:"bytes,1,opt,name=email,proto3" json:"email,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SendEmailRequest) Reset() {
+	*x = SendEmailRequest{}
+	mi := &file_templgrid_v1_templgrid_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SendEmailRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SendEmailRequest) ProtoMessage() {}
+
+func (x *SendEmailRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_templgrid_v1_templgrid_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SendEmailRequest.ProtoReflect.Descriptor instead.
+func (*SendEmailRequest) Descriptor() ([]byte, []int) {
+	return file_templgrid_v1_templgrid_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SendEmailRequest) GetEmail() *Email {
+	if x != nil {
+		return x.Email
+	}
+	return nil
+}
+
+type SendEmailResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SendEmailResponse) Reset() {
+	*x = SendEmailResponse{}
+	mi := &file_templgrid_v1_templgrid_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SendEmailResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SendEmailResponse) ProtoMessage() {}
+
+func (x *SendEmailResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_templgrid_v1_templgrid_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SendEmailResponse.ProtoReflect.Descriptor instead.
+func (*SendEmailResponse) Descriptor() ([]byte, []int) {
+	return file_templgrid_v1_templgrid_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *SendEmailResponse) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type SendBatchRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Emails        []*Email               `protobuf:"bytes,1,rep,name=emails,proto3" json:"emails,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SendBatchRequest) Reset() {
+	*x = SendBatchRequest{}
+	mi := &file_templgrid_v1_templgrid_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SendBatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SendBatchRequest) ProtoMessage() {}
+
+func (x *SendBatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_templgrid_v1_templgrid_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SendBatchRequest.ProtoReflect.Descriptor instead.
+func (*SendBatchRequest) Descriptor() ([]byte, []int) {
+	return file_templgrid_v1_templgrid_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *SendBatchRequest) GetEmails() []*Email {
+	if x != nil {
+		return x.Emails
+	}
+	return nil
+}
+
+type SendBatchResult struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Message id, empty if the email was rejected.
+	Id            string        `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Error         string        `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	Fields        []*FieldError `protobuf:"bytes,3,rep,name=fields,proto3" json:"fields,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SendBatchResult) Reset() {
+	*x = SendBatchResult{}
+	mi := &file_templgrid_v1_templgrid_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SendBatchResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SendBatchResult) ProtoMessage() {}
+
+func (x *SendBatchResult) ProtoReflect() protoreflect.Message {
+	mi := &file_templgrid_v1_templgrid_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SendBatchResult.ProtoReflect.Descriptor instead.
+func (*SendBatchResult) Descriptor() ([]byte, []int) {
+	return file_templgrid_v1_templgrid_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *SendBatchResult) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *SendBatchResult) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *SendBatchResult) GetFields() []*FieldError {
+	if x != nil {
+		return x.Fields
+	}
+	return nil
+}
+
+type SendBatchResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Results in the order of the request emails.
+	Results       []*SendBatchResult `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SendBatchResponse) Reset() {
+	*x = SendBatchResponse{}
+	mi := &file_templgrid_v1_templgrid_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SendBatchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SendBatchResponse) ProtoMessage() {}
+
+func (x *SendBatchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_templgrid_v1_templgrid_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SendBatchResponse.ProtoReflect.Descriptor instead.
+func (*SendBatchResponse) Descriptor() ([]byte, []int) {
+	return file_templgrid_v1_templgrid_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *SendBatchResponse) GetResults() []*SendBatchResult {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+type GetStatusRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetStatusRequest) Reset() {
+	*x = GetStatusRequest{}
+	mi := &file_templgrid_v1_templgrid_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStatusRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStatusRequest) ProtoMessage() {}
+
+func (x *GetStatusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_templgrid_v1_templgrid_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStatusRequest.ProtoReflect.Descriptor instead.
+func (*GetStatusRequest) Descriptor() ([]byte, []int) {
+	return file_templgrid_v1_templgrid_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *GetStatusRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type GetStatusResponse struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	Id           string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	TemplateName string                 `protobuf:"bytes,2,opt,name=template_name,json=templateName,proto3" json:"template_name,omitempty"`
+	State        string                 `protobuf:"bytes,3,opt,name=state,proto3" json:"state,omitempty"`
+	Error        string                 `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	CreatedAt    *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt    *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	// Recipients removed before sending.
+	Dropped []*DroppedRecipient `protobuf:"bytes,7,rep,name=dropped,proto3" json:"dropped,omitempty"`
+	// States of recipients reported by the provider, state is the most advanced of them.
+	Recipients    []*RecipientState `protobuf:"bytes,8,rep,name=recipients,proto3" json:"recipients,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetStatusResponse) Reset() {
+	*x = GetStatusResponse{}
+	mi := &file_templgrid_v1_templgrid_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStatusResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStatusResponse) ProtoMessage() {}
+
+func (x *GetStatusResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_templgrid_v1_templgrid_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStatusResponse.ProtoReflect.Descriptor instead.
+func (*GetStatusResponse) Descriptor() ([]byte, []int) {
+	return file_templgrid_v1_templgrid_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *GetStatusResponse) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *GetStatusResponse) GetTemplateName() string {
+	if x != nil {
+		return x.TemplateName
+	}
+	return ""
+}
+
+func (x *GetStatusResponse) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *GetStatusResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *GetStatusResponse) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *GetStatusResponse) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+func (x *GetStatusResponse) GetDropped() []*DroppedRecipient {
+	if x != nil {
+		return x.Dropped
+	}
+	return nil
+}
+
+func (x *GetStatusResponse) GetRecipients() []*RecipientState {
+	if x != nil {
+		return x.Recipients
+	}
+	return nil
+}
+
+type RecipientState struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Address       string                 `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+	State         string                 `protobuf:"bytes,2,opt,name=state,proto3" json:"state,omitempty"`
+	Reason        string                 `protobuf:"bytes,3,opt,name=reason,proto3" json:"reason,omitempty"`
+	UpdatedAt     *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RecipientState) Reset() {
+	*x = RecipientState{}
+	mi := &file_templgrid_v1_templgrid_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RecipientState) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RecipientState) ProtoMessage() {}
+
+func (x *RecipientState) ProtoReflect() protoreflect.Message {
+	mi := &file_templgrid_v1_templgrid_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RecipientState.ProtoReflect.Descriptor instead.
+func (*RecipientState) Descriptor() ([]byte, []int) {
+	return file_templgrid_v1_templgrid_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *RecipientState) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *RecipientState) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *RecipientState) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *RecipientState) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+type CancelEmailRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CancelEmailRequest) Reset() {
+	*x = CancelEmailRequest{}
+	mi := &file_templgrid_v1_templgrid_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CancelEmailRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CancelEmailRequest) ProtoMessage() {}
+
+func (x *CancelEmailRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_templgrid_v1_templgrid_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CancelEmailRequest.ProtoReflect.Descriptor instead.
+func (*CancelEmailRequest) Descriptor() ([]byte, []int) {
+	return file_templgrid_v1_templgrid_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *CancelEmailRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type CancelEmailResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CancelEmailResponse) Reset() {
+	*x = CancelEmailResponse{}
+	mi := &file_templgrid_v1_templgrid_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CancelEmailResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CancelEmailResponse) ProtoMessage() {}
+
+func (x *CancelEmailResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_templgrid_v1_templgrid_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CancelEmailResponse.ProtoReflect.Descriptor instead.
+func (*CancelEmailResponse) Descriptor() ([]byte, []int) {
+	return file_templgrid_v1_templgrid_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *CancelEmailResponse) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type DroppedRecipient struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Address       string                 `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+	Reason        string                 `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DroppedRecipient) Reset() {
+	*x = DroppedRecipient{}
+	mi := &file_templgrid_v1_templgrid_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DroppedRecipient) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DroppedRecipient) ProtoMessage() {}
+
+func (x *DroppedRecipient) ProtoReflect() protoreflect.Message {
+	mi := &file_templgrid_v1_templgrid_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DroppedRecipient.ProtoReflect.Descriptor instead.
+func (*DroppedRecipient) Descriptor() ([]byte, []int) {
+	return file_templgrid_v1_templgrid_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *DroppedRecipient) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *DroppedRecipient) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+type RenderPreviewRequest struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	TemplateName    string                 `protobuf:"bytes,1,opt,name=template_name,json=templateName,proto3" json:"template_name,omitempty"`
+	Locale          string                 `protobuf:"bytes,2,opt,name=locale,proto3" json:"locale,omitempty"`
+	EmailParameters *structpb.Value        `protobuf:"bytes,3,opt,name=email_parameters,json=emailParameters,proto3" json:"email_parameters,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *RenderPreviewRequest) Reset() {
+	*x = RenderPreviewRequest{}
+	mi := &file_templgrid_v1_templgrid_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RenderPreviewRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RenderPreviewRequest) ProtoMessage() {}
+
+func (x *RenderPreviewRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_templgrid_v1_templgrid_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RenderPreviewRequest.ProtoReflect.Descriptor instead.
+func (*RenderPreviewRequest) Descriptor() ([]byte, []int) {
+	return file_templgrid_v1_templgrid_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *RenderPreviewRequest) GetTemplateName() string {
+	if x != nil {
+		return x.TemplateName
+	}
+	return ""
+}
+
+func (x *RenderPreviewRequest) GetLocale() string {
+	if x != nil {
+		return x.Locale
+	}
+	return ""
+}
+
+func (x *RenderPreviewRequest) GetEmailParameters() *structpb.Value {
+	if x != nil {
+		return x.EmailParameters
+	}
+	return nil
+}
+
+type RenderPreviewResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Subject       string                 `protobuf:"bytes,1,opt,name=subject,proto3" json:"subject,omitempty"`
+	Html          string                 `protobuf:"bytes,2,opt,name=html,proto3" json:"html,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RenderPreviewResponse) Reset() {
+	*x = RenderPreviewResponse{}
+	mi := &file_templgrid_v1_templgrid_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RenderPreviewResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RenderPreviewResponse) ProtoMessage() {}
+
+func (x *RenderPreviewResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_templgrid_v1_templgrid_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RenderPreviewResponse.ProtoReflect.Descriptor instead.
+func (*RenderPreviewResponse) Descriptor() ([]byte, []int) {
+	return file_templgrid_v1_templgrid_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *RenderPreviewResponse) GetSubject() string {
+	if x != nil {
+		return x.Subject
+	}
+	return ""
+}
+
+func (x *RenderPreviewResponse) GetHtml() string {
+	if x != nil {
+		return x.Html
+	}
+	return ""
+}
+
+var File_templgrid_v1_templgrid_proto protoreflect.FileDescriptor
+
+const file_templgrid_v1_templgrid_proto_rawDesc = "" +
+	"\n" +
+	"\x1ctemplgrid/v1/templgrid.proto\x12\ftemplgrid.v1\x1a\x1cgoogle/protobuf/struct.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\xa3\x03\n" +
+	"\x05Email\x12#\n" +
+	"\rtemplate_name\x18\x01 \x01(\tR\ftemplateName\x12\x16\n" +
+	"\x06locale\x18\x02 \x01(\tR\x06locale\x12A\n" +
+	"\x10email_parameters\x18\x03 \x01(\v2\x16.google.protobuf.ValueR\x0femailParameters\x12I\n" +
+	"\x14send_grid_parameters\x18\x04 \x01(\v2\x17.google.protobuf.StructR\x12sendGridParameters\x123\n" +
+	"\asend_at\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\x06sendAt\x12\x14\n" +
+	"\x05delay\x18\x06 \x01(\tR\x05delay\x12\x1a\n" +
+	"\bpriority\x18\a \x01(\tR\bpriority\x12\x16\n" +
+	"\x06urgent\x18\b \x01(\bR\x06urgent\x12-\n" +
+	"\x12recipient_timezone\x18\t \x01(\tR\x11recipientTimezone\x12!\n" +
+	"\fcallback_url\x18\n" +
+	" \x01(\tR\vcallbackUrl\"<\n" +
+	"\n" +
+	"FieldError\x12\x14\n" +
+	"\x05field\x18\x01 \x01(\tR\x05field\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\"=\n" +
+	"\x10SendEmailRequest\x12)\n" +
+	"\x05email\x18\x01 \x01(\v2\x13.templgrid.v1.EmailR\x05email\"#\n" +
+	"\x11SendEmailResponse\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"?\n" +
+	"\x10SendBatchRequest\x12+\n" +
+	"\x06emails\x18\x01 \x03(\v2\x13.templgrid.v1.EmailR\x06emails\"i\n" +
+	"\x0fSendBatchResult\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x14\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error\x120\n" +
+	"\x06fields\x18\x03 \x03(\v2\x18.templgrid.v1.FieldErrorR\x06fields\"L\n" +
+	"\x11SendBatchResponse\x127\n" +
+	"\aresults\x18\x01 \x03(\v2\x1d.templgrid.v1.SendBatchResultR\aresults\"\"\n" +
+	"\x10GetStatusRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\xe2\x02\n" +
+	"\x11GetStatusResponse\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12#\n" +
+	"\rtemplate_name\x18\x02 \x01(\tR\ftemplateName\x12\x14\n" +
+	"\x05state\x18\x03 \x01(\tR\x05state\x12\x14\n" +
+	"\x05error\x18\x04 \x01(\tR\x05error\x129\n" +
+	"\n" +
+	"created_at\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x128\n" +
+	"\adropped\x18\a \x03(\v2\x1e.templgrid.v1.DroppedRecipientR\adropped\x12<\n" +
+	"\n" +
+	"recipients\x18\b \x03(\v2\x1c.templgrid.v1.RecipientStateR\n" +
+	"recipients\"\x93\x01\n" +
+	"\x0eRecipientState\x12\x18\n" +
+	"\aaddress\x18\x01 \x01(\tR\aaddress\x12\x14\n" +
+	"\x05state\x18\x02 \x01(\tR\x05state\x12\x16\n" +
+	"\x06reason\x18\x03 \x01(\tR\x06reason\x129\n" +
+	"\n" +
+	"updated_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\"$\n" +
+	"\x12CancelEmailRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"%\n" +
+	"\x13CancelEmailResponse\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"D\n" +
+	"\x10DroppedRecipient\x12\x18\n" +
+	"\aaddress\x18\x01 \x01(\tR\aaddress\x12\x16\n" +
+	"\x06reason\x18\x02 \x01(\tR\x06reason\"\x96\x01\n" +
+	"\x14RenderPreviewRequest\x12#\n" +
+	"\rtemplate_name\x18\x01 \x01(\tR\ftemplateName\x12\x16\n" +
+	"\x06locale\x18\x02 \x01(\tR\x06locale\x12A\n" +
+	"\x10email_parameters\x18\x03 \x01(\v2\x16.google.protobuf.ValueR\x0femailParameters\"E\n" +
+	"\x15RenderPreviewResponse\x12\x18\n" +
+	"\asubject\x18\x01 \x01(\tR\asubject\x12\x12\n" +
+	"\x04html\x18\x02 \x01(\tR\x04html2\xa3\x03\n" +
+	"\tTemplgrid\x12L\n" +
+	"\tSendEmail\x12\x1e.templgrid.v1.SendEmailRequest\x1a\x1f.templgrid.v1.SendEmailResponse\x12L\n" +
+	"\tSendBatch\x12\x1e.templgrid.v1.SendBatchRequest\x1a\x1f.templgrid.v1.SendBatchResponse\x12L\n" +
+	"\tGetStatus\x12\x1e.templgrid.v1.GetStatusRequest\x1a\x1f.templgrid.v1.GetStatusResponse\x12R\n" +
+	"\vCancelEmail\x12 .templgrid.v1.CancelEmailRequest\x1a!.templgrid.v1.CancelEmailResponse\x12X\n" +
+	"\rRenderPreview\x12\".templgrid.v1.RenderPreviewRequest\x1a#.templgrid.v1.RenderPreviewResponseBBZ@github.com/pralolik/templgrid/pkg/proto/templgrid/v1;templgridv1b\x06proto3"
+
+var (
+	file_templgrid_v1_templgrid_proto_rawDescOnce sync.Once
+	file_templgrid_v1_templgrid_proto_rawDescData []byte
+)
+
+func file_templgrid_v1_templgrid_proto_rawDescGZIP() []byte {
+	file_templgrid_v1_templgrid_proto_rawDescOnce.Do(func() {
+		file_templgrid_v1_templgrid_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_templgrid_v1_templgrid_proto_rawDesc), len(file_templgrid_v1_templgrid_proto_rawDesc)))
+	})
+	return file_templgrid_v1_templgrid_proto_rawDescData
+}
+
+var file_templgrid_v1_templgrid_proto_msgTypes = make([]protoimpl.MessageInfo, 15)
+var file_templgrid_v1_templgrid_proto_goTypes = []any{
+	(*Email)(nil),                 // 0: templgrid.v1.Email
+	(*FieldError)(nil),            // 1: templgrid.v1.FieldError
+	(*SendEmailRequest)(nil),      // 2: templgrid.v1.SendEmailRequest
+	(*SendEmailResponse)(nil),     // 3: templgrid.v1.SendEmailResponse
+	(*SendBatchRequest)(nil),      // 4: templgrid.v1.SendBatchRequest
+	(*SendBatchResult)(nil),       // 5: templgrid.v1.SendBatchResult
+	(*SendBatchResponse)(nil),     // 6: templgrid.v1.SendBatchResponse
+	(*GetStatusRequest)(nil),      // 7: templgrid.v1.GetStatusRequest
+	(*GetStatusResponse)(nil),     // 8: templgrid.v1.GetStatusResponse
+	(*RecipientState)(nil),        // 9: templgrid.v1.RecipientState
+	(*CancelEmailRequest)(nil),    // 10: templgrid.v1.CancelEmailRequest
+	(*CancelEmailResponse)(nil),   // 11: templgrid.v1.CancelEmailResponse
+	(*DroppedRecipient)(nil),      // 12: templgrid.v1.DroppedRecipient
+	(*RenderPreviewRequest)(nil),  // 13: templgrid.v1.RenderPreviewRequest
+	(*RenderPreviewResponse)(nil), // 14: templgrid.v1.RenderPreviewResponse
+	(*structpb.Value)(nil),        // 15: google.protobuf.Value
+	(*structpb.Struct)(nil),       // 16: google.protobuf.Struct
+	(*timestamppb.Timestamp)(nil), // 17: google.protobuf.Timestamp
+}
+var file_templgrid_v1_templgrid_proto_depIdxs = []int32{
+	15, // 0: templgrid.v1.Email.email_parameters:type_name -> google.protobuf.Value
+	16, // 1: templgrid.v1.Email.send_grid_parameters:type_name -> google.protobuf.Struct
+	17, // 2: templgrid.v1.Email.send_at:type_name -> google.protobuf.Timestamp
+	0,  // 3: templgrid.v1.SendEmailRequest.email:type_name -> templgrid.v1.Email
+	0,  // 4: templgrid.v1.SendBatchRequest.emails:type_name -> templgrid.v1.Email
+	1,  // 5: templgrid.v1.SendBatchResult.fields:type_name -> templgrid.v1.FieldError
+	5,  // 6: templgrid.v1.SendBatchResponse.results:type_name -> templgrid.v1.SendBatchResult
+	17, // 7: templgrid.v1.GetStatusResponse.created_at:type_name -> google.protobuf.Timestamp
+	17, // 8: templgrid.v1.GetStatusResponse.updated_at:type_name -> google.protobuf.Timestamp
+	12, // 9: templgrid.v1.GetStatusResponse.dropped:type_name -> templgrid.v1.DroppedRecipient
+	9,  // 10: templgrid.v1.GetStatusResponse.recipients:type_name -> templgrid.v1.RecipientState
+	17, // 11: templgrid.v1.RecipientState.updated_at:type_name -> google.protobuf.Timestamp
+	15, // 12: templgrid.v1.RenderPreviewRequest.email_parameters:type_name -> google.protobuf.Value
+	2,  // 13: templgrid.v1.Templgrid.SendEmail:input_type -> templgrid.v1.SendEmailRequest
+	4,  // 14: templgrid.v1.Templgrid.SendBatch:input_type -> templgrid.v1.SendBatchRequest
+	7,  // 15: templgrid.v1.Templgrid.GetStatus:input_type -> templgrid.v1.GetStatusRequest
+	10, // 16: templgrid.v1.Templgrid.CancelEmail:input_type -> templgrid.v1.CancelEmailRequest
+	13, // 17: templgrid.v1.Templgrid.RenderPreview:input_type -> templgrid.v1.RenderPreviewRequest
+	3,  // 18: templgrid.v1.Templgrid.SendEmail:output_type -> templgrid.v1.SendEmailResponse
+	6,  // 19: templgrid.v1.Templgrid.SendBatch:output_type -> templgrid.v1.SendBatchResponse
+	8,  // 20: templgrid.v1.Templgrid.GetStatus:output_type -> templgrid.v1.GetStatusResponse
+	11, // 21: templgrid.v1.Templgrid.CancelEmail:output_type -> templgrid.v1.CancelEmailResponse
+	14, // 22: templgrid.v1.Templgrid.RenderPreview:output_type -> templgrid.v1.RenderPreviewResponse
+	18, // [18:23] is the sub-list for method output_type
+	13, // [13:18] is the sub-list for method input_type
+	13, // [13:13] is the sub-list for extension type_name
+	13, // [13:13] is the sub-list for extension extendee
+	0,  // [0:13] is the sub-list for field type_name
+}
+
+func init() { file_templgrid_v1_templgrid_proto_init() }
+func file_templgrid_v1_templgrid_proto_init() {
+	if File_templgrid_v1_templgrid_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_templgrid_v1_templgrid_proto_rawDesc), len(file_templgrid_v1_templgrid_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   15,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_templgrid_v1_templgrid_proto_goTypes,
+		DependencyIndexes: file_templgrid_v1_templgrid_proto_depIdxs,
+		MessageInfos:      file_templgrid_v1_templgrid_proto_msgTypes,
+	}.Build()
+	File_templgrid_v1_templgrid_proto = out.File
+	file_templgrid_v1_templgrid_proto_goTypes = nil
+	file_templgrid_v1_templgrid_proto_depIdxs = nil
+}
@@ -0,0 +1,289 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: templgrid/v1/templgrid.proto
+
+package templgridv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Templgrid_SendEmail_FullMethodName     = "/templgrid.v1.Templgrid/SendEmail"
+	Templgrid_SendBatch_FullMethodName     = "/templgrid.v1.Templgrid/SendBatch"
+	Templgrid_GetStatus_FullMethodName     = "/templgrid.v1.Templgrid/GetStatus"
+	Templgrid_CancelEmail_FullMethodName   = "/templgrid.v1.Templgrid/CancelEmail"
+	Templgrid_RenderPreview_FullMethodName = "/templgrid.v1.Templgrid/RenderPreview"
+)
+
+// TemplgridClient is the client API for Templgrid service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Templgrid builds emails from templates and pushes them to the send queue.
+type TemplgridClient interface {
+	// SendEmail validates email and pushes it to the queue.
+	SendEmail(ctx context.Context, in *SendEmailRequest, opts ...grpc.CallOption) (*SendEmailResponse, error)
+	// SendBatch validates and queues every email independently.
+	// Every email takes a rate limit token, the batch is rejected if the API key has less tokens.
+	SendBatch(ctx context.Context, in *SendBatchRequest, opts ...grpc.CallOption) (*SendBatchResponse, error)
+	// GetStatus returns the delivery status of a queued email.
+	GetStatus(ctx context.Context, in *GetStatusRequest, opts ...grpc.CallOption) (*GetStatusResponse, error)
+	// CancelEmail cancels a scheduled email sent by the key.
+	CancelEmail(ctx context.Context, in *CancelEmailRequest, opts ...grpc.CallOption) (*CancelEmailResponse, error)
+	// RenderPreview builds subject and HTML without sending.
+	RenderPreview(ctx context.Context, in *RenderPreviewRequest, opts ...grpc.CallOption) (*RenderPreviewResponse, error)
+}
+
+type templgridClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewTemplgridClient(cc grpc.ClientConnInterface) TemplgridClient {
+	return &templgridClient{cc}
+}
+
+func (c *templgridClient) SendEmail(ctx context.Context, in *SendEmailRequest, opts ...grpc.CallOption) (*SendEmailResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SendEmailResponse)
+	err := c.cc.Invoke(ctx, Templgrid_SendEmail_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *templgridClient) SendBatch(ctx context.Context, in *SendBatchRequest, opts ...grpc.CallOption) (*SendBatchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SendBatchResponse)
+	err := c.cc.Invoke(ctx, Templgrid_SendBatch_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *templgridClient) GetStatus(ctx context.Context, in *GetStatusRequest, opts ...grpc.CallOption) (*GetStatusResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetStatusResponse)
+	err := c.cc.Invoke(ctx, Templgrid_GetStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *templgridClient) CancelEmail(ctx context.Context, in *CancelEmailRequest, opts ...grpc.CallOption) (*CancelEmailResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CancelEmailResponse)
+	err := c.cc.Invoke(ctx, Templgrid_CancelEmail_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *templgridClient) RenderPreview(ctx context.Context, in *RenderPreviewRequest, opts ...grpc.CallOption) (*RenderPreviewResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RenderPreviewResponse)
+	err := c.cc.Invoke(ctx, Templgrid_RenderPreview_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// TemplgridServer is the server API for Templgrid service.
+// All implementations must embed UnimplementedTemplgridServer
+// for forward compatibility.
+//
+// Templgrid builds emails from templates and pushes them to the send queue.
+type TemplgridServer interface {
+	// SendEmail validates email and pushes it to the queue.
+	SendEmail(context.Context, *SendEmailRequest) (*SendEmailResponse, error)
+	// SendBatch validates and queues every email independently.
+	// Every email takes a rate limit token, the batch is rejected if the API key has less tokens.
+	SendBatch(context.Context, *SendBatchRequest) (*SendBatchResponse, error)
+	// GetStatus returns the delivery status of a queued email.
+	GetStatus(context.Context, *GetStatusRequest) (*GetStatusResponse, error)
+	// CancelEmail cancels a scheduled email sent by the key.
+	CancelEmail(context.Context, *CancelEmailRequest) (*CancelEmailResponse, error)
+	// RenderPreview builds subject and HTML without sending.
+	RenderPreview(context.Context, *RenderPreviewRequest) (*RenderPreviewResponse, error)
+	mustEmbedUnimplementedTemplgridServer()
+}
+
+// UnimplementedTemplgridServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedTemplgridServer struct{}
+
+func (UnimplementedTemplgridServer) SendEmail(context.Context, *SendEmailRequest) (*SendEmailResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method SendEmail not implemented")
+}
+func (UnimplementedTemplgridServer) SendBatch(context.Context, *SendBatchRequest) (*SendBatchResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method SendBatch not implemented")
+}
+func (UnimplementedTemplgridServer) GetStatus(context.Context, *GetStatusRequest) (*GetStatusResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetStatus not implemented")
+}
+func (UnimplementedTemplgridServer) CancelEmail(context.Context, *CancelEmailRequest) (*CancelEmailResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method CancelEmail not implemented")
+}
+func (UnimplementedTemplgridServer) RenderPreview(context.Context, *RenderPreviewRequest) (*RenderPreviewResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method RenderPreview not implemented")
+}
+func (UnimplementedTemplgridServer) mustEmbedUnimplementedTemplgridServer() {}
+func (UnimplementedTemplgridServer) testEmbeddedByValue()                   {}
+
+// UnsafeTemplgridServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to TemplgridServer will
+// result in compilation errors.
+type UnsafeTemplgridServer interface {
+	mustEmbedUnimplementedTemplgridServer()
+}
+
+func RegisterTemplgridServer(s grpc.ServiceRegistrar, srv TemplgridServer) {
+	// If the following call panics, it indicates UnimplementedTemplgridServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Templgrid_ServiceDesc, srv)
+}
+
+func _Templgrid_SendEmail_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SendEmailRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TemplgridServer).SendEmail(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Templgrid_SendEmail_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TemplgridServer).SendEmail(ctx, req.(*SendEmailRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Templgrid_SendBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SendBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TemplgridServer).SendBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Templgrid_SendBatch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TemplgridServer).SendBatch(ctx, req.(*SendBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Templgrid_GetStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TemplgridServer).GetStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Templgrid_GetStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TemplgridServer).GetStatus(ctx, req.(*GetStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Templgrid_CancelEmail_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelEmailRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TemplgridServer).CancelEmail(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Templgrid_CancelEmail_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TemplgridServer).CancelEmail(ctx, req.(*CancelEmailRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Templgrid_RenderPreview_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RenderPreviewRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TemplgridServer).RenderPreview(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Templgrid_RenderPreview_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TemplgridServer).RenderPreview(ctx, req.(*RenderPreviewRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Templgrid_ServiceDesc is the grpc.ServiceDesc for Templgrid service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Templgrid_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "templgrid.v1.Templgrid",
+	HandlerType: (*TemplgridServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SendEmail",
+			Handler:    _Templgrid_SendEmail_Handler,
+		},
+		{
+			MethodName: "SendBatch",
+			Handler:    _Templgrid_SendBatch_Handler,
+		},
+		{
+			MethodName: "GetStatus",
+			Handler:    _Templgrid_GetStatus_Handler,
+		},
+		{
+			MethodName: "CancelEmail",
+			Handler:    _Templgrid_CancelEmail_Handler,
+		},
+		{
+			MethodName: "RenderPreview",
+			Handler:    _Templgrid_RenderPreview_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "templgrid/v1/templgrid.proto",
+}
@@ -1,11 +1,18 @@
 package pkg
 
 type ErrorResponse struct {
-	Ok    bool   `json:"ok"`
-	Error string `json:"error"`
+	Ok     bool         `json:"ok"`
+	Error  string       `json:"error"`
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
 }
 
 type SuccessfulResponse struct {
 	Ok      bool   `json:"ok"`
 	Message string `json:"message"`
+	ID      string `json:"id,omitempty"`
 }
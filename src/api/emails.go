@@ -2,14 +2,25 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
 
 	"github.com/pralolik/templgrid/pkg"
+	"github.com/pralolik/templgrid/src/api/middleware"
+	"github.com/pralolik/templgrid/src/auth"
+	"github.com/pralolik/templgrid/src/ingest"
+	"github.com/pralolik/templgrid/src/logging"
+	"github.com/pralolik/templgrid/src/scheduler"
 )
 
 func (s *Server) newEmail(rw http.ResponseWriter, r *http.Request) {
 	var req pkg.TemplgridEmailEntity
+	log := logging.FromContext(r.Context(), s.log)
 	if r.Header.Get("Content-Type") != "application/json" {
 		http.Error(rw, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
 		return
@@ -17,32 +28,100 @@ func (s *Server) newEmail(rw http.ResponseWriter, r *http.Request) {
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		reqBody, readErr := io.ReadAll(r.Body)
 		if readErr != nil {
-			s.log.Error("Failed to set entry: %v Unable to read request body: %v ", err, readErr)
+			log.Error("Failed to set entry: %v Unable to read request body: %v ", err, readErr)
 		}
 
-		s.log.Error("Failed to set entry: failed to decode request body '%s': %v ", string(reqBody), err)
+		log.Error("Failed to set entry: failed to decode request body '%s': %v ", string(reqBody), err)
 		s.sendErrorValidationResponse(rw, err)
 		return
 	}
 
-	if err := req.Validate(); err != nil {
-		s.log.Error("Invalid request '%v': %v ", req, err.Error())
-		s.sendErrorValidationResponse(rw, err)
+	principal, _ := auth.PrincipalFromContext(r.Context())
+	if err := s.ingest.Authorize(principal, &req); err != nil {
+		s.sendErrorResponse(rw, http.StatusForbidden, err)
 		return
 	}
 
-	if err := s.HasTemplateName(req.TemplateName); err != nil {
-		s.log.Error("Invalid request '%v': %v ", req, err.Error())
+	if err := s.ingest.Validate(&req); err != nil {
+		log.Error("Invalid request for %s parameters '%v': %v ", req.TemplateName, s.redactor.Params(req.EmailParameters), err)
 		s.sendErrorValidationResponse(rw, err)
 		return
 	}
 
-	err := s.queue.Push(&req)
+	idempotencyKey := r.Header.Get(IdempotencyHeader)
+	if len(idempotencyKey) > maxIdempotencyKeyLen {
+		s.sendErrorValidationResponse(rw, errors.New("idempotency key is too long"))
+		return
+	}
+	var keyName, hash string
+	if principal != nil {
+		keyName = principal.Name
+	}
+	if idempotencyKey != "" {
+		hash = requestHash(&req)
+		state, id := s.idempotency.reserve(keyName, idempotencyKey, hash)
+		switch state {
+		case idempotencyDone:
+			s.sendSuccessfulResponse(rw, "Message already queued", id)
+			return
+		case idempotencyInProgress:
+			s.sendErrorResponse(rw, http.StatusConflict, errors.New("request with the same idempotency key is in progress"))
+			return
+		case idempotencyMismatch:
+			s.sendErrorResponse(rw, http.StatusUnprocessableEntity,
+				errors.New("idempotency key is already used with another request body"))
+			return
+		case idempotencyNew:
+		}
+	}
+
+	id, err := s.ingest.Push(r.Context(), &req)
 	rw.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		if idempotencyKey != "" {
+			s.idempotency.release(keyName, idempotencyKey)
+		}
+		if ingest.IsUnavailable(err) {
+			rw.Header().Set("Retry-After", strconv.Itoa(middleware.RetryAfterSeconds(ingest.RetryAfter)))
+			s.sendErrorResponse(rw, http.StatusServiceUnavailable, err)
+			return
+		}
+		s.sendInternalErrorResponse(rw, err)
+		return
+	}
+	if idempotencyKey != "" {
+		s.idempotency.complete(keyName, idempotencyKey, hash, id)
+	}
+	if req.SendAt != nil {
+		s.sendSuccessfulResponse(rw, "Message successfully scheduled", id)
+		logging.With(log, logging.MessageIDKey, id).Info("New email type '%s' scheduled at %s with id %s", req.TemplateName, req.SendAt, id)
+		return
+	}
+	s.sendSuccessfulResponse(rw, "Message successfully queued", id)
+	logging.With(log, logging.MessageIDKey, id).Info("New email type '%s' pushed to queue with id %s", req.TemplateName, id)
+}
+
+func (s *Server) cancelEmail(rw http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	principal, _ := auth.PrincipalFromContext(r.Context())
+	err := s.ingest.Cancel(principal, id)
+	if errors.Is(err, scheduler.ErrNotFound) {
+		// state is told only to the key which sent the message.
+		if st, stErr := s.statuses.Get(id); stErr == nil && principal != nil && principal.Owns(st.KeyName) {
+			s.sendErrorResponse(rw, http.StatusConflict, fmt.Errorf("message is not scheduled, state %s", st.State))
+			return
+		}
+		s.sendErrorResponse(rw, http.StatusNotFound, err)
+		return
+	}
+	if errors.Is(err, auth.ErrForbidden) || errors.Is(err, auth.ErrUnauthorized) {
+		s.sendErrorResponse(rw, http.StatusForbidden, err)
+		return
+	}
 	if err != nil {
 		s.sendInternalErrorResponse(rw, err)
 		return
 	}
-	s.sendSuccessfulResponse(rw)
-	s.log.Info("New email type '%s' pushed to queue", req.TemplateName)
+	s.sendSuccessfulResponse(rw, "Message successfully cancelled", id)
+	logging.With(logging.FromContext(r.Context(), s.log), logging.MessageIDKey, id).Info("Scheduled email %s cancelled", id)
 }
@@ -1,14 +1,46 @@
 package api
 
-import "net/http"
+import (
+	"encoding/json"
+	"net/http"
 
-func (s *Server) health(rw http.ResponseWriter, r *http.Request) {
-	if err := s.hc.Health(r.Context()); err != nil {
-		s.log.Error("Health check failed: %v ", err)
-		http.Error(rw, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
-		return
-	}
+	"github.com/pralolik/templgrid/src/api/lib/health"
+)
 
+// health is the legacy liveness check kept for existing probes, components are checked by ready only,
+// so provider outages and graceful drains don't restart the process.
+func (s *Server) health(rw http.ResponseWriter, _ *http.Request) {
 	rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	rw.WriteHeader(http.StatusOK)
 }
+
+// live reports that the process is able to serve requests, dependencies are not checked.
+func (s *Server) live(rw http.ResponseWriter, _ *http.Request) {
+	s.sendHealthReport(rw, health.Report{Status: health.StatusUp, Components: map[string]health.Result{}})
+}
+
+// ready reports health of every registered component.
+func (s *Server) ready(rw http.ResponseWriter, r *http.Request) {
+	report := s.hc.Report(r.Context())
+	if report.Status != health.StatusUp {
+		s.log.Info("Readiness check failed: %v ", report.Components)
+	}
+	s.sendHealthReport(rw, report)
+}
+
+func (s *Server) sendHealthReport(rw http.ResponseWriter, report health.Report) {
+	outgoingJSON, err := json.Marshal(report)
+	if err != nil {
+		s.sendInternalErrorResponse(rw, err)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	if report.Status == health.StatusUp {
+		rw.WriteHeader(http.StatusOK)
+	} else {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if _, err = rw.Write(outgoingJSON); err != nil {
+		s.log.Error("Error with sending health report: %v ", err)
+	}
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"github.com/pralolik/templgrid/src/history"
+)
+
+type historyList struct {
+	Ok         bool             `json:"ok"`
+	Items      []history.Record `json:"items"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+type historyItem struct {
+	Ok   bool            `json:"ok"`
+	Item *history.Record `json:"item"`
+}
+
+func (s *Server) listEmails(rw http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	limit := history.DefListLimit
+	if limitParam := params.Get("limit"); limitParam != "" {
+		var err error
+		if limit, err = strconv.Atoi(limitParam); err != nil || limit <= 0 || limit > history.MaxListLimit {
+			s.sendErrorValidationResponse(rw, fmt.Errorf("limit should be from 1 to %d", history.MaxListLimit))
+			return
+		}
+	}
+	query := history.Query{Recipient: params.Get("recipient"), Template: params.Get("template")}
+	for name, bound := range map[string]*time.Time{"from": &query.From, "to": &query.To} {
+		if value := params.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				s.sendErrorValidationResponse(rw, fmt.Errorf("%s should be RFC 3339 time", name))
+				return
+			}
+			*bound = t
+		}
+	}
+	records, next, err := s.history.Search(query, params.Get("cursor"), limit)
+	if errors.Is(err, history.ErrInvalid) {
+		s.sendErrorValidationResponse(rw, err)
+		return
+	}
+	if err != nil {
+		s.sendInternalErrorResponse(rw, err)
+		return
+	}
+	outgoingJSON, err := json.Marshal(historyList{Ok: true, Items: records, NextCursor: next})
+	if err != nil {
+		s.sendInternalErrorResponse(rw, err)
+		return
+	}
+	if _, err = rw.Write(outgoingJSON); err != nil {
+		s.log.Error("Error with sending history: %v ", err)
+	}
+}
+
+func (s *Server) getEmail(rw http.ResponseWriter, r *http.Request) {
+	record, err := s.history.Get(chi.URLParam(r, "id"))
+	if errors.Is(err, history.ErrNotFound) {
+		s.sendErrorResponse(rw, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		s.sendInternalErrorResponse(rw, err)
+		return
+	}
+	outgoingJSON, err := json.Marshal(historyItem{Ok: true, Item: record})
+	if err != nil {
+		s.sendInternalErrorResponse(rw, err)
+		return
+	}
+	if _, err = rw.Write(outgoingJSON); err != nil {
+		s.log.Error("Error with sending history record: %v ", err)
+	}
+}
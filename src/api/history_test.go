@@ -0,0 +1,81 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pralolik/templgrid/src/auth"
+	"github.com/pralolik/templgrid/src/history"
+	"github.com/pralolik/templgrid/src/logging"
+)
+
+func TestEmailsHistory(t *testing.T) {
+	log := &logging.DisabledLog{}
+	hist := history.New(log, history.NewMemoryStore(), history.WithBodyRetention(time.Hour))
+	sentAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	hist.Record(history.Record{ID: "m1", TemplateName: "welcome", Recipients: []string{"A@Example.com"}, SentAt: sentAt, Body: "<p>hi</p>"})
+	hist.Record(history.Record{ID: "m2", TemplateName: "reset", Recipients: []string{"b@example.com"}, SentAt: sentAt.Add(time.Minute)})
+	keys := auth.NewKeySet(
+		auth.Key{Name: "shop", Secret: "send-secret", Scopes: []auth.Scope{auth.ScopeSend}},
+		auth.Key{Name: "ops", Secret: "admin-secret", Scopes: []auth.Scope{auth.ScopeAdmin}},
+	)
+	s := NewServer(log, WithAPI(true, DefPort), WithAuth(keys), WithHistory(hist))
+
+	serve := func(target, key string) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		if key != "" {
+			r.Header.Set("X-Api-Key", key)
+		}
+		s.httpRouter.ServeHTTP(rw, r)
+		return rw
+	}
+
+	for _, target := range []string{"/emails", "/emails/m1"} {
+		if rw := serve(target, ""); rw.Code != http.StatusForbidden {
+			t.Errorf("%s without key = %d, want 403", target, rw.Code)
+		}
+		if rw := serve(target, "send-secret"); rw.Code != http.StatusForbidden {
+			t.Errorf("%s with send key = %d, want 403", target, rw.Code)
+		}
+	}
+
+	rw := serve("/emails?recipient=a@example.com", "admin-secret")
+	if rw.Code != http.StatusOK {
+		t.Fatalf("list = %d %s", rw.Code, rw.Body)
+	}
+	var list historyList
+	if err := json.Unmarshal(rw.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 1 || list.Items[0].ID != "m1" || list.Items[0].Body != "" {
+		t.Errorf("list = %+v, want m1 without body", list.Items)
+	}
+
+	rw = serve("/emails/m1", "admin-secret")
+	if rw.Code != http.StatusOK {
+		t.Fatalf("get = %d %s", rw.Code, rw.Body)
+	}
+	var item historyItem
+	if err := json.Unmarshal(rw.Body.Bytes(), &item); err != nil {
+		t.Fatal(err)
+	}
+	if item.Item == nil || item.Item.Body != "<p>hi</p>" {
+		t.Errorf("get = %+v, want m1 with body", item.Item)
+	}
+
+	for target, code := range map[string]int{
+		"/emails/unknown":        http.StatusNotFound,
+		"/emails?limit=0":        http.StatusBadRequest,
+		"/emails?from=yesterday": http.StatusBadRequest,
+		"/emails?cursor=!":       http.StatusBadRequest,
+		"/emails?from=2024-05-02T00:00:00Z&to=2024-05-01T00:00:00Z": http.StatusBadRequest,
+	} {
+		if rw := serve(target, "admin-secret"); rw.Code != code {
+			t.Errorf("%s = %d, want %d", target, rw.Code, code)
+		}
+	}
+}
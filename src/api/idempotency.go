@@ -0,0 +1,111 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/pralolik/templgrid/pkg"
+)
+
+const (
+	IdempotencyHeader    = "Idempotency-Key"
+	maxIdempotencyKeyLen = 255
+	defIdempotencyTTL    = 24 * time.Hour
+	idempotencyCleanup   = time.Minute
+)
+
+type idempotencyState int
+
+const (
+	idempotencyNew idempotencyState = iota
+	idempotencyInProgress
+	idempotencyDone
+	// idempotencyMismatch means the key is used with another request body.
+	idempotencyMismatch
+)
+
+type idempotencyEntry struct {
+	done    bool
+	id      string
+	hash    string
+	expires time.Time
+}
+
+// idempotencyStore remembers idempotency keys of accepted requests for ttl.
+// Keys are scoped by API key name, so clients can't see message ids of each other.
+type idempotencyStore struct {
+	mu          sync.Mutex
+	ttl         time.Duration
+	entries     map[string]idempotencyEntry
+	lastCleanup time.Time
+}
+
+func newIdempotencyStore(ttl time.Duration) *idempotencyStore {
+	return &idempotencyStore{
+		ttl:     ttl,
+		entries: map[string]idempotencyEntry{},
+	}
+}
+
+// reserve marks key of the API key as in progress if it wasn't seen before, hash is the request body hash.
+// Returns previous state of the key and message id if it's done.
+func (s *idempotencyStore) reserve(keyName, key, hash string) (idempotencyState, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.cleanup(now)
+	scoped := idempotencyScope(keyName, key)
+	if entry, ok := s.entries[scoped]; ok && now.Before(entry.expires) {
+		switch {
+		case entry.hash != hash:
+			return idempotencyMismatch, ""
+		case entry.done:
+			return idempotencyDone, entry.id
+		}
+		return idempotencyInProgress, ""
+	}
+	s.entries[scoped] = idempotencyEntry{hash: hash, expires: now.Add(s.ttl)}
+
+	return idempotencyNew, ""
+}
+
+func (s *idempotencyStore) complete(keyName, key, hash, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[idempotencyScope(keyName, key)] = idempotencyEntry{done: true, id: id, hash: hash, expires: time.Now().Add(s.ttl)}
+}
+
+func (s *idempotencyStore) release(keyName, key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, idempotencyScope(keyName, key))
+}
+
+// idempotencyScope joins names by NUL, it can't be a part of the API key name.
+func idempotencyScope(keyName, key string) string {
+	return keyName + "\x00" + key
+}
+
+// requestHash returns hash of the decoded request, so formatting and order of fields don't matter.
+func requestHash(req *pkg.TemplgridEmailEntity) string {
+	body, _ := json.Marshal(req)
+	sum := sha256.Sum256(body)
+
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *idempotencyStore) cleanup(now time.Time) {
+	if now.Sub(s.lastCleanup) < idempotencyCleanup {
+		return
+	}
+	s.lastCleanup = now
+	for key, entry := range s.entries {
+		if now.After(entry.expires) {
+			delete(s.entries, key)
+		}
+	}
+}
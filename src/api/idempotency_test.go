@@ -0,0 +1,36 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pralolik/templgrid/pkg"
+)
+
+func TestIdempotencyStore(t *testing.T) {
+	s := newIdempotencyStore(time.Hour)
+	hash := requestHash(&pkg.TemplgridEmailEntity{TemplateName: "welcome"})
+	other := requestHash(&pkg.TemplgridEmailEntity{TemplateName: "reset"})
+
+	if state, _ := s.reserve("shop", "k1", hash); state != idempotencyNew {
+		t.Fatalf("first reserve state = %v, want new", state)
+	}
+	if state, _ := s.reserve("shop", "k1", hash); state != idempotencyInProgress {
+		t.Errorf("repeated reserve state = %v, want in progress", state)
+	}
+	s.complete("shop", "k1", hash, "m1")
+	if state, id := s.reserve("shop", "k1", hash); state != idempotencyDone || id != "m1" {
+		t.Errorf("reserve of completed key = %v %q, want done m1", state, id)
+	}
+	if state, id := s.reserve("shop", "k1", other); state != idempotencyMismatch || id != "" {
+		t.Errorf("reserve with another body = %v %q, want mismatch without id", state, id)
+	}
+	// the same key of another API key is independent.
+	if state, _ := s.reserve("blog", "k1", other); state != idempotencyNew {
+		t.Errorf("reserve by another API key state = %v, want new", state)
+	}
+	s.release("blog", "k1")
+	if state, _ := s.reserve("blog", "k1", hash); state != idempotencyNew {
+		t.Errorf("reserve of released key state = %v, want new", state)
+	}
+}
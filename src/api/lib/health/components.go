@@ -0,0 +1,131 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// CheckerFunc adapts function to Checker interface.
+type CheckerFunc func(ctx context.Context) error
+
+func (f CheckerFunc) Health(ctx context.Context) error { return f(ctx) }
+
+// Result holds health check result of a component.
+type Result struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Report holds health check results of all components.
+type Report struct {
+	Status     string            `json:"status"`
+	Components map[string]Result `json:"components"`
+}
+
+// NewComponentChecker returns a pointer to a new instance of ComponentChecker struct.
+func NewComponentChecker() *ComponentChecker {
+	return &ComponentChecker{hcs: map[string]Checker{}}
+}
+
+// ComponentChecker performs health checks of named components concurrently
+// and reports result of each of them.
+type ComponentChecker struct {
+	mu  sync.RWMutex
+	hcs map[string]Checker
+}
+
+// Add registers health Checker of the component, replacing previous one with the same name.
+func (c *ComponentChecker) Add(name string, hc Checker) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hcs[name] = hc
+}
+
+// Report runs all checks and returns results per component.
+func (c *ComponentChecker) Report(ctx context.Context) Report {
+	c.mu.RLock()
+	hcs := make(map[string]Checker, len(c.hcs))
+	for name, hc := range c.hcs {
+		hcs[name] = hc
+	}
+	c.mu.RUnlock()
+
+	report := Report{Status: StatusUp, Components: make(map[string]Result, len(hcs))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, hc := range hcs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := Result{Status: StatusUp}
+			if err := hc.Health(ctx); err != nil {
+				result = Result{Status: StatusDown, Error: err.Error()}
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			report.Components[name] = result
+			if result.Status == StatusDown {
+				report.Status = StatusDown
+			}
+		}()
+	}
+	wg.Wait()
+
+	return report
+}
+
+// Health returns error naming failed components.
+func (c *ComponentChecker) Health(ctx context.Context) error {
+	report := c.Report(ctx)
+	if report.Status == StatusUp {
+		return nil
+	}
+	var errs []error
+	for name, result := range report.Components {
+		if result.Status == StatusDown {
+			errs = append(errs, fmt.Errorf("%s: %s", name, result.Error))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// NewCachedChecker returns checker which reuses result of hc for ttl.
+// Useful for checks calling external services.
+func NewCachedChecker(hc Checker, ttl time.Duration) *CachedChecker {
+	return &CachedChecker{hc: hc, ttl: ttl}
+}
+
+// CachedChecker caches result of the underlying Checker.
+type CachedChecker struct {
+	hc        Checker
+	ttl       time.Duration
+	mu        sync.Mutex
+	err       error
+	checkedAt time.Time
+}
+
+func (c *CachedChecker) Health(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.checkedAt.IsZero() && time.Since(c.checkedAt) < c.ttl {
+		return c.err
+	}
+	err := c.hc.Health(ctx)
+	if ctx.Err() != nil {
+		// result of cancelled check says nothing about the component.
+		return err
+	}
+	c.err = err
+	c.checkedAt = time.Now()
+
+	return err
+}
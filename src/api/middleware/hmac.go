@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pralolik/templgrid/src/auth"
+	"github.com/pralolik/templgrid/src/logging"
+)
+
+const (
+	HMACKeyHeader       = "X-Templgrid-Key"
+	HMACTimestampHeader = "X-Templgrid-Timestamp"
+	HMACNonceHeader     = "X-Templgrid-Nonce"
+	HMACSignatureHeader = "X-Templgrid-Signature"
+
+	DefHMACWindow   = 5 * time.Minute
+	maxSignedBody   = 10 << 20
+	maxNonceLen     = 128
+	nonceCleanupGap = time.Minute
+)
+
+var (
+	ErrSignatureMissing = errors.New("request signature is missing")
+	ErrSignatureExpired = errors.New("request timestamp is out of the allowed window")
+	ErrSignatureReplay  = errors.New("request nonce was already used")
+)
+
+// MACVerifier checks message authentication codes.
+type MACVerifier interface {
+	VerifyMAC(keyName string, message, mac []byte) (*auth.Principal, error)
+}
+
+// SigningString returns canonical string signed by HMAC-SHA256:
+// method, request URI, unix timestamp, nonce and hex SHA256 of the body
+// joined by new lines.
+func SigningString(method, requestURI, timestamp, nonce string, body []byte) []byte {
+	bodyHash := sha256.Sum256(body)
+	return []byte(method + "\n" + requestURI + "\n" + timestamp + "\n" + nonce + "\n" + hex.EncodeToString(bodyHash[:]))
+}
+
+// IsSigned reports whether request carries HMAC signature.
+func IsSigned(r *http.Request) bool {
+	return r.Header.Get(HMACSignatureHeader) != ""
+}
+
+// HMAC verifies signed requests and stores the key principal in the request context.
+// Requests older or newer than window and repeated nonces are rejected.
+func HMAC(verifier MACVerifier, window time.Duration, log logging.Logger) func(next http.Handler) http.Handler {
+	nonces := newNonceCache(2 * window)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, err := verifyRequest(r, verifier, window, nonces)
+			if err != nil {
+				log.Error("HMAC verification failed for %s: %v ", r.URL.Path, err)
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+func verifyRequest(r *http.Request, verifier MACVerifier, window time.Duration, nonces *nonceCache) (*auth.Principal, error) {
+	timestamp := r.Header.Get(HMACTimestampHeader)
+	nonce := r.Header.Get(HMACNonceHeader)
+	signature := r.Header.Get(HMACSignatureHeader)
+	if timestamp == "" || nonce == "" || signature == "" || len(nonce) > maxNonceLen {
+		return nil, ErrSignatureMissing
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("incorrect timestamp: %w", err)
+	}
+	if age := time.Since(time.Unix(unix, 0)); age > window || age < -window {
+		return nil, ErrSignatureExpired
+	}
+
+	mac, err := hex.DecodeString(signature)
+	if err != nil {
+		return nil, fmt.Errorf("incorrect signature: %w", err)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxSignedBody))
+	if err != nil {
+		return nil, fmt.Errorf("can't read body: %w", err)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	message := SigningString(r.Method, r.URL.RequestURI(), timestamp, nonce, body)
+	principal, err := verifier.VerifyMAC(r.Header.Get(HMACKeyHeader), message, mac)
+	if err != nil {
+		return nil, err
+	}
+
+	// nonce is stored only for valid signatures, so it can't be burned by a forged request
+	if !nonces.add(nonce) {
+		return nil, ErrSignatureReplay
+	}
+
+	return principal, nil
+}
+
+// nonceCache remembers nonces for ttl.
+type nonceCache struct {
+	mu          sync.Mutex
+	ttl         time.Duration
+	nonces      map[string]time.Time
+	lastCleanup time.Time
+}
+
+func newNonceCache(ttl time.Duration) *nonceCache {
+	return &nonceCache{
+		ttl:    ttl,
+		nonces: map[string]time.Time{},
+	}
+}
+
+// add returns false if the nonce is already known.
+func (c *nonceCache) add(nonce string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(c.lastCleanup) > nonceCleanupGap {
+		c.lastCleanup = now
+		for n, expires := range c.nonces {
+			if now.After(expires) {
+				delete(c.nonces, n)
+			}
+		}
+	}
+	if expires, ok := c.nonces[nonce]; ok && now.Before(expires) {
+		return false
+	}
+	c.nonces[nonce] = now.Add(c.ttl)
+
+	return true
+}
@@ -19,15 +19,17 @@ func Logging(log logging.Logger) func(next http.Handler) http.Handler {
 			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
 			next.ServeHTTP(ww, r)
 			status := ww.Status()
+			uri := redactURI(r)
+			reqLog := logging.FromContext(r.Context(), log)
 
 			switch {
-			case strings.Contains(r.RequestURI, "/health"):
-				log.Info(format, r.Method, status, r.RequestURI, r.RemoteAddr, time.Since(start).String())
+			case strings.Contains(uri, "/health"):
+				reqLog.Info(format, r.Method, status, uri, r.RemoteAddr, time.Since(start).String())
 			default:
 				if status >= 400 {
-					log.Error(format, r.Method, status, r.RequestURI, r.RemoteAddr, time.Since(start).String())
+					reqLog.Error(format, r.Method, status, uri, r.RemoteAddr, time.Since(start).String())
 				} else {
-					log.Info(format, r.Method, status, r.RequestURI, r.RemoteAddr, time.Since(start).String())
+					reqLog.Info(format, r.Method, status, uri, r.RemoteAddr, time.Since(start).String())
 				}
 			}
 		}
@@ -35,3 +37,24 @@ func Logging(log logging.Logger) func(next http.Handler) http.Handler {
 		return http.HandlerFunc(fn)
 	}
 }
+
+// redactedParams are query params carrying secrets: API key and signed tokens of unsubscribe,
+// preferences and tracking links, which identify the recipient.
+var redactedParams = []string{"api_key", "token"}
+
+// redactURI returns request URI without secrets passed in query.
+func redactURI(r *http.Request) string {
+	query := r.URL.Query()
+	redacted := false
+	for _, param := range redactedParams {
+		if query.Get(param) != "" {
+			query.Set(param, "redacted")
+			redacted = true
+		}
+	}
+	if !redacted {
+		return r.RequestURI
+	}
+
+	return r.URL.Path + "?" + query.Encode()
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+)
+
+// HTTPObserver records served requests.
+type HTTPObserver interface {
+	ObserveHTTP(route, method string, status int, d time.Duration)
+}
+
+// Metrics reports requests to observer labeled by chi route pattern,
+// so path parameters don't blow up label cardinality.
+func Metrics(observer HTTPObserver) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+
+			route := "unmatched"
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			observer.ObserveHTTP(route, r.Method, status, time.Since(start))
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Limiter decides whether the request with key is allowed.
+type Limiter interface {
+	// Allow returns false and time to wait if the key is over the limit.
+	Allow(key string) (bool, time.Duration)
+	// AllowN is Allow for n requests at once, like emails of a batch.
+	AllowN(key string, n int) (bool, time.Duration)
+}
+
+// RateLimit rejects requests over the limit of their key with 429 and Retry-After header.
+// Key is taken from the request by keyFunc.
+func RateLimit(limiter Limiter, keyFunc func(r *http.Request) string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ok, retryAfter := limiter.Allow(keyFunc(r)); !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(RetryAfterSeconds(retryAfter)))
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RetryAfterSeconds rounds duration up to whole seconds, at least 1.
+func RetryAfterSeconds(d time.Duration) int {
+	seconds := int(math.Ceil(d.Seconds()))
+	if seconds < 1 {
+		return 1
+	}
+
+	return seconds
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/pralolik/templgrid/src/helper"
+	"github.com/pralolik/templgrid/src/logging"
+)
+
+const (
+	RequestIDHeader    = "X-Request-ID"
+	maxRequestIDLength = 128
+)
+
+// RequestID takes request id from X-Request-ID or generates a new one,
+// echoes it in the response and adds it to the logging fields of the request context.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if !ValidRequestID(requestID) {
+			requestID = helper.NewID()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+		ctx := logging.ContextWith(r.Context(), logging.RequestIDKey, requestID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// ValidRequestID accepts printable ASCII ids of limited length, so they are safe to log.
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < '!' || id[i] > '~' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracingRoutePattern(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	router := chi.NewRouter()
+	router.Use(Tracing())
+	router.Route("/emails", func(r chi.Router) {
+		r.Get("/{id}", func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		})
+	})
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/emails/m1?token=secret", nil))
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("%d spans ended, want 1", len(spans))
+	}
+	if name := spans[0].Name(); name != "GET /emails/{id}" {
+		t.Errorf("span name = %q, want route pattern", name)
+	}
+	attrs := map[string]string{}
+	for _, attr := range spans[0].Attributes() {
+		attrs[string(attr.Key)] = attr.Value.Emit()
+	}
+	if attrs["http.route"] != "/emails/{id}" || attrs["http.response.status_code"] != "404" {
+		t.Errorf("attributes = %v, want route and status", attrs)
+	}
+	for key, value := range attrs {
+		if value == "/emails/m1" || key == "url.path" {
+			t.Errorf("raw path is recorded in %s", key)
+		}
+	}
+}
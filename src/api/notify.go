@@ -0,0 +1,40 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/pralolik/templgrid/src/notify"
+)
+
+const (
+	defDeliveriesLimit = 100
+	maxDeliveriesLimit = 1000
+)
+
+type deliveryList struct {
+	Ok    bool              `json:"ok"`
+	Items []notify.Delivery `json:"items"`
+}
+
+func (s *Server) listDeliveries(rw http.ResponseWriter, r *http.Request) {
+	limit := defDeliveriesLimit
+	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+		var err error
+		if limit, err = strconv.Atoi(limitParam); err != nil || limit <= 0 || limit > maxDeliveriesLimit {
+			s.sendErrorValidationResponse(rw, fmt.Errorf("limit should be from 1 to %d", maxDeliveriesLimit))
+			return
+		}
+	}
+	deliveries := s.notifier.Deliveries(r.URL.Query().Get("message_id"), limit)
+	outgoingJSON, err := json.Marshal(deliveryList{Ok: true, Items: deliveries})
+	if err != nil {
+		s.sendInternalErrorResponse(rw, err)
+		return
+	}
+	if _, err = rw.Write(outgoingJSON); err != nil {
+		s.log.Error("Error with sending webhook deliveries: %v ", err)
+	}
+}
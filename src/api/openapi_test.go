@@ -0,0 +1,246 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+
+	"github.com/pralolik/templgrid/src/auth"
+	"github.com/pralolik/templgrid/src/history"
+	"github.com/pralolik/templgrid/src/ingest"
+	"github.com/pralolik/templgrid/src/logging"
+	"github.com/pralolik/templgrid/src/metrics"
+	"github.com/pralolik/templgrid/src/notify"
+	"github.com/pralolik/templgrid/src/preference"
+	"github.com/pralolik/templgrid/src/queue"
+	"github.com/pralolik/templgrid/src/resources"
+	"github.com/pralolik/templgrid/src/sendgrid"
+	"github.com/pralolik/templgrid/src/status"
+	"github.com/pralolik/templgrid/src/suppression"
+	"github.com/pralolik/templgrid/src/templatemanager"
+	"github.com/pralolik/templgrid/src/tracking"
+	"github.com/pralolik/templgrid/src/unsubscribe"
+)
+
+// TestOpenAPIRoutes checks that every route of the server with all features enabled
+// is documented in openapi.json and every documented operation is routed.
+func TestOpenAPIRoutes(t *testing.T) {
+	log := &logging.DisabledLog{}
+	statuses := status.NewMemoryStore(status.DefTTL)
+	suppressions := suppression.NewMemoryStore()
+	s := NewServer(log,
+		WithAPI(true, DefPort),
+		WithPreview(true, templatemanager.NewEmailStorage()),
+		WithStatusStore(statuses),
+		WithSuppressions(suppressions),
+		WithNotifier(notify.NewNotifier(log, statuses)),
+		WithUnsubscribe(unsubscribe.NewSigner("secret", "https://mail.example.com")),
+		WithPreferences(preference.NewCenter(preference.NewMemoryStore(), nil), "Preferences"),
+		WithTracker(tracking.NewTracker("secret", "https://mail.example.com", nil)),
+		WithHistory(history.New(log, history.NewMemoryStore())),
+		WithSendGridEvents(&sendgrid.EventVerifier{}, sendgrid.NewEventProcessor(log, statuses, suppressions)),
+		WithMetrics(metrics.New(), true),
+	)
+
+	var routed []string
+	err := chi.Walk(s.httpRouter, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if route != "/" {
+			route = strings.TrimSuffix(route, "/")
+		}
+		routed = append(routed, method+" "+route)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err = json.Unmarshal(openAPISpec, &spec); err != nil {
+		t.Fatalf("openapi.json is invalid: %v", err)
+	}
+	var documented []string
+	for path, item := range spec.Paths {
+		for method := range item {
+			switch method {
+			case "get", "post", "put", "patch", "delete":
+				documented = append(documented, strings.ToUpper(method)+" "+path)
+			}
+		}
+	}
+
+	for _, route := range routed {
+		if !slices.Contains(documented, route) {
+			t.Errorf("%s is routed, but not documented in openapi.json", route)
+		}
+	}
+	for _, operation := range documented {
+		if !slices.Contains(routed, operation) {
+			t.Errorf("%s is documented in openapi.json, but not routed", operation)
+		}
+	}
+}
+
+// TestOpenAPIResponses checks responses of the handlers against documented status codes,
+// content types and schemas of openapi.json.
+func TestOpenAPIResponses(t *testing.T) {
+	log := &logging.DisabledLog{}
+	storage := templatemanager.NewEmailStorage()
+	err := storage.AddEmail(&resources.TemplateResource{
+		Name:             "welcome",
+		EmailTemplate:    `{{define "subject"}}Hi{{end}}{{define "email"}}<p>{{.name}}</p>{{end}}`,
+		ParametersSchema: `{"type": "object", "required": ["name"], "properties": {"name": {"type": "string"}}}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := status.NewMemoryStore(status.DefTTL)
+	hist := history.New(log, history.NewMemoryStore())
+	hist.Record(history.Record{ID: "m1", TemplateName: "welcome", Recipients: []string{"john@example.com"}, State: status.Sent, SentAt: time.Now()})
+	keys := auth.NewKeySet(auth.Key{Name: "ops", Secret: "admin-secret", Scopes: []auth.Scope{auth.ScopeAdmin}})
+	s := NewServer(log,
+		WithAPI(true, DefPort),
+		WithAuth(keys),
+		WithPreview(false, storage),
+		WithStatusStore(statuses),
+		WithSuppressions(suppression.NewMemoryStore()),
+		WithHistory(hist),
+	)
+	s.ingest = ingest.New(storage, statuses, queue.NewInternalQueue(log))
+
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(openAPISpec))
+	if err != nil {
+		t.Fatalf("openapi.json is invalid: %v", err)
+	}
+	compiler := jsonschema.NewCompiler()
+	if err = compiler.AddResource("openapi.json", doc); err != nil {
+		t.Fatal(err)
+	}
+
+	email := func(name string) string {
+		return `{"template_name": "welcome", "email_parameters": {"name": ` + name + `},
+			"send_grid_parameters": {"personalizations": [{"to": [{"email": "john@example.com"}]}]}}`
+	}
+	valid := email(`"John"`)
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		path        string
+		contentType string
+		key         string
+		headers     map[string]string
+		body        string
+		code        int
+	}{
+		{"queued", http.MethodPost, "/email", "/email", "application/json", "admin-secret", nil, valid, http.StatusOK},
+		{"no key", http.MethodPost, "/email", "/email", "application/json", "", nil, valid, http.StatusForbidden},
+		{"text body", http.MethodPost, "/email", "/email", "text/plain", "admin-secret", nil, valid, http.StatusUnsupportedMediaType},
+		{"malformed", http.MethodPost, "/email", "/email", "application/json", "admin-secret", nil, `{"template_name":`, http.StatusBadRequest},
+		{"unknown template", http.MethodPost, "/email", "/email", "application/json", "admin-secret", nil,
+			strings.Replace(valid, "welcome", "reset", 1), http.StatusBadRequest},
+		{"invalid parameters", http.MethodPost, "/email", "/email", "application/json", "admin-secret", nil,
+			email("1"), http.StatusBadRequest},
+		{"idempotent", http.MethodPost, "/email", "/email", "application/json", "admin-secret",
+			map[string]string{IdempotencyHeader: "k1"}, valid, http.StatusOK},
+		{"idempotency mismatch", http.MethodPost, "/email", "/email", "application/json", "admin-secret",
+			map[string]string{IdempotencyHeader: "k1"}, email(`"Jane"`),
+			http.StatusUnprocessableEntity},
+		{"history", http.MethodGet, "/emails", "/emails", "", "admin-secret", nil, "", http.StatusOK},
+		{"history query", http.MethodGet, "/emails?limit=0", "/emails", "", "admin-secret", nil, "", http.StatusBadRequest},
+		{"history record", http.MethodGet, "/emails/m1", "/emails/{id}", "", "admin-secret", nil, "", http.StatusOK},
+		{"suppressions", http.MethodGet, "/admin/suppressions", "/admin/suppressions", "", "admin-secret", nil, "", http.StatusOK},
+		{"liveness", http.MethodGet, "/health", "/health", "", "", nil, "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+		if tt.contentType != "" {
+			r.Header.Set("Content-Type", tt.contentType)
+		}
+		if tt.key != "" {
+			r.Header.Set("X-Api-Key", tt.key)
+		}
+		for name, value := range tt.headers {
+			r.Header.Set(name, value)
+		}
+		rw := httptest.NewRecorder()
+		s.httpRouter.ServeHTTP(rw, r)
+		if rw.Code != tt.code {
+			t.Errorf("%s = %d %s, want %d", tt.name, rw.Code, rw.Body, tt.code)
+			continue
+		}
+
+		mediaType, _, _ := strings.Cut(rw.Header().Get("Content-Type"), ";")
+		pointer, err := responseSchema(doc, tt.path, strings.ToLower(tt.method), rw.Code, mediaType)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if mediaType != "application/json" {
+			continue
+		}
+		sch, err := compiler.Compile("openapi.json#" + pointer)
+		if err != nil {
+			t.Fatalf("%s: can't compile %s: %v", tt.name, pointer, err)
+		}
+		body, err := jsonschema.UnmarshalJSON(bytes.NewReader(rw.Body.Bytes()))
+		if err != nil {
+			t.Errorf("%s: response is not json: %v", tt.name, err)
+			continue
+		}
+		if err = sch.Validate(body); err != nil {
+			t.Errorf("%s: response %s doesn't match %s: %v", tt.name, rw.Body, pointer, err)
+		}
+		if tt.name == "invalid parameters" && !strings.Contains(rw.Body.String(), `"fields":[{"field":"email_parameters.name"`) {
+			t.Errorf("%s: response %s has no field errors", tt.name, rw.Body)
+		}
+	}
+}
+
+// responseSchema returns JSON pointer of the schema documented for the response,
+// error if the status code or content type is not documented.
+func responseSchema(doc any, path, method string, code int, mediaType string) (string, error) {
+	pointer := "/paths/" + escapePointer(path) + "/" + method + "/responses/" + strconv.Itoa(code)
+	response, ok := lookup(doc, pointer).(map[string]any)
+	if !ok {
+		return "", fmt.Errorf("response %d of %s %s is not documented", code, method, path)
+	}
+	if ref, ok := response["$ref"].(string); ok {
+		pointer = strings.TrimPrefix(ref, "#")
+	}
+	pointer += "/content/" + escapePointer(mediaType)
+	if _, ok := lookup(doc, pointer).(map[string]any); !ok {
+		return "", fmt.Errorf("content type %q of response %d of %s %s is not documented", mediaType, code, method, path)
+	}
+
+	return pointer + "/schema", nil
+}
+
+// lookup returns value of the JSON pointer in the document, nil if there is none.
+func lookup(doc any, pointer string) any {
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		object, ok := doc.(map[string]any)
+		if !ok {
+			return nil
+		}
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		doc = object[token]
+	}
+
+	return doc
+}
+
+func escapePointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/pralolik/templgrid/src/preference"
+	"github.com/pralolik/templgrid/src/unsubscribe"
+)
+
+// preferencesField is the form field listing subscribed categories.
+const preferencesField = "subscribed"
+
+// preferencesPage renders preference page template of the signed link on GET,
+// POST replaces opt-outs by optional categories not checked in the form.
+func (s *Server) preferencesPage(rw http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get(unsubscribe.TokenParam)
+	address, err := s.unsubscriber.VerifyPreferences(token)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	saved := false
+	if r.Method == http.MethodPost {
+		if err = r.ParseForm(); err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		subscribed := r.PostForm[preferencesField]
+		var optedOut []string
+		for _, category := range s.preferences.Categories() {
+			if !category.Transactional && !slices.Contains(subscribed, category.Name) {
+				optedOut = append(optedOut, category.Name)
+			}
+		}
+		err = s.preferences.Update(address, optedOut)
+		if errors.Is(err, preference.ErrInvalid) {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			s.log.Error("Can't update preferences of %s: %v ", address, err)
+			http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		s.log.Info("Preferences of %s updated, opted out of %v", address, optedOut)
+		saved = true
+	}
+
+	prefs, err := s.preferences.Get(address)
+	if err != nil {
+		s.log.Error("Can't get preferences of %s: %v ", address, err)
+		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	categories := make([]map[string]interface{}, 0, len(s.preferences.Categories()))
+	for _, category := range s.preferences.Categories() {
+		categories = append(categories, map[string]interface{}{
+			"name":          category.Name,
+			"title":         category.Title,
+			"transactional": category.Transactional,
+			"subscribed":    category.Transactional || prefs.Allows(category.Name),
+		})
+	}
+	_, page, err := s.emailStorage.BuildEmail(s.preferencePage, r.URL.Query().Get("locale"), map[string]interface{}{
+		"address":    address,
+		"categories": categories,
+		"field":      preferencesField,
+		"saved":      saved,
+	})
+	if err != nil {
+		s.log.Error("Can't render preference page: %v ", err)
+		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if _, err = rw.Write([]byte(page)); err != nil {
+		s.log.Error("Error with sending preference page: %v ", err)
+	}
+}
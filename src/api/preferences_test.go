@@ -0,0 +1,75 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/pralolik/templgrid/src/logging"
+	"github.com/pralolik/templgrid/src/preference"
+	"github.com/pralolik/templgrid/src/resources"
+	"github.com/pralolik/templgrid/src/templatemanager"
+	"github.com/pralolik/templgrid/src/unsubscribe"
+)
+
+func TestPreferencesPage(t *testing.T) {
+	log := &logging.DisabledLog{}
+	storage := templatemanager.NewEmailStorage()
+	err := storage.AddEmail(&resources.TemplateResource{
+		Name: "Preferences",
+		EmailTemplate: `{{define "subject"}}Preferences{{end}}` +
+			`{{define "email"}}<p>{{.address}} {{range .categories}}{{.name}}={{.subscribed}};{{end}}{{if .saved}} saved{{end}}</p>{{end}}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	center := preference.NewCenter(preference.NewMemoryStore(), []preference.Category{
+		{Name: "newsletters", Title: "Newsletters"},
+		{Name: "tips", Title: "Tips"},
+		{Name: "receipts", Title: "Receipts", Transactional: true},
+	})
+	signer := unsubscribe.NewSigner("secret", "https://mail.example.com")
+	s := NewServer(log, WithAPI(true, DefPort), WithPreview(false, storage),
+		WithUnsubscribe(signer), WithPreferences(center, "Preferences"))
+
+	target := signer.PreferencesURL("john@example.com")[len("https://mail.example.com"):]
+	serve := func(method, target string, form url.Values) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		r := httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		s.httpRouter.ServeHTTP(rw, r)
+		return rw
+	}
+
+	rw := serve(http.MethodGet, target, nil)
+	if rw.Code != http.StatusOK || !strings.Contains(rw.Body.String(), "john@example.com newsletters=true;tips=true;receipts=true;") {
+		t.Fatalf("page = %d %s, want every category subscribed", rw.Code, rw.Body)
+	}
+
+	// unchecked transactional category stays subscribed.
+	rw = serve(http.MethodPost, target, url.Values{preferencesField: {"tips"}})
+	if rw.Code != http.StatusOK || !strings.Contains(rw.Body.String(), "newsletters=false;tips=true;receipts=true; saved") {
+		t.Fatalf("saved page = %d %s, want newsletters opted out", rw.Code, rw.Body)
+	}
+	prefs, err := center.Get("john@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(prefs.OptedOut, []string{"newsletters"}) {
+		t.Errorf("opted out = %v, want newsletters", prefs.OptedOut)
+	}
+
+	// unsubscribe token of the same address isn't accepted by the page.
+	invalid := unsubscribe.PreferencesPath + "?" + unsubscribe.TokenParam + "=" + signer.Token("john@example.com", "tips")
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		if rw := serve(method, invalid, url.Values{}); rw.Code != http.StatusBadRequest {
+			t.Errorf("%s with unsubscribe token = %d, want 400", method, rw.Code)
+		}
+	}
+	if prefs, _ = center.Get("john@example.com"); !slices.Equal(prefs.OptedOut, []string{"newsletters"}) {
+		t.Errorf("rejected request changed preferences: %v", prefs.OptedOut)
+	}
+}
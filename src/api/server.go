@@ -14,31 +14,85 @@ import (
 	"github.com/pralolik/templgrid/pkg"
 	"github.com/pralolik/templgrid/src/api/lib/health"
 	"github.com/pralolik/templgrid/src/api/middleware"
+	"github.com/pralolik/templgrid/src/auth"
+	"github.com/pralolik/templgrid/src/history"
+	"github.com/pralolik/templgrid/src/ingest"
 	"github.com/pralolik/templgrid/src/logging"
+	"github.com/pralolik/templgrid/src/metrics"
+	"github.com/pralolik/templgrid/src/notify"
+	"github.com/pralolik/templgrid/src/preference"
 	"github.com/pralolik/templgrid/src/queue"
+	"github.com/pralolik/templgrid/src/redact"
+	"github.com/pralolik/templgrid/src/scheduler"
+	"github.com/pralolik/templgrid/src/sendgrid"
+	"github.com/pralolik/templgrid/src/status"
+	"github.com/pralolik/templgrid/src/suppression"
 	"github.com/pralolik/templgrid/src/templatemanager"
+	"github.com/pralolik/templgrid/src/tracking"
+	"github.com/pralolik/templgrid/src/unsubscribe"
 )
 
-const DefPort = ":8080"
+const (
+	DefPort         = ":8080"
+	shutdownTimeout = 5 * time.Second
+)
+
+// AuthMode represents how API requests are authenticated.
+type AuthMode string
+
+const (
+	// AuthModeKey accepts API key in headers.
+	AuthModeKey AuthMode = "key"
+	// AuthModeHMAC accepts only HMAC-SHA256 signed requests.
+	AuthModeHMAC AuthMode = "hmac"
+	// AuthModeAny accepts signed requests and API keys.
+	AuthModeAny AuthMode = "any"
+)
 
 type Server struct {
-	hc             health.Checker
+	hc             *health.ComponentChecker
 	log            logging.Logger
 	httpRouter     chi.Router
 	httpServer     *http.Server
-	apiKey         string
+	auth           auth.Authenticator
+	authMode       AuthMode
+	hmacWindow     time.Duration
+	queryKey       bool
+	hmacAuth       func(next http.Handler) http.Handler
+	limiter        middleware.Limiter
 	apiEnabled     bool
 	previewEnabled bool
 	emailStorage   *templatemanager.EmailStorage
-	queue          queue.Interface
+	statuses       status.Store
+	ingest         *ingest.Ingest
+	scheduler      *scheduler.Scheduler
+	suppressions   suppression.Store
+	notifier       *notify.Notifier
+	unsubscriber   *unsubscribe.Signer
+	preferences    *preference.Center
+	preferencePage string
+	tracker        *tracking.Tracker
+	history        *history.History
+	eventVerifier  *sendgrid.EventVerifier
+	eventProcessor *sendgrid.EventProcessor
+	idempotency    *idempotencyStore
+	metrics        *metrics.Metrics
+	redactor       *redact.Redactor
+	serveMetrics   bool
 }
 
 func NewServer(log logging.Logger, options ...Option) *Server {
 	httpRouter := chi.NewRouter()
 
 	api := Server{
-		hc:  health.NewMultiChecker(),
-		log: log,
+		hc:          health.NewComponentChecker(),
+		log:         log,
+		auth:        auth.NewKeySet(),
+		authMode:    AuthModeKey,
+		hmacWindow:  middleware.DefHMACWindow,
+		statuses:    status.NewMemoryStore(status.DefTTL),
+		idempotency: newIdempotencyStore(defIdempotencyTTL),
+		redactor:    redact.New(nil, nil),
 
 		httpRouter: httpRouter,
 		httpServer: &http.Server{
@@ -51,18 +105,89 @@ func NewServer(log logging.Logger, options ...Option) *Server {
 		option(&api)
 	}
 	mwLogging := middleware.Logging(log)
+	api.httpRouter.Use(middleware.RequestID)
 	api.httpRouter.Use(mwLogging)
+	api.httpRouter.Use(middleware.Tracing())
+	if api.metrics != nil {
+		api.httpRouter.Use(middleware.Metrics(api.metrics))
+	}
+	api.hmacAuth = middleware.HMAC(api.auth, api.hmacWindow, log)
 
 	if api.apiEnabled {
 		api.httpRouter.Route("/email", func(r chi.Router) {
 			r.Use(api.apiAuth)
+			r.Use(api.rateLimit)
+			r.Use(api.requireScope(auth.ScopeSend))
 			r.Use(api.jsonResponse)
 			r.Post("/", api.newEmail)
+			r.Delete("/{id}", api.cancelEmail)
+		})
+	}
+
+	if api.apiEnabled && api.suppressions != nil {
+		api.httpRouter.Route("/admin/suppressions", func(r chi.Router) {
+			r.Use(api.apiAuth)
+			r.Use(api.rateLimit)
+			r.Use(api.requireScope(auth.ScopeAdmin))
+			r.Use(api.jsonResponse)
+			r.Get("/", api.listSuppressions)
+			r.Post("/", api.addSuppression)
+			r.Delete("/{address}", api.removeSuppression)
+		})
+	}
+
+	if api.apiEnabled && api.history != nil {
+		api.httpRouter.Route("/emails", func(r chi.Router) {
+			r.Use(api.apiAuth)
+			r.Use(api.rateLimit)
+			r.Use(api.requireScope(auth.ScopeAdmin))
+			r.Use(api.jsonResponse)
+			r.Get("/", api.listEmails)
+			r.Get("/{id}", api.getEmail)
+		})
+	}
+
+	if api.apiEnabled && api.notifier != nil {
+		api.httpRouter.Route("/admin/webhooks", func(r chi.Router) {
+			r.Use(api.apiAuth)
+			r.Use(api.rateLimit)
+			r.Use(api.requireScope(auth.ScopeAdmin))
+			r.Use(api.jsonResponse)
+			r.Get("/deliveries", api.listDeliveries)
+		})
+	}
+
+	if api.apiEnabled && api.unsubscriber != nil && api.suppressions != nil {
+		// authenticated by the signed token of the link.
+		api.httpRouter.Get(unsubscribe.Path, api.unsubscribe)
+		api.httpRouter.Post(unsubscribe.Path, api.unsubscribe)
+	}
+
+	if api.apiEnabled && api.unsubscriber != nil && api.preferences != nil {
+		// authenticated by the signed token of the link.
+		api.httpRouter.Get(unsubscribe.PreferencesPath, api.preferencesPage)
+		api.httpRouter.Post(unsubscribe.PreferencesPath, api.preferencesPage)
+	}
+
+	if api.apiEnabled && api.tracker != nil {
+		// authenticated by the signed token of the link.
+		api.httpRouter.Get(tracking.ClickPath, api.trackClick)
+		api.httpRouter.Get(tracking.OpenPath, api.trackOpen)
+	}
+
+	if api.apiEnabled && api.eventVerifier != nil {
+		// authenticated by SendGrid signature instead of api keys.
+		api.httpRouter.Route("/webhooks/sendgrid", func(r chi.Router) {
+			r.Use(api.jsonResponse)
+			r.Post("/", api.sendgridEvents)
 		})
 	}
 
 	if api.previewEnabled {
 		api.httpRouter.Route("/preview", func(r chi.Router) {
+			r.Use(api.apiAuth)
+			r.Use(api.rateLimit)
+			r.Use(api.requireScope(auth.ScopePreview))
 			r.Get("/", api.main)
 			r.Get("/{locale}", api.locale)
 			r.Get("/{locale}/{slug}", api.template)
@@ -70,13 +195,27 @@ func NewServer(log logging.Logger, options ...Option) *Server {
 	}
 
 	api.httpRouter.Get("/health", api.health)
+	api.httpRouter.Get("/health/live", api.live)
+	api.httpRouter.Get("/health/ready", api.ready)
+	api.httpRouter.Get("/openapi.json", api.openAPI)
+	if api.metrics != nil && api.serveMetrics {
+		api.httpRouter.Method(http.MethodGet, "/metrics", api.metrics.Handler())
+	}
 
 	return &api
 }
 
+// Serve serves requests until ctx is done.
+// Returns after in-flight requests are finished, so nothing is pushed to the queue afterwards.
 func (s *Server) Serve(ctx context.Context, queue queue.Interface) error {
-	go s.handleShutdown(ctx)
-	s.queue = queue
+	stopped := make(chan struct{})
+	go s.handleShutdown(ctx, stopped)
+	s.ingest = ingest.New(
+		s.emailStorage,
+		s.statuses,
+		queue,
+		ingest.WithScheduler(s.scheduler),
+		ingest.WithNotifier(s.notifier))
 	s.log.Info("Server server started to serve on: '%s'", s.httpServer.Addr)
 	if err := s.httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		s.log.Error("Server failed: %v ", err)
@@ -86,34 +225,85 @@ func (s *Server) Serve(ctx context.Context, queue queue.Interface) error {
 		}
 		return fmt.Errorf("server failed: %w", err)
 	}
+	<-stopped
 
 	return nil
 }
 
-func (s *Server) handleShutdown(ctx context.Context) {
+func (s *Server) handleShutdown(ctx context.Context, stopped chan<- struct{}) {
+	defer close(stopped)
 	<-ctx.Done()
 	s.log.Info("Shutting down the server!")
 
-	killctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	killctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 	defer cancel()
 	s.httpServer.SetKeepAlivesEnabled(false)
 	if err := s.httpServer.Shutdown(killctx); err != nil {
-		s.log.Error("Panic! Failed to shutdown the HTTP server gracefully: %v ", err)
-		panic(err)
+		s.log.Error("Failed to shutdown the HTTP server gracefully: %v ", err)
+		_ = s.httpServer.Close()
 	}
 }
 
 func (s *Server) apiAuth(next http.Handler) http.Handler {
+	keyAuth := s.keyAuth(next)
+	hmacAuth := s.hmacAuth(next)
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		apiKey := r.URL.Query().Get("api_key")
-		if apiKey != s.apiKey {
+		if s.authMode == AuthModeHMAC || (s.authMode == AuthModeAny && middleware.IsSigned(r)) {
+			hmacAuth.ServeHTTP(w, r)
+			return
+		}
+		keyAuth.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) keyAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := auth.KeyFromRequest(r)
+		fromQuery := false
+		if key == "" && s.queryKey {
+			key = r.URL.Query().Get("api_key")
+			fromQuery = key != ""
+		}
+		principal, err := s.auth.Authenticate(key)
+		if err != nil {
 			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
 			return
 		}
-		next.ServeHTTP(w, r)
+		if fromQuery {
+			logging.FromContext(r.Context(), s.log).Info(
+				"WARNING: API key %s is passed in deprecated api_key query parameter, use headers", principal.Name)
+		}
+		next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
 	})
 }
 
+func (s *Server) rateLimit(next http.Handler) http.Handler {
+	if s.limiter == nil {
+		return next
+	}
+
+	return middleware.RateLimit(s.limiter, func(r *http.Request) string {
+		if principal, ok := auth.PrincipalFromContext(r.Context()); ok {
+			return principal.Name
+		}
+		return ""
+	})(next)
+}
+
+func (s *Server) requireScope(scope auth.Scope) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := auth.PrincipalFromContext(r.Context())
+			if !ok || principal.Authorize(scope) != nil {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func (s *Server) jsonResponse(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("content-type", "application/json")
@@ -121,10 +311,11 @@ func (s *Server) jsonResponse(next http.Handler) http.Handler {
 	})
 }
 
-func (s *Server) sendSuccessfulResponse(rw http.ResponseWriter) {
+func (s *Server) sendSuccessfulResponse(rw http.ResponseWriter, message, id string) {
 	outgoingJSON, err := json.Marshal(pkg.SuccessfulResponse{
 		Ok:      true,
-		Message: "Message successfully queued",
+		Message: message,
+		ID:      id,
 	})
 	if err != nil {
 		s.sendInternalErrorResponse(rw, err)
@@ -139,10 +330,15 @@ func (s *Server) sendSuccessfulResponse(rw http.ResponseWriter) {
 }
 
 func (s *Server) sendErrorValidationResponse(rw http.ResponseWriter, validationErr error) {
-	outgoingJSON, err := json.Marshal(pkg.ErrorResponse{
+	errResponse := pkg.ErrorResponse{
 		Ok:    false,
 		Error: validationErr.Error(),
-	})
+	}
+	var paramsErr *templatemanager.ParametersError
+	if errors.As(validationErr, &paramsErr) {
+		errResponse.Fields = paramsErr.Fields
+	}
+	outgoingJSON, err := json.Marshal(errResponse)
 	rw.WriteHeader(http.StatusBadRequest)
 	if err != nil {
 		s.sendInternalErrorResponse(rw, fmt.Errorf("error with marshal error validation response: %w ", err))
@@ -156,6 +352,23 @@ func (s *Server) sendErrorValidationResponse(rw http.ResponseWriter, validationE
 	s.log.Info("Server validation error %d: %v ", validationErr)
 }
 
+func (s *Server) sendErrorResponse(rw http.ResponseWriter, status int, respErr error) {
+	outgoingJSON, err := json.Marshal(pkg.ErrorResponse{
+		Ok:    false,
+		Error: respErr.Error(),
+	})
+	if err != nil {
+		s.sendInternalErrorResponse(rw, fmt.Errorf("error with marshal error response: %w ", err))
+		return
+	}
+	rw.WriteHeader(status)
+	if _, err = rw.Write(outgoingJSON); err != nil {
+		s.log.Error("Error with sending error response: %v ", err)
+		return
+	}
+	s.log.Info("Server error response %d: %v ", status, respErr)
+}
+
 func (s *Server) sendInternalErrorResponse(rw http.ResponseWriter, internalError error) {
 	outgoingJSON, err := json.Marshal(pkg.ErrorResponse{
 		Ok:    false,
@@ -180,14 +393,128 @@ func (s *Server) HasTemplateName(templateName string) error {
 
 type Option func(s *Server)
 
-func WithAPI(enabled bool, apiKey string, addr string) Option {
+func WithAPI(enabled bool, addr string) Option {
 	return func(s *Server) {
 		s.apiEnabled = enabled
-		s.apiKey = apiKey
 		s.httpServer.Addr = addr
 	}
 }
 
+func WithAuth(authenticator auth.Authenticator) Option {
+	return func(s *Server) {
+		s.auth = authenticator
+	}
+}
+
+func WithAuthMode(mode AuthMode, hmacWindow time.Duration) Option {
+	return func(s *Server) {
+		s.authMode = mode
+		if hmacWindow > 0 {
+			s.hmacWindow = hmacWindow
+		}
+	}
+}
+
+// WithQueryKey accepts API keys in deprecated api_key query parameter, every use is logged.
+func WithQueryKey(enabled bool) Option {
+	return func(s *Server) {
+		s.queryKey = enabled
+	}
+}
+
+// WithRateLimit limits requests per API key.
+func WithRateLimit(limiter middleware.Limiter) Option {
+	return func(s *Server) {
+		s.limiter = limiter
+	}
+}
+
+func WithStatusStore(statuses status.Store) Option {
+	return func(s *Server) {
+		s.statuses = statuses
+	}
+}
+
+// WithScheduler enables scheduled delivery and cancellation of scheduled emails.
+func WithScheduler(sch *scheduler.Scheduler) Option {
+	return func(s *Server) {
+		s.scheduler = sch
+	}
+}
+
+// WithSuppressions enables admin endpoints managing the suppression list.
+func WithSuppressions(store suppression.Store) Option {
+	return func(s *Server) {
+		s.suppressions = store
+	}
+}
+
+// WithNotifier enables status callbacks of accepted emails and the delivery log endpoint.
+func WithNotifier(n *notify.Notifier) Option {
+	return func(s *Server) {
+		s.notifier = n
+	}
+}
+
+// WithUnsubscribe enables public endpoint of unsubscribe links, requires suppressions.
+func WithUnsubscribe(signer *unsubscribe.Signer) Option {
+	return func(s *Server) {
+		s.unsubscriber = signer
+	}
+}
+
+// WithPreferences enables public preference page rendered by the template, requires unsubscribe links.
+func WithPreferences(center *preference.Center, template string) Option {
+	return func(s *Server) {
+		s.preferences = center
+		s.preferencePage = template
+	}
+}
+
+// WithTracker enables public click redirects and open pixel.
+func WithTracker(tracker *tracking.Tracker) Option {
+	return func(s *Server) {
+		s.tracker = tracker
+	}
+}
+
+// WithHistory enables admin search of sent emails.
+func WithHistory(h *history.History) Option {
+	return func(s *Server) {
+		s.history = h
+	}
+}
+
+// WithSendGridEvents enables Event Webhook endpoint verified by verifier.
+func WithSendGridEvents(verifier *sendgrid.EventVerifier, processor *sendgrid.EventProcessor) Option {
+	return func(s *Server) {
+		s.eventVerifier = verifier
+		s.eventProcessor = processor
+	}
+}
+
+// WithMetrics records request metrics, serve exposes /metrics on the API port.
+func WithMetrics(m *metrics.Metrics, serve bool) Option {
+	return func(s *Server) {
+		s.metrics = m
+		s.serveMetrics = serve
+	}
+}
+
+// WithHealth sets checker of components reported by readiness endpoints.
+func WithHealth(hc *health.ComponentChecker) Option {
+	return func(s *Server) {
+		s.hc = hc
+	}
+}
+
+// WithRedactor sets redactor of parameters logged on validation errors.
+func WithRedactor(r *redact.Redactor) Option {
+	return func(s *Server) {
+		s.redactor = r
+	}
+}
+
 func WithPreview(enabled bool, storage *templatemanager.EmailStorage) Option {
 	return func(s *Server) {
 		s.previewEnabled = enabled
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+
+	"github.com/pralolik/templgrid/src/suppression"
+)
+
+type suppressionList struct {
+	Ok         bool                `json:"ok"`
+	Items      []suppression.Entry `json:"items"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
+func (s *Server) listSuppressions(rw http.ResponseWriter, r *http.Request) {
+	limit := suppression.DefListLimit
+	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+		var err error
+		if limit, err = strconv.Atoi(limitParam); err != nil || limit <= 0 || limit > suppression.MaxListLimit {
+			s.sendErrorValidationResponse(rw, fmt.Errorf("limit should be from 1 to %d", suppression.MaxListLimit))
+			return
+		}
+	}
+	entries, next, err := s.suppressions.List(r.URL.Query().Get("cursor"), limit)
+	if errors.Is(err, suppression.ErrInvalid) {
+		s.sendErrorValidationResponse(rw, err)
+		return
+	}
+	if err != nil {
+		s.sendInternalErrorResponse(rw, err)
+		return
+	}
+	outgoingJSON, err := json.Marshal(suppressionList{Ok: true, Items: entries, NextCursor: next})
+	if err != nil {
+		s.sendInternalErrorResponse(rw, err)
+		return
+	}
+	if _, err = rw.Write(outgoingJSON); err != nil {
+		s.log.Error("Error with sending suppressions: %v ", err)
+	}
+}
+
+func (s *Server) addSuppression(rw http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Content-Type") != "application/json" {
+		http.Error(rw, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+		return
+	}
+	var entry suppression.Entry
+	if err := json.NewDecoder(r.Body).Decode(&entry); err != nil {
+		s.sendErrorValidationResponse(rw, err)
+		return
+	}
+	entry.CreatedAt = entry.CreatedAt.UTC()
+	err := s.suppressions.Add(entry)
+	if errors.Is(err, suppression.ErrInvalid) {
+		s.sendErrorValidationResponse(rw, err)
+		return
+	}
+	if err != nil {
+		s.sendInternalErrorResponse(rw, err)
+		return
+	}
+	s.sendSuccessfulResponse(rw, "Suppression added", "")
+	s.log.Info("Suppression of %s added, reason %s, category '%s'", entry.Address, entry.Reason, entry.Category)
+}
+
+func (s *Server) removeSuppression(rw http.ResponseWriter, r *http.Request) {
+	address := chi.URLParam(r, "address")
+	category := r.URL.Query().Get("category")
+	err := s.suppressions.Remove(address, category)
+	if errors.Is(err, suppression.ErrNotFound) {
+		s.sendErrorResponse(rw, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		s.sendInternalErrorResponse(rw, err)
+		return
+	}
+	s.sendSuccessfulResponse(rw, "Suppression removed", "")
+	s.log.Info("Suppression of %s removed, category '%s'", address, category)
+}
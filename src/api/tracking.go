@@ -0,0 +1,57 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/pralolik/templgrid/src/status"
+	"github.com/pralolik/templgrid/src/tracking"
+)
+
+// pixelGIF is transparent 1x1 GIF served as the open pixel.
+var pixelGIF = []byte{
+	0x47, 0x49, 0x46, 0x38, 0x39, 0x61, 0x01, 0x00, 0x01, 0x00, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0xff, 0xff, 0xff, 0x21, 0xf9, 0x04, 0x01, 0x00, 0x00, 0x00, 0x00, 0x2c, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x01, 0x00, 0x00, 0x02, 0x02, 0x44, 0x01, 0x00, 0x3b,
+}
+
+// trackClick records click of the signed link and redirects to the original link.
+func (s *Server) trackClick(rw http.ResponseWriter, r *http.Request) {
+	messageID, link, err := s.tracker.VerifyClick(r.URL.Query().Get(tracking.TokenParam))
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.recordTracking(messageID, status.Event{Type: tracking.EventClick, URL: link, Timestamp: time.Now().UTC()})
+	http.Redirect(rw, r, link, http.StatusFound)
+}
+
+// trackOpen records open of the message and serves the pixel, invalid tokens get the pixel too.
+func (s *Server) trackOpen(rw http.ResponseWriter, r *http.Request) {
+	messageID, err := s.tracker.VerifyOpen(r.URL.Query().Get(tracking.TokenParam))
+	if err == nil {
+		s.recordTracking(messageID, status.Event{Type: tracking.EventOpen, Timestamp: time.Now().UTC()})
+	}
+	rw.Header().Set("Content-Type", "image/gif")
+	rw.Header().Set("Cache-Control", "no-store, max-age=0")
+	if _, err = rw.Write(pixelGIF); err != nil {
+		s.log.Error("Error with sending open pixel: %v ", err)
+	}
+}
+
+// recordTracking adds the event to the status and to the history record, which is kept after the status expires.
+func (s *Server) recordTracking(messageID string, event status.Event) {
+	statuses := s.statuses
+	if s.history != nil {
+		statuses = s.history.Watch(statuses)
+	}
+	err := statuses.AddEvent(messageID, event)
+	if errors.Is(err, status.ErrNotFound) {
+		s.log.Debug("%s event of unknown message %s skipped", event.Type, messageID)
+		return
+	}
+	if err != nil {
+		s.log.Error("Can't record %s event of message %s: %v ", event.Type, messageID, err)
+	}
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pralolik/templgrid/src/history"
+	"github.com/pralolik/templgrid/src/logging"
+	"github.com/pralolik/templgrid/src/status"
+	"github.com/pralolik/templgrid/src/tracking"
+)
+
+func TestTracking(t *testing.T) {
+	log := &logging.DisabledLog{}
+	statuses := status.NewMemoryStore(status.DefTTL)
+	if err := statuses.Create("m1", "welcome", "shop"); err != nil {
+		t.Fatal(err)
+	}
+	hist := history.New(log, history.NewMemoryStore())
+	hist.Record(history.Record{ID: "m1", TemplateName: "welcome", SentAt: time.Now()})
+	tracker := tracking.NewTracker("secret", "https://mail.example.com", nil)
+	s := NewServer(log, WithAPI(true, DefPort), WithStatusStore(statuses), WithTracker(tracker), WithHistory(hist))
+
+	serve := func(target string) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		s.httpRouter.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, target, nil))
+		return rw
+	}
+	path := func(rawURL string) string {
+		return rawURL[len("https://mail.example.com"):]
+	}
+
+	click := serve(path(tracker.ClickURL("m1", "https://shop.example.com/sale")))
+	if click.Code != http.StatusFound || click.Header().Get("Location") != "https://shop.example.com/sale" {
+		t.Errorf("click = %d %s, want redirect to the link", click.Code, click.Header().Get("Location"))
+	}
+	if tampered := serve(path(tracker.ClickURL("m1", "https://shop.example.com/sale")) + "x"); tampered.Code != http.StatusBadRequest {
+		t.Errorf("tampered click = %d, want 400", tampered.Code)
+	}
+	if cross := serve(tracking.ClickPath + "?" + tracking.TokenParam + "=" + path(tracker.OpenURL("m1"))[len(tracking.OpenPath+"?token="):]); cross.Code != http.StatusBadRequest {
+		t.Errorf("click with open token = %d, want 400", cross.Code)
+	}
+
+	open := serve(path(tracker.OpenURL("m1")))
+	if open.Code != http.StatusOK || open.Header().Get("Content-Type") != "image/gif" || open.Body.Len() != len(pixelGIF) {
+		t.Errorf("open = %d %s, want pixel", open.Code, open.Header().Get("Content-Type"))
+	}
+	// invalid tokens get the pixel, but no event.
+	if invalid := serve(tracking.OpenPath + "?token=invalid"); invalid.Code != http.StatusOK {
+		t.Errorf("open with invalid token = %d, want 200", invalid.Code)
+	}
+
+	st, err := statuses.Get("m1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(st.Events) != 2 || st.Events[0].Type != tracking.EventClick || st.Events[1].Type != tracking.EventOpen {
+		t.Errorf("status events = %+v, want click and open", st.Events)
+	}
+	if st.Events[0].URL != "https://shop.example.com/sale" {
+		t.Errorf("click url = %q", st.Events[0].URL)
+	}
+	rec, err := hist.Get("m1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.Events) != 2 {
+		t.Errorf("history events = %+v, want click and open", rec.Events)
+	}
+}
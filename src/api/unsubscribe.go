@@ -0,0 +1,72 @@
+package api
+
+import (
+	"html/template"
+	"net/http"
+
+	"github.com/pralolik/templgrid/src/suppression"
+	"github.com/pralolik/templgrid/src/unsubscribe"
+)
+
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><meta name="viewport" content="width=device-width"><title>Unsubscribe</title></head>
+<body>
+{{- if .Done}}
+<p>{{.Address}} is unsubscribed from {{if .Category}}{{.Category}}{{else}}all{{end}} emails.</p>
+{{- else}}
+<form method="post">
+<p>Unsubscribe {{.Address}} from {{if .Category}}{{.Category}}{{else}}all{{end}} emails?</p>
+<input type="hidden" name="List-Unsubscribe" value="One-Click">
+<button type="submit">Unsubscribe</button>
+</form>
+{{- end}}
+</body></html>
+`))
+
+// optOut records opt-out of optional preference category, suppression otherwise.
+func (s *Server) optOut(address, category string) error {
+	if s.preferences != nil && s.preferences.Optional(category) {
+		return s.preferences.OptOut(address, category)
+	}
+
+	return s.suppressions.Add(suppression.Entry{
+		Address:  address,
+		Reason:   suppression.ReasonUnsubscribe,
+		Category: category,
+	})
+}
+
+type unsubscribeView struct {
+	Address  string
+	Category string
+	Done     bool
+}
+
+// unsubscribe shows confirmation form on GET, so link scanners don't opt recipients out,
+// and records opt-out on POST sent by the form or RFC 8058 one-click mail clients.
+func (s *Server) unsubscribe(rw http.ResponseWriter, r *http.Request) {
+	address, category, err := s.unsubscriber.Verify(r.URL.Query().Get(unsubscribe.TokenParam))
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	view := unsubscribeView{Address: address, Category: category}
+	if s.preferences != nil && s.preferences.Transactional(category) {
+		http.Error(rw, "transactional emails can't be unsubscribed", http.StatusBadRequest)
+		return
+	}
+	if r.Method == http.MethodPost {
+		err = s.optOut(address, category)
+		if err != nil {
+			s.log.Error("Can't unsubscribe %s from '%s': %v ", address, category, err)
+			http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		s.log.Info("%s unsubscribed from '%s'", address, category)
+		view.Done = true
+	}
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err = unsubscribePage.Execute(rw, view); err != nil {
+		s.log.Error("Error with sending unsubscribe page: %v ", err)
+	}
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/sendgrid/sendgrid-go/helpers/eventwebhook"
+
+	"github.com/pralolik/templgrid/src/sendgrid"
+)
+
+// maxEventsBody limits Event Webhook request, SendGrid batches events up to a few megabytes.
+const maxEventsBody = 10 << 20
+
+func (s *Server) sendgridEvents(rw http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(rw, r.Body, maxEventsBody))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			s.sendErrorResponse(rw, http.StatusRequestEntityTooLarge, err)
+			return
+		}
+		s.sendErrorValidationResponse(rw, err)
+		return
+	}
+	err = s.eventVerifier.Verify(
+		body,
+		r.Header.Get(eventwebhook.VerificationHTTPHeader),
+		r.Header.Get(eventwebhook.TimestampHTTPHeader),
+		time.Now())
+	if err != nil {
+		s.log.Error("SendGrid events rejected: %v ", err)
+		s.sendErrorResponse(rw, http.StatusForbidden, sendgrid.ErrInvalidSignature)
+		return
+	}
+	events, err := sendgrid.ParseEvents(body)
+	if err != nil {
+		s.sendErrorValidationResponse(rw, err)
+		return
+	}
+	s.eventProcessor.Process(events)
+	s.sendSuccessfulResponse(rw, "Events processed", "")
+	s.log.Debug("%d SendGrid events processed", len(events))
+}
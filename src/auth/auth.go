@@ -0,0 +1,223 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var (
+	// ErrUnauthorized indicates that request credentials are missing or invalid.
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrForbidden indicates that the key isn't allowed to perform the request.
+	ErrForbidden = errors.New("forbidden")
+)
+
+// Scope represents set of operations allowed for the key.
+type Scope string
+
+const (
+	// ScopeSend allows to queue emails and read their statuses.
+	ScopeSend Scope = "send"
+	// ScopeRender allows to render emails without sending.
+	ScopeRender Scope = "render"
+	// ScopePreview allows to browse template previews.
+	ScopePreview Scope = "preview"
+	// ScopeAdmin allows everything including admin endpoints.
+	ScopeAdmin Scope = "admin"
+)
+
+// AllScopes lists every known scope.
+var AllScopes = []Scope{ScopeSend, ScopeRender, ScopePreview, ScopeAdmin}
+
+// ParseScope converts string to Scope.
+func ParseScope(s string) (Scope, error) {
+	for _, scope := range AllScopes {
+		if string(scope) == strings.ToLower(s) {
+			return scope, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown scope %s, use: %v", s, AllScopes)
+}
+
+// Key describes API key with its permissions.
+type Key struct {
+	Name   string
+	Secret string
+	Scopes []Scope
+	// Templates limits templates the key may use, empty means all.
+	Templates []string
+}
+
+// Principal is the authenticated owner of the API key.
+type Principal struct {
+	Name      string
+	scopes    map[Scope]bool
+	templates map[string]bool
+}
+
+// HasScope reports whether the principal is allowed the scope.
+// Admin scope implies all others.
+func (p *Principal) HasScope(scope Scope) bool {
+	return p.scopes[scope] || p.scopes[ScopeAdmin]
+}
+
+// AllowsTemplate reports whether the principal may use the template.
+func (p *Principal) AllowsTemplate(name string) bool {
+	return len(p.templates) == 0 || p.templates[name]
+}
+
+// Authorize checks scope of the principal.
+func (p *Principal) Authorize(scope Scope) error {
+	if !p.HasScope(scope) {
+		return fmt.Errorf("%w: key %s has no scope %s", ErrForbidden, p.Name, scope)
+	}
+
+	return nil
+}
+
+// Owns reports whether the principal may access messages sent by the key,
+// admin keys access messages of all keys.
+func (p *Principal) Owns(keyName string) bool {
+	return p.Name == keyName || p.scopes[ScopeAdmin]
+}
+
+// AuthorizeTemplate checks that the principal may use the template.
+func (p *Principal) AuthorizeTemplate(name string) error {
+	if !p.AllowsTemplate(name) {
+		return fmt.Errorf("%w: key %s can't use template %s", ErrForbidden, p.Name, name)
+	}
+
+	return nil
+}
+
+// Authenticator checks credentials of API requests.
+type Authenticator interface {
+	// Authenticate returns principal for the valid key or ErrUnauthorized.
+	Authenticate(key string) (*Principal, error)
+	// VerifyMAC returns principal of the key which signed the message or ErrUnauthorized.
+	VerifyMAC(keyName string, message, mac []byte) (*Principal, error)
+}
+
+// NewKeySet returns Authenticator accepting given keys.
+// Keys with empty secret are ignored.
+func NewKeySet(keys ...Key) *KeySet {
+	ks := &KeySet{}
+	for _, key := range keys {
+		if key.Secret == "" {
+			continue
+		}
+		p := &Principal{
+			Name:      key.Name,
+			scopes:    map[Scope]bool{},
+			templates: map[string]bool{},
+		}
+		for _, scope := range key.Scopes {
+			p.scopes[scope] = true
+		}
+		for _, tmpl := range key.Templates {
+			p.templates[tmpl] = true
+		}
+		ks.entries = append(ks.entries, keyEntry{
+			name:      key.Name,
+			secret:    []byte(key.Secret),
+			hash:      sha256.Sum256([]byte(key.Secret)),
+			principal: p,
+		})
+	}
+
+	return ks
+}
+
+// KeySet implements Authenticator with the set of named keys.
+// Keys are compared by their hashes in constant time.
+type KeySet struct {
+	entries []keyEntry
+}
+
+type keyEntry struct {
+	name      string
+	secret    []byte
+	hash      [sha256.Size]byte
+	principal *Principal
+}
+
+// Len returns count of usable keys.
+func (ks *KeySet) Len() int { return len(ks.entries) }
+
+func (ks *KeySet) Authenticate(key string) (*Principal, error) {
+	if key == "" {
+		return nil, ErrUnauthorized
+	}
+	hash := sha256.Sum256([]byte(key))
+	var found *Principal
+	for i := range ks.entries {
+		if subtle.ConstantTimeCompare(hash[:], ks.entries[i].hash[:]) == 1 {
+			found = ks.entries[i].principal
+		}
+	}
+	if found == nil {
+		return nil, ErrUnauthorized
+	}
+
+	return found, nil
+}
+
+// VerifyMAC checks HMAC-SHA256 of the message signed with the named key.
+// If keyName is empty every key is tried, which allows rotation
+// by configuring old and new keys together.
+func (ks *KeySet) VerifyMAC(keyName string, message, mac []byte) (*Principal, error) {
+	var found *Principal
+	for i := range ks.entries {
+		if keyName != "" && ks.entries[i].name != keyName {
+			continue
+		}
+		h := hmac.New(sha256.New, ks.entries[i].secret)
+		h.Write(message)
+		if hmac.Equal(h.Sum(nil), mac) {
+			found = ks.entries[i].principal
+		}
+	}
+	if found == nil {
+		return nil, ErrUnauthorized
+	}
+
+	return found, nil
+}
+
+// KeyFromHeader extracts API key from `Authorization: Bearer` or `X-Api-Key` header value.
+func KeyFromHeader(authorization, xAPIKey string) string {
+	if xAPIKey != "" {
+		return xAPIKey
+	}
+	const prefix = "bearer "
+	if len(authorization) > len(prefix) && strings.EqualFold(authorization[:len(prefix)], prefix) {
+		return strings.TrimSpace(authorization[len(prefix):])
+	}
+
+	return ""
+}
+
+// KeyFromRequest extracts API key from request headers.
+func KeyFromRequest(r *http.Request) string {
+	return KeyFromHeader(r.Header.Get("Authorization"), r.Header.Get("X-Api-Key"))
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns context holding the principal.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns principal stored by WithPrincipal.
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
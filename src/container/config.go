@@ -3,9 +3,21 @@ package container
 import (
 	"flag"
 	"fmt"
+	"net/url"
 	"os"
+	"time"
 
 	"gopkg.in/yaml.v2"
+
+	"github.com/pralolik/templgrid/pkg"
+	"github.com/pralolik/templgrid/src/api"
+	"github.com/pralolik/templgrid/src/auth"
+	"github.com/pralolik/templgrid/src/logging"
+	"github.com/pralolik/templgrid/src/preference"
+	"github.com/pralolik/templgrid/src/redact"
+	"github.com/pralolik/templgrid/src/sendgrid"
+	"github.com/pralolik/templgrid/src/templatemanager"
+	"github.com/pralolik/templgrid/src/tracing"
 )
 
 const (
@@ -15,27 +27,519 @@ const (
 
 var AvailableCommands = []string{VersionCmd, RunCommand}
 
+// legacyKeyName is the name of the principal authenticated by api-key.
+const legacyKeyName = "default"
+
 type Config struct {
-	Command   string
-	LogLvl    string         `yaml:"logLvl"`
-	APIConfig apiConfig      `yaml:"api"`
-	Sendgrid  sendgridConfig `yaml:"sendgrid"`
+	Command     string
+	LogLvl      string            `yaml:"logLvl"`
+	LogFormat   string            `yaml:"logFormat"`
+	APIConfig   apiConfig         `yaml:"api"`
+	GRPC        grpcConfig        `yaml:"grpc"`
+	Sendgrid    sendgridConfig    `yaml:"sendgrid"`
+	Templates   templatesConfig   `yaml:"templates"`
+	RateLimit   rateLimitConfig   `yaml:"rate-limit"`
+	Metrics     metricsConfig     `yaml:"metrics"`
+	Tracing     tracingConfig     `yaml:"tracing"`
+	Redaction   redactionConfig   `yaml:"redaction"`
+	Health      healthConfig      `yaml:"health"`
+	Shutdown    shutdownConfig    `yaml:"shutdown"`
+	Queue       queueConfig       `yaml:"queue"`
+	Scheduler   schedulerConfig   `yaml:"scheduler"`
+	Suppression suppressionConfig `yaml:"suppression"`
+	// Notifications posts status changes to callback URLs and webhooks of api keys.
+	Notifications notificationsConfig `yaml:"notifications"`
+	// Unsubscribe signs unsubscribe links served by the api.
+	Unsubscribe unsubscribeConfig `yaml:"unsubscribe"`
+	// Preferences lets recipients opt out of template categories.
+	Preferences preferencesConfig `yaml:"preferences"`
+	// Tracking rewrites links to redirects and adds open pixel served by the api.
+	Tracking trackingConfig `yaml:"tracking"`
+	// History keeps records of sent emails searchable by admin api.
+	History historyConfig `yaml:"history"`
+}
+
+// Redactor returns redactor masking configured parameter fields and all configured secrets.
+func (c *Config) Redactor() *redact.Redactor {
+	secrets := []string{c.APIConfig.APIKey, c.Sendgrid.PrivateToken}
+	for _, key := range c.APIConfig.Keys {
+		secrets = append(secrets, key.Key, key.Webhook.Secret)
+	}
+	secrets = append(secrets, c.Notifications.Secret, c.Unsubscribe.Secret, c.Tracking.Secret)
+
+	return redact.New(c.Redaction.Fields, secrets)
 }
 
 func (c *Config) validate() error {
+	if _, err := logging.ParseFormat(c.LogFormat); err != nil {
+		return err
+	}
+	switch api.AuthMode(c.APIConfig.AuthMode) {
+	case "", api.AuthModeKey, api.AuthModeHMAC, api.AuthModeAny:
+	default:
+		return fmt.Errorf("unknown api auth-mode %s", c.APIConfig.AuthMode)
+	}
+	if c.RateLimit.API.Enabled && (c.RateLimit.API.RPS <= 0 || c.RateLimit.API.Burst <= 0) {
+		return fmt.Errorf("rate-limit.api requires positive rps and burst")
+	}
+	if c.RateLimit.Recipient.Enabled && (c.RateLimit.Recipient.Limit <= 0 || c.RateLimit.Recipient.Window <= 0) {
+		return fmt.Errorf("rate-limit.recipient requires positive limit and window")
+	}
+	switch c.Tracing.Exporter {
+	case "", tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP:
+	default:
+		return fmt.Errorf("unknown tracing exporter %s", c.Tracing.Exporter)
+	}
+	if c.Notifications.Enabled && c.Notifications.Secret == "" {
+		return fmt.Errorf("notifications require secret")
+	}
+	if c.Sendgrid.Webhook.Enabled {
+		if _, err := sendgrid.NewEventVerifier(c.Sendgrid.Webhook.PublicKey, c.Sendgrid.Webhook.MaxAge); err != nil {
+			return fmt.Errorf("sendgrid.webhook: %w", err)
+		}
+	}
+	if err := c.Templates.validateQuietHours(c.Scheduler.Enabled); err != nil {
+		return err
+	}
+	if err := c.Templates.validatePriorities(); err != nil {
+		return err
+	}
+	if err := c.Unsubscribe.validate(c.Suppression.Enabled); err != nil {
+		return err
+	}
+	for name, tmplCfg := range c.Templates.Options {
+		if tmplCfg.Marketing && !c.Unsubscribe.Enabled {
+			return fmt.Errorf("templates.options.%s.marketing requires unsubscribe enabled", name)
+		}
+	}
+	if err := c.Preferences.validate(c.Unsubscribe.Enabled, c.Templates.Options); err != nil {
+		return err
+	}
+	if err := c.Tracking.validate(c.APIConfig.Enabled); err != nil {
+		return err
+	}
+	if err := c.Templates.validateTracking(c.Tracking.Enabled); err != nil {
+		return err
+	}
+	if c.History.RetentionDays < 0 || c.History.BodyDays < 0 {
+		return fmt.Errorf("history retention-days and body-days can't be negative")
+	}
+	for priority, weight := range c.Queue.Weights {
+		if p := pkg.Priority(priority); p == "" || !p.Valid() || weight <= 0 {
+			return fmt.Errorf("queue weights require known priority and positive weight, got %s: %d", priority, weight)
+		}
+	}
+	names := map[string]bool{}
+	if c.APIConfig.APIKey != "" {
+		names[legacyKeyName] = true
+	}
+	for _, key := range c.APIConfig.Keys {
+		if key.Name == "" || key.Key == "" {
+			return fmt.Errorf("api key requires name and key")
+		}
+		if names[key.Name] {
+			if key.Name == legacyKeyName {
+				return fmt.Errorf("api key name %s is taken by api-key", key.Name)
+			}
+			return fmt.Errorf("api key %s is duplicated", key.Name)
+		}
+		names[key.Name] = true
+		if key.Webhook.URL != "" {
+			if !c.Notifications.Enabled {
+				return fmt.Errorf("api key %s: webhook requires notifications enabled", key.Name)
+			}
+			if err := pkg.ValidateCallbackURL(key.Webhook.URL); err != nil {
+				return fmt.Errorf("api key %s: incorrect webhook url", key.Name)
+			}
+		}
+		for _, scope := range key.Scopes {
+			if _, err := auth.ParseScope(scope); err != nil {
+				return fmt.Errorf("api key %s: %w", key.Name, err)
+			}
+		}
+	}
+
 	return nil
 }
 
 type apiConfig struct {
-	APIKey  string `yaml:"api-key"`
+	// APIKey is the legacy key with all scopes.
+	APIKey  string         `yaml:"api-key"`
+	Keys    []apiKeyConfig `yaml:"keys"`
+	Port    string         `yaml:"port"`
+	Enabled bool           `yaml:"enabled"`
+	// AuthMode is one of key, hmac, any.
+	AuthMode   string        `yaml:"auth-mode"`
+	HMACWindow time.Duration `yaml:"hmac-window"`
+	// QueryKey accepts deprecated api_key query parameter, keys in URLs leak to logs of proxies.
+	// Accepted if not set, so callers using it keep working until they migrate to headers.
+	QueryKey *bool `yaml:"query-key"`
+}
+
+// queryKey reports whether api_key query parameter is accepted, true if not set.
+func (c *apiConfig) queryKey() bool {
+	return c.QueryKey == nil || *c.QueryKey
+}
+
+type apiKeyConfig struct {
+	Name      string   `yaml:"name"`
+	Key       string   `yaml:"key"`
+	Scopes    []string `yaml:"scopes"`
+	Templates []string `yaml:"templates"`
+	// Webhook receives status changes of emails sent by the key.
+	Webhook keyWebhookConfig `yaml:"webhook"`
+}
+
+type unsubscribeConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Secret  string `yaml:"secret"`
+	// BaseURL is the public URL of the api serving unsubscribe links.
+	BaseURL string `yaml:"base-url"`
+}
+
+func (c *unsubscribeConfig) validate(suppressionEnabled bool) error {
+	if !c.Enabled {
+		return nil
+	}
+	if !suppressionEnabled {
+		return fmt.Errorf("unsubscribe requires suppression enabled")
+	}
+	if c.Secret == "" {
+		return fmt.Errorf("unsubscribe requires secret")
+	}
+	if u, err := url.Parse(c.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("unsubscribe requires absolute base-url, got '%s'", c.BaseURL)
+	}
+
+	return nil
+}
+
+type trackingConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Secret  string `yaml:"secret"`
+	// BaseURL is the public URL of the api serving redirects and open pixel.
+	BaseURL string `yaml:"base-url"`
+	// Exclude lists link prefixes never rewritten.
+	Exclude []string `yaml:"exclude"`
+}
+
+func (c *trackingConfig) validate(apiEnabled bool) error {
+	if !c.Enabled {
+		return nil
+	}
+	if !apiEnabled {
+		return fmt.Errorf("tracking requires api enabled")
+	}
+	if c.Secret == "" {
+		return fmt.Errorf("tracking requires secret")
+	}
+	if u, err := url.Parse(c.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("tracking requires absolute base-url, got '%s'", c.BaseURL)
+	}
+
+	return nil
+}
+
+type preferencesConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Path is the bbolt file keeping preferences, they are kept in memory if empty.
+	Path string `yaml:"path"`
+	// Template renders the hosted preference page.
+	Template   string           `yaml:"template"`
+	Categories []categoryConfig `yaml:"categories"`
+}
+
+type categoryConfig struct {
+	Name  string `yaml:"name"`
+	Title string `yaml:"title"`
+	// Transactional categories can't be opted out.
+	Transactional bool `yaml:"transactional"`
+}
+
+func (c *preferencesConfig) validate(unsubscribeEnabled bool, templates map[string]templateConfig) error {
+	if !c.Enabled {
+		return nil
+	}
+	if !unsubscribeEnabled {
+		return fmt.Errorf("preferences require unsubscribe enabled")
+	}
+	if c.Template == "" {
+		return fmt.Errorf("preferences require template")
+	}
+	categories := map[string]categoryConfig{}
+	for _, category := range c.Categories {
+		if category.Name == "" {
+			return fmt.Errorf("preferences categories require name")
+		}
+		if _, ok := categories[category.Name]; ok {
+			return fmt.Errorf("preferences category %s is duplicated", category.Name)
+		}
+		categories[category.Name] = category
+	}
+	for name, tmplCfg := range templates {
+		if tmplCfg.Category == "" {
+			continue
+		}
+		category, ok := categories[tmplCfg.Category]
+		if !ok {
+			return fmt.Errorf("templates.options.%s.category %s is not in preferences categories", name, tmplCfg.Category)
+		}
+		if category.Transactional && tmplCfg.Marketing {
+			return fmt.Errorf("templates.options.%s: marketing template can't be in transactional category", name)
+		}
+	}
+
+	return nil
+}
+
+// categories returns configured categories in the order of configuration.
+func (c *preferencesConfig) categories() []preference.Category {
+	res := make([]preference.Category, 0, len(c.Categories))
+	for _, category := range c.Categories {
+		title := category.Title
+		if title == "" {
+			title = category.Name
+		}
+		res = append(res, preference.Category{Name: category.Name, Title: title, Transactional: category.Transactional})
+	}
+
+	return res
+}
+
+type keyWebhookConfig struct {
+	URL string `yaml:"url"`
+	// Secret signs deliveries, notifications secret is used if empty.
+	Secret string `yaml:"secret"`
+}
+
+type notificationsConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Secret signs callback URLs of requests and webhooks without own secret.
+	Secret       string        `yaml:"secret"`
+	MaxRetries   *int          `yaml:"max-retries"`
+	RetryBackoff time.Duration `yaml:"retry-backoff"`
+	Timeout      time.Duration `yaml:"timeout"`
+	Concurrency  int           `yaml:"concurrency"`
+	// LogSize is count of delivery attempts kept for the admin endpoint.
+	LogSize int `yaml:"log-size"`
+	// PersistPath is the file for events not delivered before shutdown deadline,
+	// they are posted on the next start. Events are lost if empty.
+	PersistPath string `yaml:"persist-path"`
+	// AllowPrivateCallbacks lets callback URLs of requests reach loopback, private and link-local addresses.
+	AllowPrivateCallbacks bool `yaml:"allow-private-callbacks"`
+}
+
+type grpcConfig struct {
 	Port    string `yaml:"port"`
 	Enabled bool   `yaml:"enabled"`
 }
 
+type metricsConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Port serves /metrics separately, API port is used if empty.
+	Port string `yaml:"port"`
+}
+
+type queueConfig struct {
+	Capacity int `yaml:"capacity"`
+	// PushTimeout is how long requests wait for space in the full queue, 0 - reject immediately.
+	PushTimeout *time.Duration `yaml:"push-timeout"`
+	// Weights are shares of dequeued emails per priority lane.
+	Weights map[string]int `yaml:"weights"`
+}
+
+type suppressionConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Path is the bbolt file keeping suppressions, they are kept in memory if empty.
+	Path string `yaml:"path"`
+}
+
+type historyConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Path is the bbolt file keeping history, it is kept in memory if empty.
+	Path          string `yaml:"path"`
+	RetentionDays int    `yaml:"retention-days"`
+	// BodyDays keeps rendered bodies, 0 - bodies are not stored.
+	BodyDays int `yaml:"body-days"`
+}
+
+type schedulerConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Path is the bbolt file keeping scheduled emails, they are kept in memory if empty.
+	Path string `yaml:"path"`
+	// Interval is how often due emails are pushed to the queue.
+	Interval time.Duration `yaml:"interval"`
+	// MaxDelay is how far in the future emails may be scheduled.
+	MaxDelay time.Duration `yaml:"max-delay"`
+}
+
+type shutdownConfig struct {
+	// Timeout is the deadline to stop APIs, drain the queue and finish in-flight sends.
+	Timeout time.Duration `yaml:"timeout"`
+	// PersistPath is the file for emails left in the queue on deadline,
+	// they are restored on the next start. Emails are lost if empty.
+	PersistPath string `yaml:"persist-path"`
+}
+
+type healthConfig struct {
+	// MaxQueueDepth makes service not ready when exceeded, 0 - unlimited.
+	MaxQueueDepth int `yaml:"max-queue-depth"`
+	// CredentialsTTL is how long result of sendgrid credentials check is cached.
+	CredentialsTTL time.Duration `yaml:"credentials-ttl"`
+}
+
+type redactionConfig struct {
+	// Fields are template parameter names masked in logs.
+	Fields []string `yaml:"fields"`
+}
+
+type tracingConfig struct {
+	// Exporter is one of none, stdout, otlp.
+	Exporter    string  `yaml:"exporter"`
+	Endpoint    string  `yaml:"endpoint"`
+	Insecure    bool    `yaml:"insecure"`
+	SampleRatio float64 `yaml:"sample-ratio"`
+}
+
+type rateLimitConfig struct {
+	API       apiRateLimitConfig       `yaml:"api"`
+	Recipient recipientRateLimitConfig `yaml:"recipient"`
+}
+
+type apiRateLimitConfig struct {
+	Enabled bool    `yaml:"enabled"`
+	RPS     float64 `yaml:"rps"`
+	Burst   int     `yaml:"burst"`
+}
+
+type recipientRateLimitConfig struct {
+	Enabled bool          `yaml:"enabled"`
+	Limit   int           `yaml:"limit"`
+	Window  time.Duration `yaml:"window"`
+}
+
+type templatesConfig struct {
+	// DefaultLocale is used in strict mode for emails without locale and for keys missing in the email locale.
+	DefaultLocale string                    `yaml:"default-locale"`
+	Strict        bool                      `yaml:"strict"`
+	DebugRender   bool                      `yaml:"debug-render"`
+	QuietHours    *quietHoursConfig         `yaml:"quiet-hours"`
+	Priority      string                    `yaml:"priority"`
+	TrackClicks   bool                      `yaml:"track-clicks"`
+	TrackOpens    bool                      `yaml:"track-opens"`
+	Options       map[string]templateConfig `yaml:"options"`
+}
+
+func (c *templatesConfig) validateTracking(trackingEnabled bool) error {
+	if trackingEnabled {
+		return nil
+	}
+	if c.TrackClicks || c.TrackOpens {
+		return fmt.Errorf("templates.track-clicks and track-opens require tracking enabled")
+	}
+	for name, tmplCfg := range c.Options {
+		if (tmplCfg.TrackClicks != nil && *tmplCfg.TrackClicks) || (tmplCfg.TrackOpens != nil && *tmplCfg.TrackOpens) {
+			return fmt.Errorf("templates.options.%s: track-clicks and track-opens require tracking enabled", name)
+		}
+	}
+
+	return nil
+}
+
+func (c *templatesConfig) validatePriorities() error {
+	if !pkg.Priority(c.Priority).Valid() {
+		return fmt.Errorf("templates.priority: unknown priority %s", c.Priority)
+	}
+	for name, tmplCfg := range c.Options {
+		if tmplCfg.Priority != nil && !pkg.Priority(*tmplCfg.Priority).Valid() {
+			return fmt.Errorf("templates.options.%s.priority: unknown priority %s", name, *tmplCfg.Priority)
+		}
+	}
+
+	return nil
+}
+
+func (c *templatesConfig) validateQuietHours(schedulerEnabled bool) error {
+	policies := map[string]*quietHoursConfig{"templates": c.QuietHours}
+	for name, tmplCfg := range c.Options {
+		policies["templates.options."+name] = tmplCfg.QuietHours
+	}
+	for name, policy := range policies {
+		quietHours, err := policy.parse()
+		if err != nil {
+			return fmt.Errorf("%s.quiet-hours: %w", name, err)
+		}
+		if quietHours != nil && !schedulerEnabled {
+			return fmt.Errorf("%s.quiet-hours require enabled scheduler", name)
+		}
+	}
+
+	return nil
+}
+
+type templateConfig struct {
+	Strict      *bool `yaml:"strict"`
+	DebugRender *bool `yaml:"debug-render"`
+	// QuietHours overrides default policy, empty start and end disable it.
+	QuietHours *quietHoursConfig `yaml:"quiet-hours"`
+	Priority   *string           `yaml:"priority"`
+	Category   string            `yaml:"category"`
+	// Marketing emails get one-click List-Unsubscribe headers.
+	Marketing   bool  `yaml:"marketing"`
+	TrackClicks *bool `yaml:"track-clicks"`
+	TrackOpens  *bool `yaml:"track-opens"`
+}
+
+type quietHoursConfig struct {
+	// Start and End are local times like 22:00.
+	Start string `yaml:"start"`
+	End   string `yaml:"end"`
+	// Timezone is used when recipient timezone is not set in the request, UTC if empty.
+	Timezone string `yaml:"timezone"`
+}
+
+// parse returns nil if quiet hours are not configured or disabled.
+func (c *quietHoursConfig) parse() (*templatemanager.QuietHours, error) {
+	if c == nil || (c.Start == "" && c.End == "") {
+		return nil, nil
+	}
+	start, err := time.Parse("15:04", c.Start)
+	if err != nil {
+		return nil, fmt.Errorf("incorrect start: %w", err)
+	}
+	end, err := time.Parse("15:04", c.End)
+	if err != nil {
+		return nil, fmt.Errorf("incorrect end: %w", err)
+	}
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("incorrect timezone: %w", err)
+	}
+
+	return &templatemanager.QuietHours{
+		Start:    time.Duration(start.Hour())*time.Hour + time.Duration(start.Minute())*time.Minute,
+		End:      time.Duration(end.Hour())*time.Hour + time.Duration(end.Minute())*time.Minute,
+		Location: loc,
+	}, nil
+}
+
 type sendgridConfig struct {
-	PrivateToken string `yaml:"private-token"`
-	Enabled      bool   `yaml:"enabled"`
-	SandBox      bool   `yaml:"sand-box"`
+	PrivateToken string        `yaml:"private-token"`
+	Enabled      bool          `yaml:"enabled"`
+	SandBox      bool          `yaml:"sand-box"`
+	Concurrency  int           `yaml:"concurrency"`
+	RPS          float64       `yaml:"rps"`
+	MaxRetries   *int          `yaml:"max-retries"`
+	RetryBackoff time.Duration `yaml:"retry-backoff"`
+	Webhook      webhookConfig `yaml:"webhook"`
+}
+
+type webhookConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// PublicKey is the verification key from SendGrid signed Event Webhook settings.
+	PublicKey string `yaml:"public-key"`
+	// MaxAge rejects events signed earlier, 0 - no limit.
+	MaxAge time.Duration `yaml:"max-age"`
 }
 
 func NewConfig(args []string) (*Config, error) {
@@ -0,0 +1,43 @@
+package container
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateKeyNames(t *testing.T) {
+	tests := []struct {
+		name   string
+		config apiConfig
+		err    string
+	}{
+		{"unique", apiConfig{APIKey: "legacy", Keys: []apiKeyConfig{{Name: "shop", Key: "s1"}, {Name: "blog", Key: "s2"}}}, ""},
+		{"duplicated", apiConfig{Keys: []apiKeyConfig{{Name: "shop", Key: "s1"}, {Name: "shop", Key: "s2"}}}, "api key shop is duplicated"},
+		{"legacy name", apiConfig{APIKey: "legacy", Keys: []apiKeyConfig{{Name: legacyKeyName, Key: "s1"}}}, "taken by api-key"},
+		// the name is free without api-key.
+		{"legacy name without api-key", apiConfig{Keys: []apiKeyConfig{{Name: legacyKeyName, Key: "s1"}}}, ""},
+	}
+	for _, tt := range tests {
+		cfg := Config{APIConfig: tt.config}
+		err := cfg.validate()
+		if tt.err == "" && err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+		if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%s error = %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestQueryKeyDefault(t *testing.T) {
+	enabled, disabled := true, false
+	for _, tt := range []struct {
+		value *bool
+		want  bool
+	}{{nil, true}, {&enabled, true}, {&disabled, false}} {
+		cfg := apiConfig{QueryKey: tt.value}
+		if got := cfg.queryKey(); got != tt.want {
+			t.Errorf("queryKey of %v = %t, want %t", tt.value, got, tt.want)
+		}
+	}
+}
@@ -7,51 +7,421 @@ import (
 	"net"
 	"os"
 	"runtime/debug"
+	"sync"
+	"time"
 
+	"github.com/pralolik/templgrid/pkg"
 	"github.com/pralolik/templgrid/src/api"
+	"github.com/pralolik/templgrid/src/api/lib/health"
+	"github.com/pralolik/templgrid/src/auth"
 	"github.com/pralolik/templgrid/src/generator"
 	"github.com/pralolik/templgrid/src/generator/input"
 	"github.com/pralolik/templgrid/src/generator/output"
+	"github.com/pralolik/templgrid/src/grpcapi"
+	"github.com/pralolik/templgrid/src/history"
 	"github.com/pralolik/templgrid/src/logging"
+	"github.com/pralolik/templgrid/src/metrics"
+	"github.com/pralolik/templgrid/src/notify"
+	"github.com/pralolik/templgrid/src/preference"
 	"github.com/pralolik/templgrid/src/queue"
+	"github.com/pralolik/templgrid/src/ratelimit"
+	"github.com/pralolik/templgrid/src/redact"
+	"github.com/pralolik/templgrid/src/scheduler"
 	"github.com/pralolik/templgrid/src/sendgrid"
+	"github.com/pralolik/templgrid/src/status"
+	"github.com/pralolik/templgrid/src/suppression"
 	"github.com/pralolik/templgrid/src/templatemanager"
+	"github.com/pralolik/templgrid/src/tracing"
+	"github.com/pralolik/templgrid/src/tracking"
+	"github.com/pralolik/templgrid/src/unsubscribe"
 )
 
+const (
+	defCredentialsTTL  = 5 * time.Minute
+	defShutdownTimeout = 25 * time.Second
+	day                = 24 * time.Hour
+)
+
+var errShuttingDown = errors.New("shutting down")
+
 type AppContainer struct {
 	Log          logging.Logger
 	Config       *Config
 	EmailStorage *templatemanager.EmailStorage
+	Statuses     status.Store
+	Auth         auth.Authenticator
+	// APILimiter is shared by all ingestion APIs, nil if disabled.
+	APILimiter *ratelimit.KeyLimiter
+	// Metrics is nil if disabled.
+	Metrics  *metrics.Metrics
+	Redactor *redact.Redactor
+	// Health holds readiness checks of all components.
+	Health *health.ComponentChecker
+	// Scheduler is nil if scheduled delivery is disabled.
+	Scheduler *scheduler.Scheduler
+	// Suppressions is nil if suppression list is disabled.
+	Suppressions suppression.Store
+	// Notifier wraps Statuses if notifications are enabled, nil otherwise.
+	Notifier *notify.Notifier
+	// Unsubscribe is nil if unsubscribe links are disabled.
+	Unsubscribe *unsubscribe.Signer
+	// Preferences is nil if preference center is disabled.
+	Preferences *preference.Center
+	// Tracker is nil if open and click tracking is disabled.
+	Tracker *tracking.Tracker
+	// History is nil if send history is disabled.
+	History *history.History
+
+	schedulerStore  scheduler.Store
+	preferenceStore preference.Store
+	historyStore    history.Store
+	shutdownTracing func(ctx context.Context) error
 }
 
 func NewAppContainer(config *Config, log logging.Logger) (*AppContainer, error) {
 	emailStorage := templatemanager.NewEmailStorage()
+	setTemplateOptions(config, emailStorage)
 	gen := generator.New(getInput(log), getOutputs(config, log, emailStorage), log)
 	if err := gen.Generate(); err != nil {
 		return nil, fmt.Errorf("can't create app container: %w ", err)
 	}
 
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:    config.Tracing.Exporter,
+		Endpoint:    config.Tracing.Endpoint,
+		Insecure:    config.Tracing.Insecure,
+		SampleRatio: config.Tracing.SampleRatio,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("can't setup tracing: %w ", err)
+	}
+
+	hc := health.NewComponentChecker()
+	hc.Add("storage", emailStorage)
+
+	var statuses status.Store = status.NewMemoryStore(status.DefTTL)
+	notifier := getNotifier(config, log, statuses)
+	if notifier != nil {
+		statuses = notifier
+	}
+	schedulerStore, err := getSchedulerStore(config)
+	if err != nil {
+		return nil, fmt.Errorf("can't create scheduler: %w ", err)
+	}
+	suppressions, err := getSuppressions(config)
+	if err != nil {
+		return nil, fmt.Errorf("can't create suppression store: %w ", err)
+	}
+	preferenceStore, err := getPreferenceStore(config, emailStorage)
+	if err != nil {
+		return nil, fmt.Errorf("can't create preference store: %w ", err)
+	}
+	historyStore, err := getHistoryStore(config)
+	if err != nil {
+		return nil, fmt.Errorf("can't create history store: %w ", err)
+	}
+	var hist *history.History
+	if historyStore != nil {
+		hist = history.New(
+			log,
+			historyStore,
+			history.WithRetention(time.Duration(config.History.RetentionDays)*day),
+			history.WithBodyRetention(time.Duration(config.History.BodyDays)*day),
+		)
+	}
+	var preferences *preference.Center
+	if preferenceStore != nil {
+		preferences = preference.NewCenter(preferenceStore, config.Preferences.categories())
+	}
+	var sch *scheduler.Scheduler
+	if schedulerStore != nil {
+		sch = scheduler.New(
+			log,
+			schedulerStore,
+			scheduler.WithStatusStore(statuses),
+			scheduler.WithInterval(config.Scheduler.Interval),
+			scheduler.WithMaxDelay(config.Scheduler.MaxDelay),
+		)
+	}
+
 	return &AppContainer{
 		Config:       config,
 		Log:          log,
 		EmailStorage: emailStorage,
+		Statuses:     statuses,
+		Auth:         getAuth(config, log),
+		APILimiter:   getAPILimiter(config),
+		Metrics:      getMetrics(config),
+		Redactor:     config.Redactor(),
+		Health:       hc,
+		Scheduler:    sch,
+		Suppressions: suppressions,
+		Notifier:     notifier,
+		Unsubscribe:  getUnsubscribe(config),
+		Preferences:  preferences,
+		Tracker:      getTracker(config),
+		History:      hist,
+
+		schedulerStore:  schedulerStore,
+		preferenceStore: preferenceStore,
+		historyStore:    historyStore,
+		shutdownTracing: shutdownTracing,
 	}, nil
 }
 
+// Close flushes telemetry and closes stores, should be called after Run returns.
+func (cnt *AppContainer) Close(ctx context.Context) {
+	if cnt.schedulerStore != nil {
+		if err := cnt.schedulerStore.Close(); err != nil {
+			cnt.Log.Error("can't close scheduler store: %v ", err)
+		}
+	}
+	if cnt.Suppressions != nil {
+		if err := cnt.Suppressions.Close(); err != nil {
+			cnt.Log.Error("can't close suppression store: %v ", err)
+		}
+	}
+	if cnt.preferenceStore != nil {
+		if err := cnt.preferenceStore.Close(); err != nil {
+			cnt.Log.Error("can't close preference store: %v ", err)
+		}
+	}
+	if cnt.historyStore != nil {
+		if err := cnt.historyStore.Close(); err != nil {
+			cnt.Log.Error("can't close history store: %v ", err)
+		}
+	}
+	if err := cnt.shutdownTracing(ctx); err != nil {
+		cnt.Log.Error("can't shutdown tracing: %v ", err)
+	}
+}
+
+// Run starts all components and blocks until ctx is done and shutdown is finished.
 func (cnt *AppContainer) Run(ctx context.Context) {
-	defer cnt.recover(func(_ error) {
-		cnt.Run(ctx)
-	})
 	q := cnt.createQueue()
+	cnt.Health.Add("queue", queue.NewDepthChecker(q, cnt.Config.Health.MaxQueueDepth))
+
+	// senders outlive ctx to drain the queue, sendCtx is cancelled on shutdown deadline only.
+	sendCtx, cancelSend := context.WithCancel(context.Background())
+	defer cancelSend()
+	var ingestion, senders sync.WaitGroup
 	cnt.runQueue(ctx, q)
-	cnt.runSendGrid(ctx, q)
-	cnt.runAPI(ctx, q)
+	cnt.restoreNotifications()
+	cnt.runNotifier(sendCtx)
+	cnt.runHistory(ctx)
+	s := cnt.createSendGrid()
+	if s != nil {
+		cnt.runSendGrid(sendCtx, q, s, &senders)
+	}
+	cnt.restoreScheduled()
+	cnt.restoreQueue(q)
+	cnt.runScheduler(ctx, q, &ingestion)
+	cnt.runAPI(ctx, q, &ingestion)
+	cnt.runGRPC(ctx, q, &ingestion)
+	cnt.runMetrics(ctx)
 	<-ctx.Done()
-	if ctx.Err() != nil {
-		cnt.Log.Error("AppContainer shutdown with error: %v ", ctx.Err())
+
+	cnt.shutdown(q, s, &ingestion, &senders, cancelSend)
+}
+
+// shutdown stops ingestion APIs first, then drains the queue and waits for in-flight sends
+// within the configured deadline. Emails left in the queue or in the memory scheduler and webhook events
+// which are not delivered are persisted if configured.
+func (cnt *AppContainer) shutdown(
+	q queue.Interface,
+	s *sendgrid.SendGrid,
+	ingestion, senders *sync.WaitGroup,
+	cancelSend context.CancelFunc) {
+	timeout := cnt.Config.Shutdown.Timeout
+	if timeout <= 0 {
+		timeout = defShutdownTimeout
+	}
+	cnt.Log.Info("AppContainer shutdown started, deadline %s", timeout)
+	cnt.Health.Add("shutdown", health.CheckerFunc(func(context.Context) error { return errShuttingDown }))
+	deadline, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if !waitGroup(deadline, ingestion) {
+		cnt.Log.Error("Ingestion APIs are not stopped before shutdown deadline")
+	}
+
+	drainCtx := deadline
+	if s == nil {
+		// nobody consumes the queue, so there is nothing to wait for.
+		var drainCancel context.CancelFunc
+		drainCtx, drainCancel = context.WithCancel(deadline)
+		drainCancel()
+	}
+	leftovers := q.Close(drainCtx)
+
+	if !waitGroup(deadline, senders) {
+		cnt.Log.Error("In-flight sends are aborted on shutdown deadline")
+		cancelSend()
+		senders.Wait()
+	}
+	if memStore, ok := cnt.schedulerStore.(*scheduler.MemoryStore); ok {
+		// senders are stopped, so emails deferred by quiet hours while the queue was drained are included.
+		// Scheduled emails keep send time, so they are scheduled again on restore.
+		leftovers = append(leftovers, memStore.Drain()...)
+	}
+	persisted := cnt.persistQueue(leftovers)
+	cnt.closeNotifier(deadline)
+
+	var stats sendgrid.Stats
+	if s != nil {
+		stats = s.Stats()
+	}
+	cnt.Log.Info(
+		"AppContainer shutdown: sent %d, failed %d, dropped %d, persisted %d, lost %d",
+		stats.Sent,
+		stats.Failed,
+		stats.Dropped,
+		persisted,
+		len(leftovers)-persisted)
+}
+
+// closeNotifier delivers webhook events of sent emails until ctx is done,
+// events left are persisted if configured.
+func (cnt *AppContainer) closeNotifier(ctx context.Context) {
+	if cnt.Notifier == nil {
+		return
+	}
+	pending := cnt.Notifier.Close(ctx)
+	if len(pending) == 0 {
+		return
+	}
+	path := cnt.Config.Notifications.PersistPath
+	if path == "" {
+		cnt.Log.Error("%d webhook events are not delivered before shutdown deadline and lost", len(pending))
+		return
+	}
+	if err := notify.SaveFile(path, pending); err != nil {
+		cnt.Log.Error("can't persist %d webhook events: %v ", len(pending), err)
+		return
+	}
+	cnt.Log.Info("%d webhook events persisted to %s", len(pending), path)
+}
+
+// restoreNotifications queues webhook events persisted on previous shutdown.
+func (cnt *AppContainer) restoreNotifications() {
+	path := cnt.Config.Notifications.PersistPath
+	if cnt.Notifier == nil || path == "" {
+		return
+	}
+	pending, err := notify.LoadFile(path)
+	if err != nil {
+		cnt.Log.Error("can't restore webhook events: %v ", err)
+	}
+	if len(pending) == 0 {
+		return
+	}
+	if err = os.Remove(path); err != nil {
+		cnt.Log.Error("can't remove restored webhook events file, events may be posted twice: %v ", err)
 		return
 	}
-	cnt.Log.Info("AppContainer shutdown")
+	cnt.Notifier.Restore(pending)
+	cnt.Log.Info("%d webhook events restored from %s", len(pending), path)
+}
+
+// restoreScheduled recreates statuses and webhook targets of emails kept by the scheduler store since the previous start,
+// callback URL and API key of the target are stored with the email.
+func (cnt *AppContainer) restoreScheduled() {
+	if cnt.Scheduler == nil {
+		return
+	}
+	restored := 0
+	err := cnt.Scheduler.Walk(func(email *pkg.TemplgridEmailEntity) error {
+		if err := cnt.Statuses.Create(email.ID, email.TemplateName, email.KeyName); err != nil {
+			cnt.Log.Error("can't create status of scheduled email %s: %v ", email.ID, err)
+			return nil
+		}
+		if cnt.Notifier != nil {
+			cnt.Notifier.Watch(email)
+		}
+		_ = cnt.Statuses.SetState(email.ID, status.Scheduled, nil)
+		restored++
+		return nil
+	})
+	if err != nil {
+		cnt.Log.Error("can't restore scheduled emails: %v ", err)
+	}
+	if restored > 0 {
+		cnt.Log.Info("%d scheduled emails restored", restored)
+	}
+}
+
+// restoreQueue pushes emails persisted on previous shutdown, emails due in the future are scheduled.
+func (cnt *AppContainer) restoreQueue(q queue.Interface) {
+	path := cnt.Config.Shutdown.PersistPath
+	if path == "" {
+		return
+	}
+	emails, err := queue.LoadFile(path)
+	if err != nil {
+		cnt.Log.Error("can't restore queue: %v ", err)
+	}
+	if len(emails) == 0 {
+		return
+	}
+	var rejected []*pkg.TemplgridEmailEntity
+	for _, email := range emails {
+		if err = cnt.Statuses.Create(email.ID, email.TemplateName, email.KeyName); err != nil {
+			cnt.Log.Error("can't create status of restored email %s: %v ", email.ID, err)
+		}
+		if cnt.Notifier != nil {
+			cnt.Notifier.Watch(email)
+		}
+		if cnt.Scheduler != nil && email.SendAt != nil && email.SendAt.After(time.Now()) {
+			_ = cnt.Statuses.SetState(email.ID, status.Scheduled, nil)
+			err = cnt.Scheduler.Schedule(email)
+		} else {
+			err = q.Push(email)
+		}
+		if err != nil {
+			cnt.Log.Error("can't push restored email %s: %v ", email.ID, err)
+			rejected = append(rejected, email)
+		}
+	}
+	if err = os.Remove(path); err != nil {
+		cnt.Log.Error("can't remove restored queue file, emails may be sent twice: %v ", err)
+		return
+	}
+	// rejected emails are kept for the next start.
+	if len(rejected) > 0 {
+		if err = queue.SaveFile(path, rejected); err != nil {
+			cnt.Log.Error("can't persist rejected emails: %v ", err)
+		}
+	}
+	cnt.Log.Info("%d emails restored from %s, %d kept", len(emails)-len(rejected), path, len(rejected))
+}
+
+// persistQueue saves emails left in the queue, returns count of saved emails.
+func (cnt *AppContainer) persistQueue(emails []*pkg.TemplgridEmailEntity) int {
+	path := cnt.Config.Shutdown.PersistPath
+	if len(emails) == 0 || path == "" {
+		return 0
+	}
+	if err := queue.SaveFile(path, emails); err != nil {
+		cnt.Log.Error("can't persist queue: %v ", err)
+		return 0
+	}
+
+	return len(emails)
+}
+
+// waitGroup waits for wg until ctx is done, returns false on ctx done.
+func waitGroup(ctx context.Context, wg *sync.WaitGroup) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
 }
 
 func (cnt *AppContainer) runQueue(ctx context.Context, q queue.Interface) {
@@ -64,18 +434,122 @@ func (cnt *AppContainer) runQueue(ctx context.Context, q queue.Interface) {
 	}()
 }
 
+func (cnt *AppContainer) runScheduler(ctx context.Context, q queue.Interface, wg *sync.WaitGroup) {
+	if cnt.Scheduler == nil {
+		return
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer cnt.recover(func(_ error) { cnt.runScheduler(ctx, q, wg) })()
+		if err := cnt.Scheduler.Run(ctx, q); err != nil {
+			cnt.Log.Error("scheduler error: %v ", err)
+			panic(err)
+		}
+	}()
+}
+
+// runNotifier posts webhook events until ctx is done or the notifier is closed on shutdown,
+// it outlives ingestion to notify drained emails.
+func (cnt *AppContainer) runNotifier(ctx context.Context) {
+	if cnt.Notifier == nil {
+		return
+	}
+	go func() {
+		defer cnt.recover(func(_ error) { cnt.runNotifier(ctx) })()
+		if err := cnt.Notifier.Run(ctx); err != nil {
+			cnt.Log.Error("notifier error: %v ", err)
+			panic(err)
+		}
+	}()
+}
+
+// runHistory prunes expired history records until ctx is done.
+func (cnt *AppContainer) runHistory(ctx context.Context) {
+	if cnt.History == nil {
+		return
+	}
+	go func() {
+		defer cnt.recover(func(_ error) { cnt.runHistory(ctx) })()
+		if err := cnt.History.Run(ctx); err != nil {
+			cnt.Log.Error("history error: %v ", err)
+			panic(err)
+		}
+	}()
+}
+
 func (cnt *AppContainer) createQueue() queue.Interface {
-	return queue.NewInternalQueue(cnt.Log)
+	pushTimeout := queue.DefPushTimeout
+	if cnt.Config.Queue.PushTimeout != nil {
+		pushTimeout = *cnt.Config.Queue.PushTimeout
+	}
+	q := queue.NewInternalQueue(
+		cnt.Log,
+		queue.WithMetrics(cnt.Metrics),
+		queue.WithCapacity(cnt.Config.Queue.Capacity),
+		queue.WithPushTimeout(pushTimeout),
+		queue.WithWeights(queueWeights(cnt.Config.Queue.Weights)),
+	)
+	cnt.Metrics.RegisterQueueDepth(q.Len)
+
+	return q
 }
 
-func (cnt *AppContainer) runSendGrid(ctx context.Context, q queue.Interface) {
+func (cnt *AppContainer) createSendGrid() *sendgrid.SendGrid {
 	sgCfg := cnt.Config.Sendgrid
 	if !sgCfg.Enabled {
-		return
+		return nil
+	}
+	maxRetries := sendgrid.DefMaxRetries
+	if sgCfg.MaxRetries != nil {
+		maxRetries = *sgCfg.MaxRetries
+	}
+	options := []sendgrid.Option{
+		sendgrid.WithStatusStore(cnt.Statuses),
+		sendgrid.WithConcurrency(sgCfg.Concurrency),
+		sendgrid.WithRateLimit(sgCfg.RPS),
+		sendgrid.WithRetries(maxRetries, sgCfg.RetryBackoff),
+		sendgrid.WithMetrics(cnt.Metrics),
+		sendgrid.WithScheduler(cnt.Scheduler),
+		sendgrid.WithUnsubscribe(cnt.Unsubscribe),
+		sendgrid.WithTracker(cnt.Tracker),
+		sendgrid.WithNotifier(cnt.Notifier),
+	}
+	if cnt.History != nil {
+		options = append(options, sendgrid.WithHistory(cnt.History))
+	}
+	// suppressed recipients are dropped before they are counted by rate limit.
+	if cnt.Suppressions != nil {
+		options = append(options, sendgrid.WithRecipientFilter(suppression.NewFilter(cnt.Suppressions, cnt.EmailStorage)))
+	}
+	if cnt.Preferences != nil {
+		options = append(options, sendgrid.WithRecipientFilter(preference.NewFilter(cnt.Preferences, cnt.EmailStorage)))
 	}
-	s := sendgrid.NewSendGrid(sgCfg.PrivateToken, sgCfg.SandBox, cnt.Log, cnt.EmailStorage)
+	if rcpCfg := cnt.Config.RateLimit.Recipient; rcpCfg.Enabled {
+		options = append(options, sendgrid.WithRecipientFilter(ratelimit.NewRecipientLimiter(rcpCfg.Limit, rcpCfg.Window)))
+	}
+	s := sendgrid.NewSendGrid(
+		sgCfg.PrivateToken,
+		sgCfg.SandBox,
+		cnt.Log,
+		cnt.EmailStorage,
+		options...,
+	)
+	credentialsTTL := cnt.Config.Health.CredentialsTTL
+	if credentialsTTL <= 0 {
+		credentialsTTL = defCredentialsTTL
+	}
+	cnt.Health.Add("sender", s)
+	cnt.Health.Add("provider", health.NewCachedChecker(s.CredentialsChecker(), credentialsTTL))
+
+	return s
+}
+
+func (cnt *AppContainer) runSendGrid(ctx context.Context, q queue.Interface, s *sendgrid.SendGrid, wg *sync.WaitGroup) {
+	wg.Add(1)
 	go func() {
-		defer cnt.recover(func(_ error) { cnt.runSendGrid(ctx, q) })()
+		defer wg.Done()
+		defer cnt.recover(func(_ error) { cnt.runSendGrid(ctx, q, s, wg) })()
 		if err := s.Run(ctx, q); err != nil {
 			cnt.Log.Error("sendgrid error: %v ", err)
 			panic(err)
@@ -83,19 +557,59 @@ func (cnt *AppContainer) runSendGrid(ctx context.Context, q queue.Interface) {
 	}()
 }
 
-func (cnt *AppContainer) runAPI(ctx context.Context, q queue.Interface) {
+func (cnt *AppContainer) runAPI(ctx context.Context, q queue.Interface, wg *sync.WaitGroup) {
 	apiConfig := cnt.Config.APIConfig
+	if apiConfig.Enabled && apiConfig.QueryKey == nil {
+		cnt.Log.Info("WARNING: api.query-key is not set, deprecated api_key query parameter is accepted, " +
+			"it will be rejected by default in the next major release, send keys in headers and set query-key: false")
+	}
 	previewConfig := cnt.Config.APIConfig
-	a := api.NewServer(
-		cnt.Log,
-		api.WithAPI(apiConfig.Enabled, apiConfig.APIKey, apiConfig.Port),
+	options := []api.Option{
+		api.WithAPI(apiConfig.Enabled, apiConfig.Port),
+		api.WithAuth(cnt.Auth),
+		api.WithAuthMode(authMode(apiConfig.AuthMode), apiConfig.HMACWindow),
+		api.WithQueryKey(apiConfig.queryKey()),
 		api.WithPreview(previewConfig.Enabled, cnt.EmailStorage),
-	)
+		api.WithStatusStore(cnt.Statuses),
+		api.WithRedactor(cnt.Redactor),
+		api.WithHealth(cnt.Health),
+		api.WithScheduler(cnt.Scheduler),
+		api.WithSuppressions(cnt.Suppressions),
+		api.WithNotifier(cnt.Notifier),
+		api.WithUnsubscribe(cnt.Unsubscribe),
+		api.WithTracker(cnt.Tracker),
+	}
+	if cnt.History != nil {
+		options = append(options, api.WithHistory(cnt.History))
+	}
+	if cnt.Preferences != nil {
+		options = append(options, api.WithPreferences(cnt.Preferences, cnt.Config.Preferences.Template))
+	}
+	if cnt.APILimiter != nil {
+		options = append(options, api.WithRateLimit(cnt.APILimiter))
+	}
+	if cnt.Metrics != nil {
+		options = append(options, api.WithMetrics(cnt.Metrics, cnt.Config.Metrics.Port == ""))
+	}
+	if webhookCfg := cnt.Config.Sendgrid.Webhook; webhookCfg.Enabled {
+		// public key is checked by Config.validate
+		verifier, _ := sendgrid.NewEventVerifier(webhookCfg.PublicKey, webhookCfg.MaxAge)
+		statuses := cnt.Statuses
+		if cnt.History != nil {
+			// provider events change state of sent emails kept in history.
+			statuses = cnt.History.Watch(statuses)
+		}
+		processor := sendgrid.NewEventProcessor(cnt.Log, statuses, cnt.Suppressions)
+		options = append(options, api.WithSendGridEvents(verifier, processor))
+	}
+	a := api.NewServer(cnt.Log, options...)
+	wg.Add(1)
 	go func() {
+		defer wg.Done()
 		defer cnt.recover(func(err error) {
 			var optE *net.OpError
 			if !errors.As(err, &optE) {
-				cnt.runAPI(ctx, q)
+				cnt.runAPI(ctx, q, wg)
 			}
 			os.Exit(1)
 		})()
@@ -106,16 +620,76 @@ func (cnt *AppContainer) runAPI(ctx context.Context, q queue.Interface) {
 	}()
 }
 
+func (cnt *AppContainer) runGRPC(ctx context.Context, q queue.Interface, wg *sync.WaitGroup) {
+	grpcConfig := cnt.Config.GRPC
+	if !grpcConfig.Enabled {
+		return
+	}
+	port := grpcConfig.Port
+	if port == "" {
+		port = grpcapi.DefPort
+	}
+	keyAuth := authMode(cnt.Config.APIConfig.AuthMode) != api.AuthModeHMAC
+	if !keyAuth {
+		cnt.Log.Info("gRPC rejects API keys in hmac auth mode, requests are not signed")
+	}
+	options := []grpcapi.Option{
+		grpcapi.WithGRPC(port),
+		grpcapi.WithAuth(cnt.Auth),
+		grpcapi.WithKeyAuth(keyAuth),
+		grpcapi.WithEmailStorage(cnt.EmailStorage),
+		grpcapi.WithStatusStore(cnt.Statuses),
+		grpcapi.WithRedactor(cnt.Redactor),
+		grpcapi.WithNotifier(cnt.Notifier),
+		grpcapi.WithScheduler(cnt.Scheduler),
+	}
+	if cnt.APILimiter != nil {
+		options = append(options, grpcapi.WithRateLimit(cnt.APILimiter))
+	}
+	g := grpcapi.NewServer(cnt.Log, options...)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer cnt.recover(func(err error) {
+			var optE *net.OpError
+			if !errors.As(err, &optE) {
+				cnt.runGRPC(ctx, q, wg)
+			}
+			os.Exit(1)
+		})()
+		if err := g.Serve(ctx, q); err != nil {
+			cnt.Log.Error("grpc error: %v ", err)
+			panic(err)
+		}
+	}()
+}
+
+func (cnt *AppContainer) runMetrics(ctx context.Context) {
+	port := cnt.Config.Metrics.Port
+	if cnt.Metrics == nil || port == "" {
+		return
+	}
+	go func() {
+		cnt.Log.Info("Metrics server started to serve on: '%s'", port)
+		if err := cnt.Metrics.Serve(ctx, port); err != nil {
+			cnt.Log.Error("metrics error: %v ", err)
+		}
+	}()
+}
+
+// recover returns deferrable func calling f only if the goroutine panicked,
+// components returning normally on shutdown must not be restarted.
 func (cnt *AppContainer) recover(f func(err error)) func() {
 	return func() {
 		var err error
 		r := recover()
-		if r != nil {
-			cnt.Log.Error("AppContainer panic: %v Stack:\n%s", r, debug.Stack())
-			or, ok := r.(*net.OpError)
-			if ok {
-				err = or
-			}
+		if r == nil {
+			return
+		}
+		cnt.Log.Error("AppContainer panic: %v Stack:\n%s", r, debug.Stack())
+		or, ok := r.(*net.OpError)
+		if ok {
+			err = or
 		}
 		f(err)
 	}
@@ -128,6 +702,210 @@ func getOutputs(_ *Config, _ logging.Logger, emailStorage *templatemanager.Email
 	return otpts
 }
 
+func setTemplateOptions(config *Config, emailStorage *templatemanager.EmailStorage) {
+	// quiet hours are checked by Config.validate
+	defQuietHours, _ := config.Templates.QuietHours.parse()
+	defOpts := templatemanager.TemplateOptions{
+		Strict:      config.Templates.Strict,
+		DebugRender: config.Templates.DebugRender,
+		QuietHours:  defQuietHours,
+		Priority:    pkg.Priority(config.Templates.Priority),
+		TrackClicks: config.Templates.TrackClicks,
+		TrackOpens:  config.Templates.TrackOpens,
+	}
+	emailStorage.SetDefaultOptions(defOpts)
+	emailStorage.SetDefaultLocale(config.Templates.DefaultLocale)
+	for name, tmplCfg := range config.Templates.Options {
+		opts := defOpts
+		if tmplCfg.Strict != nil {
+			opts.Strict = *tmplCfg.Strict
+		}
+		if tmplCfg.DebugRender != nil {
+			opts.DebugRender = *tmplCfg.DebugRender
+		}
+		if tmplCfg.QuietHours != nil {
+			opts.QuietHours, _ = tmplCfg.QuietHours.parse()
+		}
+		if tmplCfg.Priority != nil {
+			opts.Priority = pkg.Priority(*tmplCfg.Priority)
+		}
+		opts.Category = tmplCfg.Category
+		opts.Marketing = tmplCfg.Marketing
+		if tmplCfg.TrackClicks != nil {
+			opts.TrackClicks = *tmplCfg.TrackClicks
+		}
+		if tmplCfg.TrackOpens != nil {
+			opts.TrackOpens = *tmplCfg.TrackOpens
+		}
+		emailStorage.AddOptions(name, opts)
+	}
+}
+
+func getAuth(config *Config, log logging.Logger) auth.Authenticator {
+	keys := make([]auth.Key, 0, len(config.APIConfig.Keys)+1)
+	if config.APIConfig.APIKey != "" {
+		keys = append(keys, auth.Key{
+			Name:   legacyKeyName,
+			Secret: config.APIConfig.APIKey,
+			Scopes: auth.AllScopes,
+		})
+	}
+	for _, keyCfg := range config.APIConfig.Keys {
+		key := auth.Key{
+			Name:      keyCfg.Name,
+			Secret:    keyCfg.Key,
+			Templates: keyCfg.Templates,
+		}
+		for _, scopeName := range keyCfg.Scopes {
+			// scopes are checked by Config.validate
+			scope, _ := auth.ParseScope(scopeName)
+			key.Scopes = append(key.Scopes, scope)
+		}
+		keys = append(keys, key)
+	}
+	ks := auth.NewKeySet(keys...)
+	if ks.Len() == 0 {
+		log.Error("No api keys configured, all authenticated requests will be rejected")
+	}
+
+	return ks
+}
+
+func getAPILimiter(config *Config) *ratelimit.KeyLimiter {
+	apiCfg := config.RateLimit.API
+	if !apiCfg.Enabled {
+		return nil
+	}
+
+	return ratelimit.NewKeyLimiter(apiCfg.RPS, apiCfg.Burst)
+}
+
+// queueWeights converts configured weights, priorities are checked by Config.validate.
+func queueWeights(weights map[string]int) map[pkg.Priority]int {
+	res := make(map[pkg.Priority]int, len(weights))
+	for priority, weight := range weights {
+		res[pkg.Priority(priority)] = weight
+	}
+
+	return res
+}
+
+// getNotifier returns nil if notifications are disabled.
+func getNotifier(config *Config, log logging.Logger, statuses status.Store) *notify.Notifier {
+	notifyCfg := config.Notifications
+	if !notifyCfg.Enabled {
+		return nil
+	}
+	endpoints := map[string]notify.Endpoint{}
+	for _, key := range config.APIConfig.Keys {
+		if key.Webhook.URL != "" {
+			endpoints[key.Name] = notify.Endpoint{URL: key.Webhook.URL, Secret: key.Webhook.Secret}
+		}
+	}
+	maxRetries := notify.DefMaxRetries
+	if notifyCfg.MaxRetries != nil {
+		maxRetries = *notifyCfg.MaxRetries
+	}
+
+	return notify.NewNotifier(
+		log,
+		statuses,
+		notify.WithEndpoints(endpoints),
+		notify.WithSecret(notifyCfg.Secret),
+		notify.WithRetries(maxRetries, notifyCfg.RetryBackoff),
+		notify.WithTimeout(notifyCfg.Timeout),
+		notify.WithConcurrency(notifyCfg.Concurrency),
+		notify.WithLogSize(notifyCfg.LogSize),
+		notify.WithPrivateCallbacks(notifyCfg.AllowPrivateCallbacks),
+	)
+}
+
+// getUnsubscribe returns nil if unsubscribe links are disabled.
+func getUnsubscribe(config *Config) *unsubscribe.Signer {
+	if !config.Unsubscribe.Enabled {
+		return nil
+	}
+
+	return unsubscribe.NewSigner(config.Unsubscribe.Secret, config.Unsubscribe.BaseURL)
+}
+
+// getTracker returns nil if open and click tracking is disabled.
+func getTracker(config *Config) *tracking.Tracker {
+	if !config.Tracking.Enabled {
+		return nil
+	}
+
+	return tracking.NewTracker(config.Tracking.Secret, config.Tracking.BaseURL, config.Tracking.Exclude)
+}
+
+// getPreferenceStore returns nil if preference center is disabled.
+// Templates should be generated before, so the page template is checked.
+func getPreferenceStore(config *Config, emailStorage *templatemanager.EmailStorage) (preference.Store, error) {
+	if !config.Preferences.Enabled {
+		return nil, nil
+	}
+	if err := emailStorage.HasEmail(config.Preferences.Template); err != nil {
+		return nil, err
+	}
+	if config.Preferences.Path == "" {
+		return preference.NewMemoryStore(), nil
+	}
+
+	return preference.NewBoltStore(config.Preferences.Path)
+}
+
+// getSuppressions returns nil if suppression list is disabled.
+func getSuppressions(config *Config) (suppression.Store, error) {
+	if !config.Suppression.Enabled {
+		return nil, nil
+	}
+	if config.Suppression.Path == "" {
+		return suppression.NewMemoryStore(), nil
+	}
+
+	return suppression.NewBoltStore(config.Suppression.Path)
+}
+
+// getHistoryStore returns nil if send history is disabled.
+func getHistoryStore(config *Config) (history.Store, error) {
+	if !config.History.Enabled {
+		return nil, nil
+	}
+	if config.History.Path == "" {
+		return history.NewMemoryStore(), nil
+	}
+
+	return history.NewBoltStore(config.History.Path)
+}
+
+// getSchedulerStore returns nil if scheduled delivery is disabled.
+func getSchedulerStore(config *Config) (scheduler.Store, error) {
+	if !config.Scheduler.Enabled {
+		return nil, nil
+	}
+	if config.Scheduler.Path == "" {
+		return scheduler.NewMemoryStore(), nil
+	}
+
+	return scheduler.NewBoltStore(config.Scheduler.Path)
+}
+
+func getMetrics(config *Config) *metrics.Metrics {
+	if !config.Metrics.Enabled {
+		return nil
+	}
+
+	return metrics.New()
+}
+
+func authMode(mode string) api.AuthMode {
+	if mode == "" {
+		return api.AuthModeKey
+	}
+
+	return api.AuthMode(mode)
+}
+
 func getInput(log logging.Logger) input.Interface {
 	return input.NewDirectoryInput(log)
 }
@@ -43,6 +43,7 @@ func (g *Generator) Generate() error {
 			Name: email.Name,
 		}
 		resource.EmailTemplate = email.EmailTemplate
+		resource.ParametersSchema = email.ParametersSchema
 		for _, out := range g.outputs {
 			if err = out.AddEmail(resource); err != nil {
 				return fmt.Errorf("error with adding to output :%w ", err)
@@ -2,6 +2,7 @@ package input
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/fs"
 	"path/filepath"
@@ -12,6 +13,8 @@ import (
 	"github.com/pralolik/templgrid/static"
 )
 
+const schemaExtension = ".schema.json"
+
 type DirectoryInput struct {
 	components []string
 	logger     logging.Logger
@@ -81,6 +84,15 @@ func (di *DirectoryInput) GetEmails() ([]*EmailInputTemplate, error) {
 		}
 		resource.EmailTemplate = string(txt)
 		resource.SubjectTemplate = string(txt)
+		schemaPath := strings.TrimSuffix(path, extension) + schemaExtension
+		schema, err := fs.ReadFile(static.Emails(), schemaPath)
+		switch {
+		case err == nil:
+			di.logger.Debug("Parsing email schema %s", schemaPath)
+			resource.ParametersSchema = string(schema)
+		case !errors.Is(err, fs.ErrNotExist):
+			return fmt.Errorf("read schema error %s: %w ", resource.Name, err)
+		}
 		tmplts = append(tmplts, resource)
 		return nil
 	})
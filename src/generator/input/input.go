@@ -10,4 +10,6 @@ type EmailInputTemplate struct {
 	Name            string
 	EmailTemplate   string
 	SubjectTemplate string
+	// ParametersSchema is an optional JSON Schema for the email parameters.
+	ParametersSchema string
 }
@@ -16,8 +16,7 @@ func NewStoreOutput(storage *templatemanager.EmailStorage) *StoreOutput {
 }
 
 func (do *StoreOutput) AddEmail(res *resources.TemplateResource) error {
-	do.storage.AddEmail(res)
-	return nil
+	return do.storage.AddEmail(res)
 }
 
 func (do *StoreOutput) AddComponents(components []string) {
@@ -0,0 +1,259 @@
+package grpcapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	grpcStatus "google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/pralolik/templgrid/pkg"
+	templgridv1 "github.com/pralolik/templgrid/pkg/proto/templgrid/v1"
+	"github.com/pralolik/templgrid/src/api/middleware"
+	"github.com/pralolik/templgrid/src/auth"
+	"github.com/pralolik/templgrid/src/ingest"
+	"github.com/pralolik/templgrid/src/logging"
+	"github.com/pralolik/templgrid/src/scheduler"
+	"github.com/pralolik/templgrid/src/status"
+	"github.com/pralolik/templgrid/src/templatemanager"
+)
+
+// MaxBatchSize is the max count of emails in SendBatch request.
+const MaxBatchSize = 100
+
+func (s *Server) SendEmail(ctx context.Context, req *templgridv1.SendEmailRequest) (*templgridv1.SendEmailResponse, error) {
+	email, err := toEntity(req.GetEmail())
+	if err != nil {
+		return nil, validationStatus(err)
+	}
+	principal, _ := auth.PrincipalFromContext(ctx)
+	if err = s.ingest.Authorize(principal, email); err != nil {
+		return nil, grpcStatus.Error(codes.PermissionDenied, err.Error())
+	}
+	if err = s.ingest.Validate(email); err != nil {
+		logging.FromContext(ctx, s.log).Error("Invalid gRPC request for %s parameters '%v': %v ",
+			email.TemplateName, s.redactor.Params(email.EmailParameters), err)
+		return nil, validationStatus(err)
+	}
+	id, err := s.ingest.Push(ctx, email)
+	if ingest.IsUnavailable(err) {
+		return nil, unavailableStatus(ctx, err)
+	}
+	if err != nil {
+		s.log.Error("gRPC internal error: %v ", err)
+		return nil, grpcStatus.Error(codes.Internal, "internal error")
+	}
+	logging.With(logging.FromContext(ctx, s.log), logging.MessageIDKey, id).
+		Info("New email type '%s' pushed to queue with id %s", email.TemplateName, id)
+
+	return &templgridv1.SendEmailResponse{Id: id}, nil
+}
+
+func (s *Server) SendBatch(ctx context.Context, req *templgridv1.SendBatchRequest) (*templgridv1.SendBatchResponse, error) {
+	if len(req.GetEmails()) == 0 || len(req.GetEmails()) > MaxBatchSize {
+		return nil, grpcStatus.Errorf(codes.InvalidArgument, "batch should contain from 1 to %d emails", MaxBatchSize)
+	}
+
+	principal, _ := auth.PrincipalFromContext(ctx)
+	res := &templgridv1.SendBatchResponse{Results: make([]*templgridv1.SendBatchResult, 0, len(req.GetEmails()))}
+	for _, reqEmail := range req.GetEmails() {
+		result := &templgridv1.SendBatchResult{}
+		res.Results = append(res.Results, result)
+
+		email, err := toEntity(reqEmail)
+		if err == nil {
+			err = s.ingest.Authorize(principal, email)
+		}
+		if err == nil {
+			err = s.ingest.Validate(email)
+		}
+		if err != nil {
+			result.Error = err.Error()
+			result.Fields = toFieldErrors(err)
+			continue
+		}
+		if result.Id, err = s.ingest.Push(ctx, email); ingest.IsUnavailable(err) {
+			result.Error = err.Error()
+			continue
+		}
+		if err != nil {
+			s.log.Error("gRPC internal error: %v ", err)
+			result.Error = "internal error"
+			continue
+		}
+		logging.With(logging.FromContext(ctx, s.log), logging.MessageIDKey, result.Id).
+			Info("New email type '%s' pushed to queue with id %s", email.TemplateName, result.Id)
+	}
+
+	return res, nil
+}
+
+// GetStatus returns status of the message sent by the key, statuses of other keys are not found.
+func (s *Server) GetStatus(ctx context.Context, req *templgridv1.GetStatusRequest) (*templgridv1.GetStatusResponse, error) {
+	st, err := s.statuses.Get(req.GetId())
+	if principal, ok := auth.PrincipalFromContext(ctx); err == nil && (!ok || !principal.Owns(st.KeyName)) {
+		err = status.ErrNotFound
+	}
+	if errors.Is(err, status.ErrNotFound) {
+		return nil, grpcStatus.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		s.log.Error("gRPC internal error: %v ", err)
+		return nil, grpcStatus.Error(codes.Internal, "internal error")
+	}
+
+	res := &templgridv1.GetStatusResponse{
+		Id:           st.ID,
+		TemplateName: st.TemplateName,
+		State:        string(st.State),
+		Error:        st.Error,
+		CreatedAt:    timestamppb.New(st.CreatedAt),
+		UpdatedAt:    timestamppb.New(st.UpdatedAt),
+	}
+	for _, dropped := range st.Dropped {
+		res.Dropped = append(res.Dropped, &templgridv1.DroppedRecipient{Address: dropped.Address, Reason: dropped.Reason})
+	}
+	for _, recipient := range st.Recipients {
+		res.Recipients = append(res.Recipients, &templgridv1.RecipientState{
+			Address:   recipient.Address,
+			State:     string(recipient.State),
+			Reason:    recipient.Reason,
+			UpdatedAt: timestamppb.New(recipient.UpdatedAt),
+		})
+	}
+
+	return res, nil
+}
+
+// CancelEmail cancels scheduled email, emails of other keys are not found.
+func (s *Server) CancelEmail(
+	ctx context.Context,
+	req *templgridv1.CancelEmailRequest) (*templgridv1.CancelEmailResponse, error) {
+	principal, _ := auth.PrincipalFromContext(ctx)
+	err := s.ingest.Cancel(principal, req.GetId())
+	if errors.Is(err, scheduler.ErrNotFound) {
+		// state is told only to the key which sent the message.
+		if st, stErr := s.statuses.Get(req.GetId()); stErr == nil && principal != nil && principal.Owns(st.KeyName) {
+			return nil, grpcStatus.Errorf(codes.FailedPrecondition, "message is not scheduled, state %s", st.State)
+		}
+		return nil, grpcStatus.Error(codes.NotFound, err.Error())
+	}
+	if errors.Is(err, auth.ErrForbidden) || errors.Is(err, auth.ErrUnauthorized) {
+		return nil, grpcStatus.Error(codes.PermissionDenied, err.Error())
+	}
+	if err != nil {
+		s.log.Error("gRPC internal error: %v ", err)
+		return nil, grpcStatus.Error(codes.Internal, "internal error")
+	}
+	logging.With(logging.FromContext(ctx, s.log), logging.MessageIDKey, req.GetId()).
+		Info("Scheduled email %s cancelled", req.GetId())
+
+	return &templgridv1.CancelEmailResponse{Id: req.GetId()}, nil
+}
+
+func (s *Server) RenderPreview(
+	ctx context.Context,
+	req *templgridv1.RenderPreviewRequest) (*templgridv1.RenderPreviewResponse, error) {
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		if err := principal.AuthorizeTemplate(req.GetTemplateName()); err != nil {
+			return nil, grpcStatus.Error(codes.PermissionDenied, err.Error())
+		}
+	}
+	if err := s.emailStorage.HasEmail(req.GetTemplateName()); err != nil {
+		return nil, grpcStatus.Error(codes.NotFound, err.Error())
+	}
+	params := req.GetEmailParameters().AsInterface()
+	if err := s.emailStorage.ValidateParameters(req.GetTemplateName(), params); err != nil {
+		return nil, validationStatus(err)
+	}
+	subject, html, err := s.emailStorage.BuildEmail(req.GetTemplateName(), req.GetLocale(), params)
+	if err != nil {
+		return nil, grpcStatus.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return &templgridv1.RenderPreviewResponse{Subject: subject, Html: html}, nil
+}
+
+func toEntity(email *templgridv1.Email) (*pkg.TemplgridEmailEntity, error) {
+	if email == nil {
+		return nil, errors.New("email is required")
+	}
+	entity := &pkg.TemplgridEmailEntity{
+		TemplateName:      email.GetTemplateName(),
+		Locale:            email.GetLocale(),
+		EmailParameters:   email.GetEmailParameters().AsInterface(),
+		Delay:             email.GetDelay(),
+		Priority:          pkg.Priority(email.GetPriority()),
+		Urgent:            email.GetUrgent(),
+		RecipientTimezone: email.GetRecipientTimezone(),
+		CallbackURL:       email.GetCallbackUrl(),
+	}
+	if email.GetSendAt() != nil {
+		sendAt := email.GetSendAt().AsTime()
+		entity.SendAt = &sendAt
+	}
+	if email.GetSendGridParameters() != nil {
+		sgJSON, err := json.Marshal(email.GetSendGridParameters().AsMap())
+		if err != nil {
+			return nil, fmt.Errorf("incorrect value for send_grid_parameters: %w", err)
+		}
+		var sgMail mail.SGMailV3
+		if err = json.Unmarshal(sgJSON, &sgMail); err != nil {
+			return nil, fmt.Errorf("incorrect value for send_grid_parameters: %w", err)
+		}
+		entity.SendGridParameters = sgMail
+		if entity.PersonalizationTimezones, err = pkg.PersonalizationTimezones(sgJSON); err != nil {
+			return nil, fmt.Errorf("incorrect value for send_grid_parameters: %w", err)
+		}
+	}
+
+	return entity, nil
+}
+
+func toFieldErrors(err error) []*templgridv1.FieldError {
+	var paramsErr *templatemanager.ParametersError
+	if !errors.As(err, &paramsErr) {
+		return nil
+	}
+	fields := make([]*templgridv1.FieldError, 0, len(paramsErr.Fields))
+	for _, f := range paramsErr.Fields {
+		fields = append(fields, &templgridv1.FieldError{Field: f.Field, Message: f.Message})
+	}
+
+	return fields
+}
+
+func validationStatus(err error) error {
+	st := grpcStatus.New(codes.InvalidArgument, err.Error())
+	fields := toFieldErrors(err)
+	if len(fields) == 0 {
+		return st.Err()
+	}
+	badRequest := &errdetails.BadRequest{}
+	for _, f := range fields {
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       f.Field,
+			Description: f.Message,
+		})
+	}
+	if detailed, detailsErr := st.WithDetails(badRequest); detailsErr == nil {
+		return detailed.Err()
+	}
+
+	return st.Err()
+}
+
+// unavailableStatus returns Unavailable status with retry-after header set.
+func unavailableStatus(ctx context.Context, err error) error {
+	_ = grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(middleware.RetryAfterSeconds(ingest.RetryAfter))))
+
+	return grpcStatus.Error(codes.Unavailable, err.Error())
+}
@@ -0,0 +1,340 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	otelCodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	grpcStatus "google.golang.org/grpc/status"
+
+	templgridv1 "github.com/pralolik/templgrid/pkg/proto/templgrid/v1"
+	"github.com/pralolik/templgrid/src/api/middleware"
+	"github.com/pralolik/templgrid/src/auth"
+	"github.com/pralolik/templgrid/src/helper"
+	"github.com/pralolik/templgrid/src/ingest"
+	"github.com/pralolik/templgrid/src/logging"
+	"github.com/pralolik/templgrid/src/notify"
+	"github.com/pralolik/templgrid/src/queue"
+	"github.com/pralolik/templgrid/src/redact"
+	"github.com/pralolik/templgrid/src/scheduler"
+	"github.com/pralolik/templgrid/src/status"
+	"github.com/pralolik/templgrid/src/templatemanager"
+	"github.com/pralolik/templgrid/src/tracing"
+)
+
+const (
+	DefPort           = ":9090"
+	shutdownTimeout   = 5 * time.Second
+	requestIDMetadata = "x-request-id"
+)
+
+type Server struct {
+	templgridv1.UnimplementedTemplgridServer
+
+	log          logging.Logger
+	addr         string
+	auth         auth.Authenticator
+	keyAuth      bool
+	emailStorage *templatemanager.EmailStorage
+	statuses     status.Store
+	ingest       *ingest.Ingest
+	notifier     *notify.Notifier
+	scheduler    *scheduler.Scheduler
+	grpcServer   *grpc.Server
+	health       *health.Server
+	limiter      middleware.Limiter
+	redactor     *redact.Redactor
+}
+
+func NewServer(log logging.Logger, options ...Option) *Server {
+	s := &Server{
+		log:      log,
+		addr:     DefPort,
+		auth:     auth.NewKeySet(),
+		keyAuth:  true,
+		statuses: status.NewMemoryStore(status.DefTTL),
+		health:   health.NewServer(),
+		redactor: redact.New(nil, nil),
+	}
+	for _, option := range options {
+		option(s)
+	}
+	s.grpcServer = grpc.NewServer(grpc.ChainUnaryInterceptor(s.tracing, s.logging, s.apiAuth, s.rateLimit))
+	templgridv1.RegisterTemplgridServer(s.grpcServer, s)
+	healthpb.RegisterHealthServer(s.grpcServer, s.health)
+
+	return s
+}
+
+func (s *Server) Serve(ctx context.Context, q queue.Interface) error {
+	s.ingest = ingest.New(
+		s.emailStorage,
+		s.statuses,
+		q,
+		ingest.WithScheduler(s.scheduler),
+		ingest.WithNotifier(s.notifier))
+	lis, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		s.log.Error("gRPC server failed: %v ", err)
+		return err
+	}
+	stopped := make(chan struct{})
+	go s.handleShutdown(ctx, stopped)
+
+	s.health.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	s.health.SetServingStatus(templgridv1.Templgrid_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	s.log.Info("gRPC server started to serve on: '%s'", s.addr)
+	if err = s.grpcServer.Serve(lis); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		s.log.Error("gRPC server failed: %v ", err)
+		return fmt.Errorf("grpc server failed: %w", err)
+	}
+	<-stopped
+
+	return nil
+}
+
+func (s *Server) handleShutdown(ctx context.Context, done chan<- struct{}) {
+	defer close(done)
+	<-ctx.Done()
+	s.log.Info("Shutting down the gRPC server!")
+	s.health.Shutdown()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(shutdownTimeout):
+		s.log.Error("Failed to shutdown the gRPC server gracefully")
+		s.grpcServer.Stop()
+	}
+}
+
+func (s *Server) apiAuth(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+	if strings.HasPrefix(info.FullMethod, "/"+healthpb.Health_ServiceDesc.ServiceName+"/") {
+		return handler(ctx, req)
+	}
+	if !s.keyAuth {
+		return nil, grpcStatus.Error(codes.Unauthenticated, "API keys are not accepted in hmac auth mode")
+	}
+	principal, err := s.auth.Authenticate(apiKeyFromMetadata(ctx))
+	if err != nil {
+		return nil, grpcStatus.Error(codes.Unauthenticated, err.Error())
+	}
+	if err = principal.Authorize(methodScope(info.FullMethod)); err != nil {
+		return nil, grpcStatus.Error(codes.PermissionDenied, err.Error())
+	}
+
+	return handler(auth.WithPrincipal(ctx, principal), req)
+}
+
+// rateLimit takes a token of the API key per request, batches take a token per email.
+func (s *Server) rateLimit(
+	ctx context.Context,
+	req interface{},
+	_ *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if s.limiter == nil || !ok {
+		return handler(ctx, req)
+	}
+	n := 1
+	if batch, ok := req.(*templgridv1.SendBatchRequest); ok && len(batch.GetEmails()) > 1 {
+		n = len(batch.GetEmails())
+	}
+	if allowed, retryAfter := s.limiter.AllowN(principal.Name, n); !allowed {
+		_ = grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(middleware.RetryAfterSeconds(retryAfter))))
+		return nil, grpcStatus.Error(codes.ResourceExhausted, "rate limit exceeded")
+	}
+
+	return handler(ctx, req)
+}
+
+func methodScope(fullMethod string) auth.Scope {
+	switch fullMethod {
+	case templgridv1.Templgrid_RenderPreview_FullMethodName:
+		return auth.ScopeRender
+	case templgridv1.Templgrid_SendEmail_FullMethodName,
+		templgridv1.Templgrid_SendBatch_FullMethodName,
+		templgridv1.Templgrid_GetStatus_FullMethodName,
+		templgridv1.Templgrid_CancelEmail_FullMethodName:
+		return auth.ScopeSend
+	default:
+		return auth.ScopeAdmin
+	}
+}
+
+func (s *Server) logging(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now().UTC()
+	requestID := requestIDFromMetadata(ctx)
+	_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDMetadata, requestID))
+	ctx = logging.ContextWith(ctx, logging.RequestIDKey, requestID)
+	log := logging.FromContext(ctx, s.log)
+
+	res, err := handler(ctx, req)
+	code := grpcStatus.Code(err)
+	if code != codes.OK {
+		log.Error("gRPC %s %s %s", info.FullMethod, code, time.Since(start).String())
+	} else {
+		log.Info("gRPC %s %s %s", info.FullMethod, code, time.Since(start).String())
+	}
+
+	return res, err
+}
+
+// tracing starts server span continuing W3C trace context of the request metadata.
+func (s *Server) tracing(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+	}
+	ctx, span := tracing.Tracer().Start(ctx, strings.TrimPrefix(info.FullMethod, "/"),
+		trace.WithSpanKind(trace.SpanKindServer))
+	defer span.End()
+
+	res, err := handler(ctx, req)
+	if err != nil {
+		span.SetStatus(otelCodes.Error, err.Error())
+	}
+
+	return res, err
+}
+
+// metadataCarrier adapts incoming gRPC metadata to propagation.TextMapCarrier.
+type metadataCarrier metadata.MD
+
+var _ propagation.TextMapCarrier = metadataCarrier{}
+
+func (c metadataCarrier) Get(key string) string {
+	if values := metadata.MD(c).Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// requestIDFromMetadata returns x-request-id of the request or a new id.
+func requestIDFromMetadata(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(requestIDMetadata); len(values) > 0 && middleware.ValidRequestID(values[0]) {
+			return values[0]
+		}
+	}
+
+	return helper.NewID()
+}
+
+func apiKeyFromMetadata(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	var authorization, xAPIKey string
+	if values := md.Get("authorization"); len(values) > 0 {
+		authorization = values[0]
+	}
+	if values := md.Get("x-api-key"); len(values) > 0 {
+		xAPIKey = values[0]
+	}
+
+	return auth.KeyFromHeader(authorization, xAPIKey)
+}
+
+type Option func(s *Server)
+
+func WithGRPC(addr string) Option {
+	return func(s *Server) {
+		s.addr = addr
+	}
+}
+
+func WithAuth(authenticator auth.Authenticator) Option {
+	return func(s *Server) {
+		s.auth = authenticator
+	}
+}
+
+// WithKeyAuth accepts API keys in metadata. gRPC requests are not signed,
+// so keys are disabled when the HTTP API accepts only signed requests.
+func WithKeyAuth(enabled bool) Option {
+	return func(s *Server) {
+		s.keyAuth = enabled
+	}
+}
+
+func WithEmailStorage(storage *templatemanager.EmailStorage) Option {
+	return func(s *Server) {
+		s.emailStorage = storage
+	}
+}
+
+// WithRateLimit limits requests per API key.
+func WithRateLimit(limiter middleware.Limiter) Option {
+	return func(s *Server) {
+		s.limiter = limiter
+	}
+}
+
+// WithRedactor sets redactor of parameters logged on validation errors.
+func WithRedactor(r *redact.Redactor) Option {
+	return func(s *Server) {
+		s.redactor = r
+	}
+}
+
+func WithStatusStore(statuses status.Store) Option {
+	return func(s *Server) {
+		s.statuses = statuses
+	}
+}
+
+// WithNotifier posts status changes of accepted emails to webhooks of their API keys.
+func WithNotifier(n *notify.Notifier) Option {
+	return func(s *Server) {
+		s.notifier = n
+	}
+}
+
+// WithScheduler enables scheduled delivery and cancellation of scheduled emails.
+func WithScheduler(sch *scheduler.Scheduler) Option {
+	return func(s *Server) {
+		s.scheduler = sch
+	}
+}
@@ -0,0 +1,78 @@
+package grpcapi
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	grpcStatus "google.golang.org/grpc/status"
+
+	templgridv1 "github.com/pralolik/templgrid/pkg/proto/templgrid/v1"
+	"github.com/pralolik/templgrid/src/auth"
+	"github.com/pralolik/templgrid/src/logging"
+	"github.com/pralolik/templgrid/src/ratelimit"
+)
+
+func TestAPIAuthKeyAuth(t *testing.T) {
+	keys := auth.NewKeySet(auth.Key{Name: "shop", Secret: "secret", Scopes: []auth.Scope{auth.ScopeSend}})
+	info := &grpc.UnaryServerInfo{FullMethod: templgridv1.Templgrid_SendEmail_FullMethodName}
+	handler := func(context.Context, interface{}) (interface{}, error) { return "ok", nil }
+
+	tests := []struct {
+		name    string
+		keyAuth bool
+		md      metadata.MD
+		want    codes.Code
+	}{
+		{name: "bearer key", keyAuth: true, md: metadata.Pairs("authorization", "Bearer secret"), want: codes.OK},
+		{name: "x-api-key", keyAuth: true, md: metadata.Pairs("x-api-key", "secret"), want: codes.OK},
+		{name: "wrong key", keyAuth: true, md: metadata.Pairs("x-api-key", "other"), want: codes.Unauthenticated},
+		{name: "bearer key in hmac mode", md: metadata.Pairs("authorization", "Bearer secret"), want: codes.Unauthenticated},
+		{name: "x-api-key in hmac mode", md: metadata.Pairs("x-api-key", "secret"), want: codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer(&logging.DisabledLog{}, WithAuth(keys), WithKeyAuth(tt.keyAuth))
+			ctx := metadata.NewIncomingContext(context.Background(), tt.md)
+			_, err := s.apiAuth(ctx, nil, info, handler)
+			if code := grpcStatus.Code(err); code != tt.want {
+				t.Errorf("code = %s, want %s", code, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimitBatch(t *testing.T) {
+	keys := auth.NewKeySet(auth.Key{Name: "shop", Secret: "secret", Scopes: []auth.Scope{auth.ScopeSend}})
+	principal, err := keys.Authenticate("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := auth.WithPrincipal(context.Background(), principal)
+	s := NewServer(&logging.DisabledLog{}, WithAuth(keys), WithRateLimit(ratelimit.NewKeyLimiter(0.001, 5)))
+	handler := func(context.Context, interface{}) (interface{}, error) { return "ok", nil }
+	batch := func(n int) *templgridv1.SendBatchRequest {
+		return &templgridv1.SendBatchRequest{Emails: make([]*templgridv1.Email, n)}
+	}
+
+	tests := []struct {
+		name string
+		req  interface{}
+		want codes.Code
+	}{
+		{name: "batch within burst", req: batch(3), want: codes.OK},
+		{name: "batch over tokens left", req: batch(3), want: codes.ResourceExhausted},
+		{name: "batch over burst", req: batch(6), want: codes.ResourceExhausted},
+		{name: "single email", req: &templgridv1.SendEmailRequest{}, want: codes.OK},
+		{name: "batch of the last token", req: batch(1), want: codes.OK},
+		{name: "no tokens left", req: &templgridv1.SendEmailRequest{}, want: codes.ResourceExhausted},
+	}
+	for _, tt := range tests {
+		_, err := s.rateLimit(ctx, tt.req, &grpc.UnaryServerInfo{}, handler)
+		if code := grpcStatus.Code(err); code != tt.want {
+			t.Errorf("%s: code = %s, want %s", tt.name, code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package helper
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// NewID returns random hex identifier.
+func NewID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,284 @@
+package history
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/pralolik/templgrid/src/status"
+)
+
+var (
+	// recordsBucket keeps records by send time key.
+	recordsBucket = []byte("history")
+	// idsBucket maps message id to the record key.
+	idsBucket = []byte("history_ids")
+	// recipientsBucket indexes record keys by recipient, keys are address, NUL and the record key.
+	recipientsBucket = []byte("history_recipients")
+	// bodiesBucket keeps rendered bodies by the record key.
+	bodiesBucket = []byte("history_bodies")
+)
+
+// BoltStore keeps history in bbolt file.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+func NewBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("can't open history db: %w ", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{recordsBucket, idsBucket, recipientsBucket, bodiesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("can't create history buckets: %w ", err)
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+func (s *BoltStore) Add(rec Record) error {
+	body := rec.Body
+	rec.Body = ""
+	v, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("can't marshal history record: %w ", err)
+	}
+	k := key(rec.SentAt, rec.ID)
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		// email restored from the queue file may be sent again.
+		if prev := tx.Bucket(idsBucket).Get([]byte(rec.ID)); prev != nil {
+			if err := deleteRecord(tx, append([]byte{}, prev...)); err != nil {
+				return err
+			}
+		}
+		if err := tx.Bucket(recordsBucket).Put(k, v); err != nil {
+			return err
+		}
+		if err := tx.Bucket(idsBucket).Put([]byte(rec.ID), k); err != nil {
+			return err
+		}
+		recipients := tx.Bucket(recipientsBucket)
+		for _, address := range rec.Recipients {
+			if err := recipients.Put(recipientKey(address, k), nil); err != nil {
+				return err
+			}
+		}
+		if body == "" {
+			return nil
+		}
+		return tx.Bucket(bodiesBucket).Put(k, []byte(body))
+	})
+}
+
+func (s *BoltStore) SetState(id string, state status.State, errMsg string, at time.Time) error {
+	return s.update(id, func(rec *Record) {
+		rec.State = state
+		rec.Error = errMsg
+		rec.UpdatedAt = at
+	})
+}
+
+func (s *BoltStore) AddEvent(id string, event status.Event) error {
+	return s.update(id, func(rec *Record) {
+		rec.Events = appendEvent(rec.Events, event)
+	})
+}
+
+// update changes the record of the message with fn or returns ErrNotFound.
+func (s *BoltStore) update(id string, fn func(rec *Record)) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		k := tx.Bucket(idsBucket).Get([]byte(id))
+		if k == nil {
+			return ErrNotFound
+		}
+		records := tx.Bucket(recordsBucket)
+		var rec Record
+		if err := json.Unmarshal(records.Get(k), &rec); err != nil {
+			return fmt.Errorf("can't unmarshal history record: %w ", err)
+		}
+		fn(&rec)
+		v, err := json.Marshal(rec)
+		if err != nil {
+			return fmt.Errorf("can't marshal history record: %w ", err)
+		}
+		return records.Put(k, v)
+	})
+}
+
+func (s *BoltStore) Get(id string) (*Record, error) {
+	var rec *Record
+	err := s.db.View(func(tx *bolt.Tx) error {
+		k := tx.Bucket(idsBucket).Get([]byte(id))
+		if k == nil {
+			return ErrNotFound
+		}
+		rec = &Record{}
+		if err := json.Unmarshal(tx.Bucket(recordsBucket).Get(k), rec); err != nil {
+			return fmt.Errorf("can't unmarshal history record: %w ", err)
+		}
+		rec.Body = string(tx.Bucket(bodiesBucket).Get(k))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rec, nil
+}
+
+// Search walks the recipient index if the recipient is set, records otherwise, from the latest key
+// before the cursor or To down to From. Template is matched on loaded records.
+func (s *BoltStore) Search(query Query, cursor string, limit int) ([]Record, string, error) {
+	after, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	var prefix []byte
+	if query.Recipient != "" {
+		prefix = recipientKey(query.Recipient, nil)
+	}
+	upper := after
+	if upper == nil && !query.To.IsZero() {
+		upper = timeKey(query.To.Add(time.Nanosecond))
+	}
+	var lower []byte
+	if !query.From.IsZero() {
+		lower = timeKey(query.From)
+	}
+
+	recs := make([]Record, 0, limit)
+	next := ""
+	err = s.db.View(func(tx *bolt.Tx) error {
+		records := tx.Bucket(recordsBucket)
+		c := records.Cursor()
+		if prefix != nil {
+			c = tx.Bucket(recipientsBucket).Cursor()
+		}
+		for k := last(c, prefix, upper); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Prev() {
+			k = k[len(prefix):]
+			if lower != nil && bytes.Compare(k, lower) < 0 {
+				return nil
+			}
+			var rec Record
+			if err := json.Unmarshal(records.Get(k), &rec); err != nil {
+				return fmt.Errorf("can't unmarshal history record: %w ", err)
+			}
+			if query.Template != "" && rec.TemplateName != query.Template {
+				continue
+			}
+			if len(recs) == limit {
+				lastRec := recs[len(recs)-1]
+				next = encodeCursor(key(lastRec.SentAt, lastRec.ID))
+				return nil
+			}
+			recs = append(recs, rec)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return recs, next, nil
+}
+
+// last moves the cursor to the last key with prefix before prefix+upper, the last key with prefix if upper is nil.
+func last(c *bolt.Cursor, prefix, upper []byte) []byte {
+	var k []byte
+	if upper == nil && prefix == nil {
+		k, _ = c.Last()
+		return k
+	}
+	seek := append(append([]byte{}, prefix...), upper...)
+	if upper == nil {
+		// NUL of the prefix is followed by the next byte, so the seek key is after all keys with prefix.
+		seek[len(seek)-1]++
+	}
+	if k, _ = c.Seek(seek); k == nil {
+		k, _ = c.Last()
+		return k
+	}
+	k, _ = c.Prev()
+
+	return k
+}
+
+func (s *BoltStore) Prune(before, bodiesBefore time.Time, limit int) (int, error) {
+	deleted := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bodies := tx.Bucket(bodiesBucket)
+		for _, k := range keysBefore(bodies, timeKey(bodiesBefore), limit) {
+			if err := bodies.Delete(k); err != nil {
+				return err
+			}
+			deleted++
+		}
+		for _, k := range keysBefore(tx.Bucket(recordsBucket), timeKey(before), limit-deleted) {
+			if err := deleteRecord(tx, k); err != nil {
+				return err
+			}
+			deleted++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return deleted, nil
+}
+
+// deleteRecord deletes the record with its index entries and body.
+func deleteRecord(tx *bolt.Tx, k []byte) error {
+	records := tx.Bucket(recordsBucket)
+	var rec Record
+	if err := json.Unmarshal(records.Get(k), &rec); err != nil {
+		return fmt.Errorf("can't unmarshal history record: %w ", err)
+	}
+	for _, address := range rec.Recipients {
+		if err := tx.Bucket(recipientsBucket).Delete(recipientKey(address, k)); err != nil {
+			return err
+		}
+	}
+	if err := tx.Bucket(idsBucket).Delete([]byte(rec.ID)); err != nil {
+		return err
+	}
+	if err := tx.Bucket(bodiesBucket).Delete(k); err != nil {
+		return err
+	}
+
+	return records.Delete(k)
+}
+
+// keysBefore returns up to limit first keys of the bucket lower than upper,
+// they are collected first, because deleting moves the cursor.
+func keysBefore(b *bolt.Bucket, upper []byte, limit int) [][]byte {
+	var keys [][]byte
+	c := b.Cursor()
+	for k, _ := c.First(); k != nil && len(keys) < limit && bytes.Compare(k, upper) < 0; k, _ = c.Next() {
+		keys = append(keys, append([]byte{}, k...))
+	}
+
+	return keys
+}
+
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+func recipientKey(address string, k []byte) []byte {
+	return append([]byte(address+"\x00"), k...)
+}
@@ -0,0 +1,265 @@
+package history
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pralolik/templgrid/src/logging"
+	"github.com/pralolik/templgrid/src/status"
+	"github.com/pralolik/templgrid/src/suppression"
+)
+
+const (
+	DefListLimit = 100
+	MaxListLimit = 1000
+	DefRetention = 30 * 24 * time.Hour
+	DefInterval  = time.Hour
+
+	// MaxEvents is the number of the latest events kept in the record.
+	MaxEvents = 100
+
+	pruneBatch = 1000
+)
+
+var (
+	// ErrNotFound is returned when there is no record of the message, it may be pruned.
+	ErrNotFound = errors.New("history record not found")
+	// ErrInvalid is returned for incorrect search queries.
+	ErrInvalid = errors.New("invalid history query")
+)
+
+// Record is the send attempt of the message kept for support lookups.
+type Record struct {
+	ID           string       `json:"id"`
+	TemplateName string       `json:"template_name"`
+	Locale       string       `json:"locale"`
+	Recipients   []string     `json:"recipients"`
+	Subject      string       `json:"subject,omitempty"`
+	KeyName      string       `json:"key_name,omitempty"`
+	State        status.State `json:"state"`
+	Error        string       `json:"error,omitempty"`
+	// ProviderID is the message id assigned by the provider.
+	ProviderID string    `json:"provider_id,omitempty"`
+	SentAt     time.Time `json:"sent_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	// Events are provider and tracking events, up to MaxEvents latest.
+	Events []status.Event `json:"events,omitempty"`
+	// Body is the rendered html, returned by Get only while body retention lasts.
+	Body string `json:"body,omitempty"`
+}
+
+// Query filters records, zero fields match everything. From and To are inclusive bounds of SentAt.
+type Query struct {
+	Recipient string
+	Template  string
+	From      time.Time
+	To        time.Time
+}
+
+// Store represents storage of history records.
+type Store interface {
+	// Add stores the record, body is stored separately, so it can be pruned earlier.
+	Add(rec Record) error
+	// SetState changes state of the record or returns ErrNotFound.
+	SetState(id string, state status.State, errMsg string, at time.Time) error
+	// AddEvent appends the event to the record or returns ErrNotFound.
+	AddEvent(id string, event status.Event) error
+	// Get returns the record with body or ErrNotFound.
+	Get(id string) (*Record, error)
+	// Search returns up to limit records without bodies, the latest first, after the cursor,
+	// and cursor of the next page, empty if there are no more records.
+	Search(query Query, cursor string, limit int) ([]Record, string, error)
+	// Prune deletes up to limit records sent before and bodies of records sent before bodiesBefore,
+	// returns count of deleted records and bodies.
+	Prune(before, bodiesBefore time.Time, limit int) (int, error)
+	Close() error
+}
+
+// History records sent messages and prunes them after retention.
+type History struct {
+	log           logging.Logger
+	store         Store
+	retention     time.Duration
+	bodyRetention time.Duration
+	interval      time.Duration
+}
+
+func New(log logging.Logger, store Store, options ...Option) *History {
+	h := &History{
+		log:       log,
+		store:     store,
+		retention: DefRetention,
+		interval:  DefInterval,
+	}
+	for _, option := range options {
+		option(h)
+	}
+
+	return h
+}
+
+type Option func(h *History)
+
+// WithRetention sets how long records are kept.
+func WithRetention(retention time.Duration) Option {
+	return func(h *History) {
+		if retention > 0 {
+			h.retention = retention
+		}
+	}
+}
+
+// WithBodyRetention sets how long rendered bodies are kept, 0 - bodies are not stored.
+func WithBodyRetention(retention time.Duration) Option {
+	return func(h *History) {
+		h.bodyRetention = retention
+	}
+}
+
+// Record stores the record, failures are logged, so history outage doesn't stop sending.
+func (h *History) Record(rec Record) {
+	if h.bodyRetention <= 0 {
+		rec.Body = ""
+	}
+	for i, address := range rec.Recipients {
+		rec.Recipients[i] = suppression.NormalizeAddress(address)
+	}
+	if rec.UpdatedAt.IsZero() {
+		rec.UpdatedAt = rec.SentAt
+	}
+	if err := h.store.Add(rec); err != nil {
+		h.log.Error("can't add history record of email %s: %v ", rec.ID, err)
+	}
+}
+
+// Get returns the record with body or ErrNotFound.
+func (h *History) Get(id string) (*Record, error) {
+	return h.store.Get(id)
+}
+
+// Search returns records matching the query, the latest first.
+func (h *History) Search(query Query, cursor string, limit int) ([]Record, string, error) {
+	if !query.From.IsZero() && !query.To.IsZero() && query.To.Before(query.From) {
+		return nil, "", fmt.Errorf("%w: to is before from", ErrInvalid)
+	}
+	query.Recipient = suppression.NormalizeAddress(query.Recipient)
+
+	return h.store.Search(query, cursor, limit)
+}
+
+// Watch returns statuses updating history records on state changes reported after sending.
+func (h *History) Watch(statuses status.Store) status.Store {
+	return &recorder{Store: statuses, history: h}
+}
+
+// Run prunes expired records and bodies until ctx is done.
+func (h *History) Run(ctx context.Context) error {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+	for {
+		h.prune(time.Now())
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *History) prune(now time.Time) {
+	bodiesBefore := now.Add(-h.bodyRetention)
+	total := 0
+	for {
+		n, err := h.store.Prune(now.Add(-h.retention), bodiesBefore, pruneBatch)
+		if err != nil {
+			h.log.Error("can't prune history: %v ", err)
+			return
+		}
+		total += n
+		if n < pruneBatch {
+			break
+		}
+	}
+	if total > 0 {
+		h.log.Info("%d history records and bodies pruned", total)
+	}
+}
+
+// recorder decorates status store, so provider events update history records.
+type recorder struct {
+	status.Store
+	history *History
+}
+
+// SetState updates history even if the status is expired, records are kept longer than statuses.
+func (r *recorder) SetState(id string, state status.State, err error) error {
+	errMsg := ""
+	if err != nil {
+		errMsg = err.Error()
+	}
+	setErr := r.history.store.SetState(id, state, errMsg, time.Now().UTC())
+	if setErr != nil && !errors.Is(setErr, ErrNotFound) {
+		r.history.log.Error("can't set history state %s of email %s: %v ", state, id, setErr)
+	}
+
+	return r.Store.SetState(id, state, err)
+}
+
+// AddEvent records the event in history, so it outlives the status. The message is unknown
+// only if neither history nor statuses have it.
+func (r *recorder) AddEvent(id string, event status.Event) error {
+	addErr := r.history.store.AddEvent(id, event)
+	if addErr != nil && !errors.Is(addErr, ErrNotFound) {
+		r.history.log.Error("can't add history event %s of email %s: %v ", event.Type, id, addErr)
+	}
+	err := r.Store.AddEvent(id, event)
+	if errors.Is(err, status.ErrNotFound) && addErr == nil {
+		return nil
+	}
+
+	return err
+}
+
+// appendEvent appends the event and drops the oldest ones over MaxEvents.
+func appendEvent(events []status.Event, event status.Event) []status.Event {
+	events = append(events, event)
+	if len(events) > MaxEvents {
+		events = events[len(events)-MaxEvents:]
+	}
+
+	return events
+}
+
+// key orders records by send time, id makes keys of the same time unique.
+func key(sentAt time.Time, id string) []byte {
+	k := make([]byte, 8, 8+len(id))
+	binary.BigEndian.PutUint64(k, uint64(sentAt.UnixNano()))
+
+	return append(k, id...)
+}
+
+// timeKey returns prefix of keys sent at t.
+func timeKey(t time.Time) []byte {
+	return key(t, "")
+}
+
+// encodeCursor makes cursor of the last listed key safe for URLs.
+func encodeCursor(k []byte) string {
+	return base64.RawURLEncoding.EncodeToString(k)
+}
+
+func decodeCursor(cursor string) ([]byte, error) {
+	k, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || (len(k) != 0 && len(k) <= 8) {
+		return nil, fmt.Errorf("%w: incorrect cursor", ErrInvalid)
+	}
+	if len(k) == 0 {
+		return nil, nil
+	}
+
+	return k, nil
+}
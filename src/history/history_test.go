@@ -0,0 +1,282 @@
+package history_test
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/pralolik/templgrid/src/history"
+	"github.com/pralolik/templgrid/src/status"
+)
+
+var base = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+// stores runs the test against every store implementation.
+func stores(t *testing.T, test func(t *testing.T, s history.Store)) {
+	t.Run("memory", func(t *testing.T) {
+		test(t, history.NewMemoryStore())
+	})
+	t.Run("bolt", func(t *testing.T) {
+		s, err := history.NewBoltStore(filepath.Join(t.TempDir(), "history.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = s.Close() })
+		test(t, s)
+	})
+}
+
+func add(t *testing.T, s history.Store, recs ...history.Record) {
+	t.Helper()
+	for _, rec := range recs {
+		if err := s.Add(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func ids(recs []history.Record) []string {
+	result := make([]string, 0, len(recs))
+	for _, rec := range recs {
+		result = append(result, rec.ID)
+	}
+
+	return result
+}
+
+func TestSearchPages(t *testing.T) {
+	stores(t, func(t *testing.T, s history.Store) {
+		var want []string
+		for i := range 7 {
+			id := fmt.Sprintf("m%d", i)
+			add(t, s, history.Record{ID: id, TemplateName: "welcome", SentAt: base.Add(time.Duration(i) * time.Minute)})
+			want = append([]string{id}, want...)
+		}
+		// records sent at the same time are ordered by id.
+		add(t, s, history.Record{ID: "m7", TemplateName: "welcome", SentAt: base})
+		want = append(want[:len(want)-1], "m7", "m0")
+
+		var got []string
+		cursor := ""
+		for page := 0; ; page++ {
+			if page > 5 {
+				t.Fatal("pagination doesn't end")
+			}
+			recs, next, err := s.Search(history.Query{}, cursor, 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(recs) > 3 {
+				t.Errorf("page %d has %d records, want up to 3", page, len(recs))
+			}
+			got = append(got, ids(recs)...)
+			if next == "" {
+				break
+			}
+			cursor = next
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("pages = %v, want %v", got, want)
+		}
+
+		if _, _, err := s.Search(history.Query{}, "!", 3); !errors.Is(err, history.ErrInvalid) {
+			t.Errorf("incorrect cursor error = %v, want ErrInvalid", err)
+		}
+	})
+}
+
+func TestSearchQuery(t *testing.T) {
+	stores(t, func(t *testing.T, s history.Store) {
+		add(t, s,
+			history.Record{ID: "m1", TemplateName: "welcome", Recipients: []string{"a@example.com"}, SentAt: base},
+			history.Record{ID: "m2", TemplateName: "reset", Recipients: []string{"a@example.com", "b@example.com"}, SentAt: base.Add(time.Hour)},
+			history.Record{ID: "m3", TemplateName: "welcome", Recipients: []string{"b@example.com"}, SentAt: base.Add(2 * time.Hour)},
+			history.Record{ID: "m4", TemplateName: "welcome", Recipients: []string{"a@example.com"}, SentAt: base.Add(3 * time.Hour)},
+			// address with the same prefix isn't matched by the recipient index.
+			history.Record{ID: "m5", TemplateName: "welcome", Recipients: []string{"a@example.co"}, SentAt: base.Add(time.Hour)},
+		)
+		tests := []struct {
+			name  string
+			query history.Query
+			want  []string
+		}{
+			{"all", history.Query{}, []string{"m4", "m3", "m5", "m2", "m1"}},
+			{"recipient", history.Query{Recipient: "a@example.com"}, []string{"m4", "m2", "m1"}},
+			{"recipient and template", history.Query{Recipient: "a@example.com", Template: "welcome"}, []string{"m4", "m1"}},
+			{"unknown recipient", history.Query{Recipient: "c@example.com"}, []string{}},
+			{"inclusive bounds", history.Query{From: base.Add(time.Hour), To: base.Add(2 * time.Hour)}, []string{"m3", "m5", "m2"}},
+			{"from", history.Query{From: base.Add(2 * time.Hour)}, []string{"m4", "m3"}},
+			{"to", history.Query{To: base}, []string{"m1"}},
+			{"recipient and bounds", history.Query{Recipient: "b@example.com", To: base.Add(90 * time.Minute)}, []string{"m2"}},
+		}
+		for _, tt := range tests {
+			recs, next, err := s.Search(tt.query, "", 10)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if got := ids(recs); !slices.Equal(got, tt.want) || next != "" {
+				t.Errorf("%s = %v next %q, want %v", tt.name, got, next, tt.want)
+			}
+		}
+
+		// pages of the recipient index continue after the cursor.
+		recs, next, err := s.Search(history.Query{Recipient: "a@example.com"}, "", 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ids(recs); !slices.Equal(got, []string{"m4", "m2"}) || next == "" {
+			t.Fatalf("first recipient page = %v next %q", got, next)
+		}
+		recs, next, err = s.Search(history.Query{Recipient: "a@example.com"}, next, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ids(recs); !slices.Equal(got, []string{"m1"}) || next != "" {
+			t.Errorf("second recipient page = %v next %q, want [m1]", got, next)
+		}
+	})
+}
+
+func TestBodies(t *testing.T) {
+	stores(t, func(t *testing.T, s history.Store) {
+		add(t, s, history.Record{ID: "m1", TemplateName: "welcome", SentAt: base, Body: "<p>hi</p>"})
+		rec, err := s.Get("m1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec.Body != "<p>hi</p>" {
+			t.Errorf("body = %q, want stored body", rec.Body)
+		}
+		recs, _, err := s.Search(history.Query{}, "", 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(recs) != 1 || recs[0].Body != "" {
+			t.Errorf("search = %+v, want the record without body", recs)
+		}
+		if _, err := s.Get("unknown"); !errors.Is(err, history.ErrNotFound) {
+			t.Errorf("unknown record error = %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestPrune(t *testing.T) {
+	stores(t, func(t *testing.T, s history.Store) {
+		add(t, s,
+			history.Record{ID: "old", Recipients: []string{"a@example.com"}, SentAt: base, Body: "old"},
+			history.Record{ID: "mid", Recipients: []string{"a@example.com"}, SentAt: base.Add(2 * time.Hour), Body: "mid"},
+			history.Record{ID: "new", Recipients: []string{"a@example.com"}, SentAt: base.Add(4 * time.Hour), Body: "new"},
+		)
+		n, err := s.Prune(base.Add(time.Hour), base.Add(3*time.Hour), 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n == 0 {
+			t.Error("nothing pruned")
+		}
+
+		if _, err := s.Get("old"); !errors.Is(err, history.ErrNotFound) {
+			t.Errorf("expired record error = %v, want ErrNotFound", err)
+		}
+		for id, body := range map[string]string{"mid": "", "new": "new"} {
+			rec, err := s.Get(id)
+			if err != nil {
+				t.Fatalf("%s: %v", id, err)
+			}
+			if rec.Body != body {
+				t.Errorf("%s body = %q, want %q", id, rec.Body, body)
+			}
+		}
+		recs, _, err := s.Search(history.Query{Recipient: "a@example.com"}, "", 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ids(recs); !slices.Equal(got, []string{"new", "mid"}) {
+			t.Errorf("recipient search after prune = %v, want [new mid]", got)
+		}
+
+		if n, err := s.Prune(base.Add(time.Hour), base.Add(3*time.Hour), 100); err != nil || n != 0 {
+			t.Errorf("repeated prune = %d %v, want nothing", n, err)
+		}
+	})
+}
+
+func TestPruneLimit(t *testing.T) {
+	stores(t, func(t *testing.T, s history.Store) {
+		for i := range 5 {
+			add(t, s, history.Record{ID: fmt.Sprintf("m%d", i), SentAt: base.Add(time.Duration(i) * time.Second)})
+		}
+		total := 0
+		for range 3 {
+			n, err := s.Prune(base.Add(time.Hour), base, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n > 2 {
+				t.Errorf("pruned %d, want up to 2", n)
+			}
+			total += n
+		}
+		if total != 5 {
+			t.Errorf("pruned %d records, want 5", total)
+		}
+	})
+}
+
+func TestUpdates(t *testing.T) {
+	stores(t, func(t *testing.T, s history.Store) {
+		add(t, s, history.Record{ID: "m1", TemplateName: "welcome", State: status.Queued, SentAt: base})
+		if err := s.SetState("m1", status.Failed, "provider error", base.Add(time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+		for i := range history.MaxEvents + 5 {
+			event := status.Event{Type: "open", Reason: fmt.Sprint(i), Timestamp: base.Add(time.Duration(i) * time.Second)}
+			if err := s.AddEvent("m1", event); err != nil {
+				t.Fatal(err)
+			}
+		}
+		rec, err := s.Get("m1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec.State != status.Failed || rec.Error != "provider error" || !rec.UpdatedAt.Equal(base.Add(time.Minute)) {
+			t.Errorf("record = %s %q %s, want failed state", rec.State, rec.Error, rec.UpdatedAt)
+		}
+		if len(rec.Events) != history.MaxEvents {
+			t.Fatalf("%d events kept, want %d", len(rec.Events), history.MaxEvents)
+		}
+		if first, last := rec.Events[0].Reason, rec.Events[len(rec.Events)-1].Reason; first != "5" || last != fmt.Sprint(history.MaxEvents+4) {
+			t.Errorf("events from %s to %s, want the latest", first, last)
+		}
+
+		if err := s.SetState("unknown", status.Sent, "", base); !errors.Is(err, history.ErrNotFound) {
+			t.Errorf("state of unknown record error = %v, want ErrNotFound", err)
+		}
+		if err := s.AddEvent("unknown", status.Event{Type: "open"}); !errors.Is(err, history.ErrNotFound) {
+			t.Errorf("event of unknown record error = %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestAddAgain(t *testing.T) {
+	stores(t, func(t *testing.T, s history.Store) {
+		add(t, s,
+			history.Record{ID: "m1", Recipients: []string{"a@example.com"}, SentAt: base},
+			// email restored from the queue is recorded again.
+			history.Record{ID: "m1", Recipients: []string{"b@example.com"}, SentAt: base.Add(time.Hour)},
+		)
+		recs, _, err := s.Search(history.Query{}, "", 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(recs) != 1 || !recs[0].SentAt.Equal(base.Add(time.Hour)) {
+			t.Errorf("records = %+v, want the latest attempt", recs)
+		}
+		if recs, _, _ := s.Search(history.Query{Recipient: "a@example.com"}, "", 10); len(recs) != 0 {
+			t.Errorf("previous recipient still indexed: %+v", recs)
+		}
+	})
+}
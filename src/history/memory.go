@@ -0,0 +1,138 @@
+package history
+
+import (
+	"bytes"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/pralolik/templgrid/src/status"
+)
+
+// MemoryStore keeps history in memory, it is lost on restart.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	records map[string]Record
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: map[string]Record{}}
+}
+
+func (s *MemoryStore) Add(rec Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[rec.ID] = rec
+
+	return nil
+}
+
+func (s *MemoryStore) SetState(id string, state status.State, errMsg string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.records[id]
+	if !ok {
+		return ErrNotFound
+	}
+	rec.State = state
+	rec.Error = errMsg
+	rec.UpdatedAt = at
+	s.records[id] = rec
+
+	return nil
+}
+
+func (s *MemoryStore) AddEvent(id string, event status.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.records[id]
+	if !ok {
+		return ErrNotFound
+	}
+	rec.Events = appendEvent(slices.Clone(rec.Events), event)
+	s.records[id] = rec
+
+	return nil
+}
+
+func (s *MemoryStore) Get(id string) (*Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rec, ok := s.records[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	rec.Events = slices.Clone(rec.Events)
+
+	return &rec, nil
+}
+
+func (s *MemoryStore) Search(query Query, cursor string, limit int) ([]Record, string, error) {
+	after, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	s.mu.RLock()
+	var recs []Record
+	for _, rec := range s.records {
+		if matches(rec, query) && (after == nil || bytes.Compare(key(rec.SentAt, rec.ID), after) < 0) {
+			rec.Body = ""
+			recs = append(recs, rec)
+		}
+	}
+	s.mu.RUnlock()
+	slices.SortFunc(recs, func(a, b Record) int {
+		return bytes.Compare(key(b.SentAt, b.ID), key(a.SentAt, a.ID))
+	})
+	next := ""
+	if len(recs) > limit {
+		recs = recs[:limit]
+		next = encodeCursor(key(recs[limit-1].SentAt, recs[limit-1].ID))
+	}
+	if recs == nil {
+		recs = []Record{}
+	}
+
+	return recs, next, nil
+}
+
+func matches(rec Record, query Query) bool {
+	switch {
+	case query.Recipient != "" && !slices.Contains(rec.Recipients, query.Recipient):
+		return false
+	case query.Template != "" && rec.TemplateName != query.Template:
+		return false
+	case !query.From.IsZero() && rec.SentAt.Before(query.From):
+		return false
+	case !query.To.IsZero() && rec.SentAt.After(query.To):
+		return false
+	}
+
+	return true
+}
+
+func (s *MemoryStore) Prune(before, bodiesBefore time.Time, limit int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	deleted := 0
+	for id, rec := range s.records {
+		if deleted == limit {
+			break
+		}
+		switch {
+		case rec.SentAt.Before(before):
+			delete(s.records, id)
+			deleted++
+		case rec.Body != "" && rec.SentAt.Before(bodiesBefore):
+			rec.Body = ""
+			s.records[id] = rec
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,203 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/pralolik/templgrid/pkg"
+	"github.com/pralolik/templgrid/src/auth"
+	"github.com/pralolik/templgrid/src/helper"
+	"github.com/pralolik/templgrid/src/notify"
+	"github.com/pralolik/templgrid/src/queue"
+	"github.com/pralolik/templgrid/src/scheduler"
+	"github.com/pralolik/templgrid/src/status"
+	"github.com/pralolik/templgrid/src/templatemanager"
+	"github.com/pralolik/templgrid/src/tracing"
+)
+
+// RetryAfter is suggested to clients when the queue is unavailable.
+const RetryAfter = time.Second
+
+var (
+	// ErrSchedulingDisabled is returned for emails with send time when the scheduler is not configured.
+	ErrSchedulingDisabled = errors.New("scheduled delivery is disabled")
+	// ErrCallbacksDisabled is returned for emails with callback URL when notifications are not configured.
+	ErrCallbacksDisabled = errors.New("status callbacks are disabled")
+)
+
+// IsUnavailable reports whether push failed because the queue is full or closed,
+// so the request may be retried later.
+func IsUnavailable(err error) bool {
+	return errors.Is(err, queue.ErrFull) || errors.Is(err, queue.ErrClosed)
+}
+
+// Ingest holds validation and queueing of incoming emails
+// shared by all ingestion APIs.
+type Ingest struct {
+	storage   *templatemanager.EmailStorage
+	statuses  status.Store
+	queue     queue.Interface
+	scheduler *scheduler.Scheduler
+	notifier  *notify.Notifier
+}
+
+func New(storage *templatemanager.EmailStorage, statuses status.Store, q queue.Interface, options ...Option) *Ingest {
+	in := &Ingest{
+		storage:  storage,
+		statuses: statuses,
+		queue:    q,
+	}
+	for _, option := range options {
+		option(in)
+	}
+
+	return in
+}
+
+type Option func(in *Ingest)
+
+// WithScheduler holds emails with send time in the future, they are rejected if nil.
+func WithScheduler(s *scheduler.Scheduler) Option {
+	return func(in *Ingest) {
+		in.scheduler = s
+	}
+}
+
+// WithNotifier posts status changes of accepted emails to their webhooks,
+// emails with callback URL are rejected if nil.
+func WithNotifier(n *notify.Notifier) Option {
+	return func(in *Ingest) {
+		in.notifier = n
+	}
+}
+
+// Authorize checks that principal may send the email.
+func (in *Ingest) Authorize(principal *auth.Principal, email *pkg.TemplgridEmailEntity) error {
+	if principal == nil {
+		return auth.ErrUnauthorized
+	}
+	if err := principal.Authorize(auth.ScopeSend); err != nil {
+		return err
+	}
+
+	return principal.AuthorizeTemplate(email.TemplateName)
+}
+
+// Validate checks email entity, template existence and template parameters.
+func (in *Ingest) Validate(email *pkg.TemplgridEmailEntity) error {
+	if err := email.Validate(); err != nil {
+		return err
+	}
+
+	if email.CallbackURL != "" && in.notifier == nil {
+		return ErrCallbacksDisabled
+	}
+
+	if due := email.DueAt(time.Now()); !due.IsZero() {
+		if in.scheduler == nil {
+			return ErrSchedulingDisabled
+		}
+		if err := in.scheduler.Check(due); err != nil {
+			return err
+		}
+	}
+
+	if err := in.storage.HasEmail(email.TemplateName); err != nil {
+		return err
+	}
+
+	return in.storage.ValidateParameters(email.TemplateName, email.EmailParameters)
+}
+
+// Push assigns new id to the email and pushes it to the queue,
+// emails due in the future are scheduled and have SendAt set.
+// Trace context and API key name of ctx principal are stored in the email.
+// Email expected to be validated before.
+func (in *Ingest) Push(ctx context.Context, email *pkg.TemplgridEmailEntity) (string, error) {
+	email.ID = helper.NewID()
+	email.KeyName = ""
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		email.KeyName = principal.Name
+	}
+	if email.Priority == "" {
+		email.Priority = in.storage.Options(email.TemplateName).Priority
+	}
+	due := email.DueAt(time.Now())
+	email.SendAt, email.Delay = nil, ""
+	if !due.IsZero() {
+		email.SendAt = &due
+	}
+	ctx, span := tracing.Tracer().Start(ctx, "templgrid.enqueue",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(tracing.MessageIDKey.String(email.ID), tracing.TemplateNameKey.String(email.TemplateName)))
+	defer span.End()
+	tracing.Inject(ctx, email)
+
+	if err := in.statuses.Create(email.ID, email.TemplateName, email.KeyName); err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return "", fmt.Errorf("can't create status: %w ", err)
+	}
+	if in.notifier != nil {
+		in.notifier.Watch(email)
+	}
+	if email.SendAt != nil {
+		if err := in.schedule(email); err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			return "", err
+		}
+		return email.ID, nil
+	}
+	if err := in.queue.Push(email); err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		in.reject(email.ID, err)
+		return "", fmt.Errorf("can't push to queue: %w ", err)
+	}
+
+	return email.ID, nil
+}
+
+func (in *Ingest) schedule(email *pkg.TemplgridEmailEntity) error {
+	if in.scheduler == nil {
+		return ErrSchedulingDisabled
+	}
+	_ = in.statuses.SetState(email.ID, status.Scheduled, nil)
+	if err := in.scheduler.Schedule(email); err != nil {
+		in.reject(email.ID, err)
+		return err
+	}
+
+	return nil
+}
+
+// reject fails the email which was not accepted, the client gets the error instead of notification.
+func (in *Ingest) reject(id string, err error) {
+	if in.notifier != nil {
+		in.notifier.Forget(id)
+	}
+	_ = in.statuses.SetState(id, status.Failed, err)
+}
+
+// Cancel cancels scheduled email if principal sent it or has admin scope.
+// Returns scheduler.ErrNotFound if the email is not scheduled or sent by another key.
+func (in *Ingest) Cancel(principal *auth.Principal, id string) error {
+	if in.scheduler == nil {
+		return scheduler.ErrNotFound
+	}
+	email, err := in.scheduler.Get(id)
+	if err != nil {
+		return err
+	}
+	if principal == nil || !principal.Owns(email.KeyName) {
+		return scheduler.ErrNotFound
+	}
+	if err = in.Authorize(principal, email); err != nil {
+		return err
+	}
+
+	return in.scheduler.Cancel(id)
+}
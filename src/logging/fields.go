@@ -0,0 +1,49 @@
+package logging
+
+import "context"
+
+// Field keys shared by all components.
+const (
+	RequestIDKey = "request_id"
+	MessageIDKey = "message_id"
+	TemplateKey  = "template"
+)
+
+// FieldLogger represents Logger which carries key-value fields.
+type FieldLogger interface {
+	Logger
+	// With returns logger which adds key-value pairs to every message.
+	With(keyvals ...interface{}) Logger
+}
+
+// With returns log with key-value pairs added,
+// log is returned unchanged if it doesn't support fields.
+func With(log Logger, keyvals ...interface{}) Logger {
+	if len(keyvals) == 0 {
+		return log
+	}
+	if fl, ok := log.(FieldLogger); ok {
+		return fl.With(keyvals...)
+	}
+
+	return log
+}
+
+type fieldsKey struct{}
+
+// ContextWith returns ctx carrying key-value pairs for loggers obtained by FromContext.
+func ContextWith(ctx context.Context, keyvals ...interface{}) context.Context {
+	fields, _ := ctx.Value(fieldsKey{}).([]interface{})
+	merged := make([]interface{}, 0, len(fields)+len(keyvals))
+	merged = append(merged, fields...)
+	merged = append(merged, keyvals...)
+
+	return context.WithValue(ctx, fieldsKey{}, merged)
+}
+
+// FromContext returns log with key-value pairs stored in ctx.
+func FromContext(ctx context.Context, log Logger) Logger {
+	fields, _ := ctx.Value(fieldsKey{}).([]interface{})
+
+	return With(log, fields...)
+}
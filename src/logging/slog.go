@@ -0,0 +1,99 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// ErrParseStrToFormat indicates that string given to function ParseFormat can't be parsed to Format.
+var ErrParseStrToFormat = errors.New("string can't be parsed to format, use: `text`, `json`, `logfmt`")
+
+// Format represents output format of logs.
+type Format string
+
+const (
+	// FormatText is colored free text of StdLog.
+	FormatText Format = "text"
+	// FormatJSON is one JSON object per line.
+	FormatJSON Format = "json"
+	// FormatLogfmt is key=value pairs per line.
+	FormatLogfmt Format = "logfmt"
+)
+
+func ParseFormat(format string) (Format, error) {
+	switch f := Format(strings.ToLower(format)); f {
+	case "":
+		return FormatText, nil
+	case FormatText, FormatJSON, FormatLogfmt:
+		return f, nil
+	default:
+		return FormatText, fmt.Errorf("%s %w", format, ErrParseStrToFormat)
+	}
+}
+
+// New returns logger writing in the given format.
+func New(level Level, format Format, w io.Writer) Logger {
+	if level == DSB {
+		return NewDisabledLog()
+	}
+	opts := &slog.HandlerOptions{Level: level.slogLevel()}
+	switch format {
+	case FormatJSON:
+		return NewSlogLog(slog.New(slog.NewJSONHandler(w, opts)))
+	case FormatLogfmt:
+		return NewSlogLog(slog.New(slog.NewTextHandler(w, opts)))
+	default:
+		return NewStdLogWriter(level, w)
+	}
+}
+
+func (l Level) slogLevel() slog.Level {
+	switch l {
+	case ERR:
+		return slog.LevelError
+	case DBG:
+		return slog.LevelDebug
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// NewSlogLog returns Logger adapter of slog.Logger.
+// Level filtering is done by the slog handler.
+func NewSlogLog(logger *slog.Logger) *SlogLog {
+	return &SlogLog{logger: logger}
+}
+
+// SlogLog represents structured logger backed by log/slog.
+type SlogLog struct {
+	logger *slog.Logger
+}
+
+func (l *SlogLog) Debug(format string, v ...interface{}) { l.log(slog.LevelDebug, format, v) }
+func (l *SlogLog) Info(format string, v ...interface{})  { l.log(slog.LevelInfo, format, v) }
+func (l *SlogLog) Error(format string, v ...interface{}) { l.log(slog.LevelError, format, v) }
+
+func (l *SlogLog) With(keyvals ...interface{}) Logger {
+	return &SlogLog{logger: l.logger.With(keyvals...)}
+}
+
+func (l *SlogLog) Enabled(level Level) bool {
+	return l.logger.Enabled(context.Background(), level.slogLevel())
+}
+
+// Slog returns underlying slog.Logger.
+func (l *SlogLog) Slog() *slog.Logger {
+	return l.logger
+}
+
+func (l *SlogLog) log(level slog.Level, format string, v []interface{}) {
+	ctx := context.Background()
+	if !l.logger.Enabled(ctx, level) {
+		return
+	}
+	l.logger.Log(ctx, level, strings.TrimSpace(fmt.Sprintf(format, v...)))
+}
@@ -0,0 +1,24 @@
+package logging
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestNewWritesToWriter(t *testing.T) {
+	for _, format := range []Format{FormatText, FormatJSON, FormatLogfmt} {
+		var buf bytes.Buffer
+		log := New(INF, format, &buf)
+		log.Debug("hidden")
+		log.Info("sent %s", "m1")
+		log.Error("failed %s", "m2")
+		out := buf.String()
+		if !strings.Contains(out, "sent m1") || !strings.Contains(out, "failed m2") {
+			t.Errorf("%s output = %q, want info and error messages", format, out)
+		}
+		if strings.Contains(out, "hidden") {
+			t.Errorf("%s output has debug message: %q", format, out)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package logging
 import (
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"strings"
@@ -39,6 +40,23 @@ type Logger interface {
 	Error(format string, v ...interface{})
 }
 
+// LevelLogger represents Logger which filters messages by level.
+type LevelLogger interface {
+	Logger
+	// Enabled reports whether messages of the level are printed.
+	Enabled(level Level) bool
+}
+
+// Enabled reports whether log prints messages of the level,
+// loggers which don't filter by level print everything.
+func Enabled(log Logger, level Level) bool {
+	if ll, ok := log.(LevelLogger); ok {
+		return ll.Enabled(level)
+	}
+
+	return true
+}
+
 func NewDisabledLog() Logger { return &DisabledLog{} }
 
 type DisabledLog struct{}
@@ -47,16 +65,29 @@ func (l *DisabledLog) Debug(string, ...interface{}) {}
 func (l *DisabledLog) Info(string, ...interface{})  {}
 func (l *DisabledLog) Error(string, ...interface{}) {}
 
+func (l *DisabledLog) With(...interface{}) Logger { return l }
+
+func (l *DisabledLog) Enabled(Level) bool { return false }
+
 // NewStdLog returns a new instance of StdLog struct.
-// Takes variadic options which will be applied to StdLog.
+// Errors are written to stderr, other levels to stdout.
 func NewStdLog(level Level) Logger {
+	return newStdLog(level, os.Stdout, os.Stderr)
+}
+
+// NewStdLogWriter returns StdLog writing all levels to w.
+func NewStdLogWriter(level Level, w io.Writer) Logger {
+	return newStdLog(level, w, w)
+}
+
+func newStdLog(level Level, out, errOut io.Writer) Logger {
 	if level == DSB {
 		return NewDisabledLog()
 	}
 	l := &StdLog{
-		err: log.New(os.Stderr, "\033[31mERR\033[0m: ", log.Ldate|log.Ltime),
-		inf: log.New(os.Stdout, "\033[32mINF\033[0m: ", log.Ldate|log.Ltime),
-		dbg: log.New(os.Stdout, "\033[35mDBG\033[0m: ", log.Ldate|log.Ltime),
+		err: log.New(errOut, "\033[31mERR\033[0m: ", log.Ldate|log.Ltime),
+		inf: log.New(out, "\033[32mINF\033[0m: ", log.Ldate|log.Ltime),
+		dbg: log.New(out, "\033[35mDBG\033[0m: ", log.Ldate|log.Ltime),
 		lvl: level,
 	}
 
@@ -67,27 +98,57 @@ func NewStdLog(level Level) Logger {
 type StdLog struct {
 	err, inf, dbg *log.Logger
 	lvl           Level
+	// fields are appended to every message as key=value.
+	fields string
 }
 
 func (l *StdLog) Debug(format string, v ...interface{}) {
 	if l.lvl < DBG {
 		return
 	}
-	l.dbg.Printf(format, v...)
+	l.dbg.Print(l.message(format, v))
 }
 
 func (l *StdLog) Info(format string, v ...interface{}) {
 	if l.lvl < INF {
 		return
 	}
-	l.inf.Printf(format, v...)
+	l.inf.Print(l.message(format, v))
 }
 
 func (l *StdLog) Error(format string, v ...interface{}) {
 	if l.lvl < ERR {
 		return
 	}
-	l.err.Printf(format, v...)
+	l.err.Print(l.message(format, v))
+}
+
+func (l *StdLog) Enabled(level Level) bool {
+	return level <= l.lvl
+}
+
+func (l *StdLog) message(format string, v []interface{}) string {
+	if l.fields == "" {
+		return fmt.Sprintf(format, v...)
+	}
+
+	return strings.TrimRight(fmt.Sprintf(format, v...), " ") + l.fields
+}
+
+func (l *StdLog) With(keyvals ...interface{}) Logger {
+	c := *l
+	var b strings.Builder
+	b.WriteString(l.fields)
+	for i := 0; i < len(keyvals); i += 2 {
+		var value interface{} = "!MISSING"
+		if i+1 < len(keyvals) {
+			value = keyvals[i+1]
+		}
+		fmt.Fprintf(&b, " %v=%v", keyvals[i], value)
+	}
+	c.fields = b.String()
+
+	return &c
 }
 
 func ParseLevel(lvl string) (Level, error) {
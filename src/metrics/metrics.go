@@ -0,0 +1,226 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "templgrid"
+
+// Dead letter reasons.
+const (
+	ReasonPermanent        = "permanent"
+	ReasonRetriesExhausted = "retries_exhausted"
+)
+
+// Metrics holds Prometheus collectors of the service.
+// All methods are safe to call on nil Metrics, which disables collection.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests      *prometheus.CounterVec
+	httpDuration      *prometheus.HistogramVec
+	enqueued          prometheus.Counter
+	dequeued          prometheus.Counter
+	renderDuration    *prometheus.HistogramVec
+	renderFailures    *prometheus.CounterVec
+	providerDuration  prometheus.Histogram
+	providerResponses *prometheus.CounterVec
+	retries           prometheus.Counter
+	deadLettered      *prometheus.CounterVec
+	queueDepth        atomic.Pointer[func() int]
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by route, method and status.",
+		}, []string{"route", "method", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by route and method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		enqueued: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "queue_enqueued_total",
+			Help:      "Emails pushed to the queue.",
+		}),
+		dequeued: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "queue_dequeued_total",
+			Help:      "Emails taken from the queue by senders.",
+		}),
+		renderDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "render_duration_seconds",
+			Help:      "Email build time by template.",
+			Buckets:   []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, []string{"template"}),
+		renderFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "render_failures_total",
+			Help:      "Failed email builds by template.",
+		}, []string{"template"}),
+		providerDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "provider_request_duration_seconds",
+			Help:      "Provider send request latency.",
+			Buckets:   prometheus.DefBuckets,
+		}),
+		providerResponses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "provider_responses_total",
+			Help:      "Provider responses by status class, `error` for failed requests.",
+		}, []string{"class"}),
+		retries: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "send_retries_total",
+			Help:      "Retried provider requests.",
+		}),
+		deadLettered: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "dead_lettered_total",
+			Help:      "Emails given up on by reason.",
+		}, []string{"reason"}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "queue_depth",
+			Help:      "Emails waiting in the queue.",
+		}, m.depth),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.enqueued,
+		m.dequeued,
+		m.renderDuration,
+		m.renderFailures,
+		m.providerDuration,
+		m.providerResponses,
+		m.retries,
+		m.deadLettered,
+	)
+
+	return m
+}
+
+// Handler returns http handler exposing metrics.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// RegisterQueueDepth exposes current queue depth returned by depth.
+// Replaces previously registered queue.
+func (m *Metrics) RegisterQueueDepth(depth func() int) {
+	if m == nil {
+		return
+	}
+	m.queueDepth.Store(&depth)
+}
+
+func (m *Metrics) depth() float64 {
+	depth := m.queueDepth.Load()
+	if depth == nil {
+		return 0
+	}
+
+	return float64((*depth)())
+}
+
+func (m *Metrics) ObserveHTTP(route, method string, status int, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.httpRequests.WithLabelValues(route, method, strconv.Itoa(status)).Inc()
+	m.httpDuration.WithLabelValues(route, method).Observe(d.Seconds())
+}
+
+func (m *Metrics) Enqueued() {
+	if m == nil {
+		return
+	}
+	m.enqueued.Inc()
+}
+
+func (m *Metrics) Dequeued() {
+	if m == nil {
+		return
+	}
+	m.dequeued.Inc()
+}
+
+func (m *Metrics) ObserveRender(template string, d time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	if err != nil {
+		m.renderFailures.WithLabelValues(template).Inc()
+		return
+	}
+	m.renderDuration.WithLabelValues(template).Observe(d.Seconds())
+}
+
+// ObserveProvider records provider request, status is 0 for failed requests.
+func (m *Metrics) ObserveProvider(status int, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.providerDuration.Observe(d.Seconds())
+	class := "error"
+	if status > 0 {
+		class = strconv.Itoa(status/100) + "xx"
+	}
+	m.providerResponses.WithLabelValues(class).Inc()
+}
+
+func (m *Metrics) Retried() {
+	if m == nil {
+		return
+	}
+	m.retries.Inc()
+}
+
+func (m *Metrics) DeadLettered(reason string) {
+	if m == nil {
+		return
+	}
+	m.deadLettered.WithLabelValues(reason).Inc()
+}
+
+// Serve exposes metrics on separate addr until ctx is done.
+func (m *Metrics) Serve(ctx context.Context, addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.Handler())
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: time.Minute,
+	}
+	go func() {
+		<-ctx.Done()
+		killctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(killctx)
+	}()
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("metrics server failed: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,290 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	EventHeader     = "X-Templgrid-Event"
+	DeliveryHeader  = "X-Templgrid-Delivery"
+	TimestampHeader = "X-Templgrid-Timestamp"
+	SignatureHeader = "X-Templgrid-Signature"
+)
+
+// errPermanent marks responses which are not retried.
+var errPermanent = errors.New("permanent failure")
+
+// Sign returns hex HMAC-SHA256 of the unix timestamp and the body joined by a dot,
+// receivers compare it with SignatureHeader.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Delivery is the attempt to post the event recorded in the delivery log.
+type Delivery struct {
+	EventID    string    `json:"event_id"`
+	MessageID  string    `json:"message_id"`
+	Type       string    `json:"type"`
+	URL        string    `json:"url"`
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Delivered  bool      `json:"delivered"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// Pending is the event which was not delivered before shutdown, it is posted again after Restore.
+type Pending struct {
+	Event   Event  `json:"event"`
+	KeyName string `json:"key_name,omitempty"`
+	// CallbackURL is set if the event is posted to callback URL of the request.
+	CallbackURL string `json:"callback_url,omitempty"`
+}
+
+// Run posts events by concurrent workers until ctx is done or Close is called.
+// Events waiting for delivery or retry are kept and returned by Close.
+func (n *Notifier) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	n.mu.Lock()
+	n.cancelRun = cancel
+	n.mu.Unlock()
+
+	for i := 0; i < n.concurrency; i++ {
+		n.workers.Add(1)
+		go func() {
+			defer n.workers.Done()
+			n.work(ctx)
+		}()
+	}
+	n.log.Info("Webhook notifier started with %d workers", n.concurrency)
+	n.workers.Wait()
+
+	return nil
+}
+
+func (n *Notifier) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case d := <-n.deliveries:
+			n.deliver(ctx, d)
+		case <-n.closing:
+			// backlog is delivered before workers stop.
+			select {
+			case d := <-n.deliveries:
+				n.deliver(ctx, d)
+			default:
+				return
+			}
+		}
+	}
+}
+
+// Close delivers events waiting for delivery or retry until ctx is done and stops workers of Run.
+// Returns events which were not delivered, so they can be persisted and posted by Restore on the next start.
+func (n *Notifier) Close(ctx context.Context) []Pending {
+	n.closeOnce.Do(func() { close(n.closing) })
+	stopped := make(chan struct{})
+	go func() {
+		n.workers.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		n.mu.Lock()
+		cancel := n.cancelRun
+		n.mu.Unlock()
+		if cancel != nil {
+			cancel()
+		}
+		<-stopped
+	}
+	for drained := false; !drained; {
+		select {
+		case d := <-n.deliveries:
+			n.keep(d)
+		default:
+			drained = true
+		}
+	}
+
+	n.leftMu.Lock()
+	defer n.leftMu.Unlock()
+	pending := make([]Pending, 0, len(n.left))
+	for _, d := range n.left {
+		pending = append(pending, d.pending())
+	}
+	n.left = nil
+
+	return pending
+}
+
+// Restore queues events persisted on previous shutdown, webhooks are resolved by current endpoints.
+func (n *Notifier) Restore(pending []Pending) {
+	for _, p := range pending {
+		endpoint, ok := n.resolve(p.KeyName, p.CallbackURL)
+		if !ok {
+			n.log.Error("webhook event %s of message %s dropped, API key %s has no webhook",
+				p.Event.Type, p.Event.MessageID, p.KeyName)
+			continue
+		}
+		d := delivery{endpoint: endpoint, callback: p.CallbackURL != "", keyName: p.KeyName, event: p.Event}
+		select {
+		case n.deliveries <- d:
+		default:
+			n.log.Error("webhook event %s of message %s dropped, delivery backlog is full", p.Event.Type, p.Event.MessageID)
+		}
+	}
+}
+
+// keep saves the event to be returned by Close.
+func (n *Notifier) keep(d delivery) {
+	n.leftMu.Lock()
+	defer n.leftMu.Unlock()
+	n.left = append(n.left, d)
+}
+
+func (n *Notifier) deliver(ctx context.Context, d delivery) {
+	body, err := json.Marshal(d.event)
+	if err != nil {
+		n.log.Error("can't marshal webhook event %s: %v ", d.event.ID, err)
+		return
+	}
+	backoff := n.backoff
+	for attempt := 1; ; attempt++ {
+		code, err := n.post(ctx, d, body)
+		entry := Delivery{
+			EventID:    d.event.ID,
+			MessageID:  d.event.MessageID,
+			Type:       d.event.Type,
+			URL:        d.endpoint.URL,
+			Attempt:    attempt,
+			StatusCode: code,
+			Delivered:  err == nil,
+			Timestamp:  time.Now().UTC(),
+		}
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		n.record(entry)
+		if err == nil {
+			n.log.Debug("webhook event %s of message %s delivered", d.event.Type, d.event.MessageID)
+			return
+		}
+		if errors.Is(err, errPermanent) || attempt > n.maxRetries {
+			n.log.Error("webhook event %s of message %s not delivered: %v ", d.event.Type, d.event.MessageID, err)
+			return
+		}
+		if ctx.Err() != nil {
+			n.keep(d)
+			return
+		}
+		n.log.Info("retry webhook event %s of message %s in %s: %v ", d.event.Type, d.event.MessageID, backoff, err)
+		select {
+		case <-ctx.Done():
+			n.keep(d)
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// post returns status code of the response, 4xx except timeout and rate limit are permanent failures.
+func (n *Notifier) post(ctx context.Context, d delivery, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", errPermanent, err)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, d.event.Type)
+	req.Header.Set(DeliveryHeader, d.event.ID)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, Sign(d.endpoint.Secret, timestamp, body))
+
+	client := n.client
+	if d.callback {
+		client = n.callbackClient
+	}
+	res, err := client.Do(req)
+	if errors.Is(err, ErrForbiddenAddress) {
+		return 0, fmt.Errorf("%w: %w", errPermanent, err)
+	}
+	if err != nil {
+		return 0, err
+	}
+	_ = res.Body.Close()
+	switch {
+	case res.StatusCode < http.StatusMultipleChoices:
+		return res.StatusCode, nil
+	case res.StatusCode == http.StatusRequestTimeout || res.StatusCode == http.StatusTooManyRequests:
+		return res.StatusCode, errors.New(http.StatusText(res.StatusCode))
+	case res.StatusCode < http.StatusInternalServerError:
+		return res.StatusCode, fmt.Errorf("%w: %s", errPermanent, http.StatusText(res.StatusCode))
+	}
+
+	return res.StatusCode, errors.New(http.StatusText(res.StatusCode))
+}
+
+// deliveryLog keeps last delivery attempts in a ring.
+type deliveryLog struct {
+	mu      sync.RWMutex
+	entries []Delivery
+	next    int
+	full    bool
+}
+
+func newDeliveryLog(size int) *deliveryLog {
+	return &deliveryLog{entries: make([]Delivery, size)}
+}
+
+func (l *deliveryLog) record(entry Delivery) {
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now().UTC()
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries[l.next] = entry
+	l.next = (l.next + 1) % len(l.entries)
+	if l.next == 0 {
+		l.full = true
+	}
+}
+
+// Deliveries returns logged attempts from the newest, all messages if messageID is empty.
+func (l *deliveryLog) Deliveries(messageID string, limit int) []Delivery {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	count := l.next
+	if l.full {
+		count = len(l.entries)
+	}
+	res := make([]Delivery, 0, min(count, limit))
+	for i := 1; i <= count && len(res) < limit; i++ {
+		entry := l.entries[(l.next-i+len(l.entries))%len(l.entries)]
+		if messageID == "" || entry.MessageID == messageID {
+			res = append(res, entry)
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,65 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress is returned when callback URL resolves to an address which is not public.
+var ErrForbiddenAddress = errors.New("callback address is not public")
+
+// sharedAddressSpace is RFC 6598 carrier-grade NAT range, cloud metadata services are served from it too.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// publicAddress reports whether ip is a public unicast address, loopback, private, link-local
+// including metadata 169.254.169.254, shared and unspecified addresses are not.
+func publicAddress(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	switch {
+	case !ip.IsValid(),
+		ip.IsUnspecified(),
+		ip.IsLoopback(),
+		ip.IsPrivate(),
+		ip.IsLinkLocalUnicast(),
+		ip.IsLinkLocalMulticast(),
+		ip.IsInterfaceLocalMulticast(),
+		ip.IsMulticast(),
+		sharedAddressSpace.Contains(ip):
+		return false
+	}
+
+	return true
+}
+
+// guardDial rejects connections to addresses which are not public, it is called after the host is resolved,
+// so DNS names and redirects pointing to internal addresses are rejected too.
+func guardDial(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, address)
+	}
+	if !publicAddress(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, addrPort.Addr())
+	}
+
+	return nil
+}
+
+// newCallbackClient returns client posting to callback URLs of requests, they are set by API clients,
+// so only public addresses are dialed unless private are allowed.
+func newCallbackClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = guardDial
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
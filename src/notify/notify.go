@@ -0,0 +1,297 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pralolik/templgrid/pkg"
+	"github.com/pralolik/templgrid/src/helper"
+	"github.com/pralolik/templgrid/src/logging"
+	"github.com/pralolik/templgrid/src/status"
+)
+
+const (
+	DefMaxRetries   = 5
+	DefRetryBackoff = time.Second
+	DefTimeout      = 10 * time.Second
+	DefLogSize      = 1000
+	DefConcurrency  = 2
+
+	// backlog is count of events waiting for delivery, new events are dropped when it is full.
+	backlog         = 1000
+	cleanupInterval = time.Minute
+)
+
+// notified lists states sent to webhook endpoints, intermediate states are skipped.
+var notified = map[status.State]bool{
+	status.Sent:       true,
+	status.Failed:     true,
+	status.Dropped:    true,
+	status.Cancelled:  true,
+	status.Delivered:  true,
+	status.Bounced:    true,
+	status.Complained: true,
+}
+
+// Endpoint is the webhook of an API key, Secret signs its deliveries.
+type Endpoint struct {
+	URL    string
+	Secret string
+}
+
+// Event is the body posted to webhook endpoints.
+type Event struct {
+	ID           string       `json:"id"`
+	Type         string       `json:"type"`
+	MessageID    string       `json:"message_id"`
+	TemplateName string       `json:"template_name"`
+	State        status.State `json:"state"`
+	Error        string       `json:"error,omitempty"`
+	Timestamp    time.Time    `json:"timestamp"`
+}
+
+// EventType returns event type of the state, like message.sent.
+func EventType(state status.State) string {
+	return "message." + string(state)
+}
+
+type target struct {
+	endpoint     Endpoint
+	templateName string
+	// updatedAt is time of the last state change, targets of scheduled messages don't expire.
+	updatedAt time.Time
+	scheduled bool
+	// callback is true if endpoint is callback URL of the request.
+	callback bool
+	keyName  string
+}
+
+type delivery struct {
+	endpoint Endpoint
+	callback bool
+	keyName  string
+	event    Event
+}
+
+// pending returns d to be persisted, endpoint is resolved again on restore, so secrets are not stored.
+func (d delivery) pending() Pending {
+	p := Pending{Event: d.event, KeyName: d.keyName}
+	if d.callback {
+		p.CallbackURL = d.endpoint.URL
+	}
+
+	return p
+}
+
+// Notifier is the status store posting status changes of watched messages
+// to their callback URL or webhook of the API key which sent them.
+type Notifier struct {
+	status.Store
+
+	log         logging.Logger
+	client      *http.Client
+	endpoints   map[string]Endpoint
+	secret      string
+	maxRetries  int
+	backoff     time.Duration
+	concurrency int
+	ttl         time.Duration
+
+	// callbackClient posts to callback URLs of requests, it dials only public addresses unless allowPrivate.
+	callbackClient *http.Client
+	allowPrivate   bool
+
+	mu          sync.Mutex
+	targets     map[string]target
+	lastCleanup time.Time
+
+	deliveries chan delivery
+	*deliveryLog
+
+	// closing is closed by Close, workers deliver the backlog and stop.
+	closing   chan struct{}
+	closeOnce sync.Once
+	workers   sync.WaitGroup
+	cancelRun context.CancelFunc
+	// left are events which were not delivered before workers stopped.
+	leftMu sync.Mutex
+	left   []delivery
+}
+
+// NewNotifier wraps statuses, messages are watched for status.DefTTL after the last state change,
+// scheduled messages until they are released.
+func NewNotifier(log logging.Logger, statuses status.Store, options ...Option) *Notifier {
+	n := &Notifier{
+		Store:       statuses,
+		log:         log,
+		client:      &http.Client{Timeout: DefTimeout},
+		endpoints:   map[string]Endpoint{},
+		maxRetries:  DefMaxRetries,
+		backoff:     DefRetryBackoff,
+		concurrency: DefConcurrency,
+		ttl:         status.DefTTL,
+		targets:     map[string]target{},
+		deliveries:  make(chan delivery, backlog),
+		deliveryLog: newDeliveryLog(DefLogSize),
+		closing:     make(chan struct{}),
+	}
+	for _, option := range options {
+		option(n)
+	}
+	n.callbackClient = newCallbackClient(n.client.Timeout, n.allowPrivate)
+
+	return n
+}
+
+type Option func(n *Notifier)
+
+// WithEndpoints sets webhooks by API key name.
+func WithEndpoints(endpoints map[string]Endpoint) Option {
+	return func(n *Notifier) {
+		n.endpoints = endpoints
+	}
+}
+
+// WithSecret signs callback URLs of keys without own webhook secret.
+func WithSecret(secret string) Option {
+	return func(n *Notifier) {
+		n.secret = secret
+	}
+}
+
+// WithRetries sets max retries of failed deliveries and initial backoff between them.
+func WithRetries(maxRetries int, backoff time.Duration) Option {
+	return func(n *Notifier) {
+		n.maxRetries = maxRetries
+		if backoff > 0 {
+			n.backoff = backoff
+		}
+	}
+}
+
+// WithTimeout limits single delivery request.
+func WithTimeout(timeout time.Duration) Option {
+	return func(n *Notifier) {
+		if timeout > 0 {
+			n.client.Timeout = timeout
+		}
+	}
+}
+
+// WithPrivateCallbacks allows callback URLs resolving to loopback, private and link-local addresses.
+func WithPrivateCallbacks(allowed bool) Option {
+	return func(n *Notifier) {
+		n.allowPrivate = allowed
+	}
+}
+
+// WithConcurrency sets count of workers posting events in parallel.
+func WithConcurrency(workers int) Option {
+	return func(n *Notifier) {
+		if workers > 0 {
+			n.concurrency = workers
+		}
+	}
+}
+
+// WithLogSize sets count of delivery attempts kept in the delivery log.
+func WithLogSize(size int) Option {
+	return func(n *Notifier) {
+		if size > 0 {
+			n.deliveryLog = newDeliveryLog(size)
+		}
+	}
+}
+
+// Watch resolves webhook of the accepted email, callback URL has precedence over
+// webhook of the API key. Emails without both are not notified.
+func (n *Notifier) Watch(email *pkg.TemplgridEmailEntity) {
+	endpoint, ok := n.resolve(email.KeyName, email.CallbackURL)
+	if !ok {
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	now := time.Now()
+	n.cleanup(now)
+	n.targets[email.ID] = target{
+		endpoint:     endpoint,
+		templateName: email.TemplateName,
+		updatedAt:    now,
+		callback:     email.CallbackURL != "",
+		keyName:      email.KeyName,
+	}
+}
+
+// resolve returns callback URL or webhook of the API key, false if there are no both.
+func (n *Notifier) resolve(keyName, callbackURL string) (Endpoint, bool) {
+	endpoint := n.endpoints[keyName]
+	if callbackURL != "" {
+		endpoint.URL = callbackURL
+	}
+	if endpoint.Secret == "" {
+		endpoint.Secret = n.secret
+	}
+
+	return endpoint, endpoint.URL != ""
+}
+
+// Forget stops notifications of the message.
+func (n *Notifier) Forget(id string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.targets, id)
+}
+
+// SetState changes state in the wrapped store and posts notified states.
+func (n *Notifier) SetState(id string, state status.State, err error) error {
+	if setErr := n.Store.SetState(id, state, err); setErr != nil {
+		return setErr
+	}
+	n.mu.Lock()
+	t, ok := n.targets[id]
+	if ok {
+		t.updatedAt = time.Now()
+		t.scheduled = state == status.Scheduled
+		n.targets[id] = t
+	}
+	n.mu.Unlock()
+	if !ok || !notified[state] {
+		return nil
+	}
+
+	event := Event{
+		ID:           helper.NewID(),
+		Type:         EventType(state),
+		MessageID:    id,
+		TemplateName: t.templateName,
+		State:        state,
+		Timestamp:    time.Now().UTC(),
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	select {
+	case n.deliveries <- delivery{endpoint: t.endpoint, callback: t.callback, keyName: t.keyName, event: event}:
+	default:
+		n.log.Error("webhook event %s of message %s dropped, delivery backlog is full", event.Type, id)
+		n.record(Delivery{EventID: event.ID, MessageID: id, Type: event.Type, URL: t.endpoint.URL, Error: "backlog is full"})
+	}
+
+	return nil
+}
+
+func (n *Notifier) cleanup(now time.Time) {
+	if now.Sub(n.lastCleanup) < cleanupInterval {
+		return
+	}
+	n.lastCleanup = now
+	for id, t := range n.targets {
+		if !t.scheduled && now.Sub(t.updatedAt) > n.ttl {
+			delete(n.targets, id)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package notify_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pralolik/templgrid/pkg"
+	"github.com/pralolik/templgrid/src/logging"
+	"github.com/pralolik/templgrid/src/notify"
+	"github.com/pralolik/templgrid/src/status"
+)
+
+// receiver is a webhook endpoint answering with the listed codes, then 200.
+type receiver struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	codes    []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newReceiver(t *testing.T, codes ...int) *receiver {
+	r := &receiver{codes: codes}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.requests = append(r.requests, req)
+		r.bodies = append(r.bodies, body)
+		code := http.StatusOK
+		if len(r.codes) > 0 {
+			code, r.codes = r.codes[0], r.codes[1:]
+		}
+		rw.WriteHeader(code)
+	}))
+	t.Cleanup(r.Close)
+
+	return r
+}
+
+func (r *receiver) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.requests)
+}
+
+func newNotifier(t *testing.T, options ...notify.Option) *notify.Notifier {
+	options = append([]notify.Option{
+		notify.WithSecret("global-secret"),
+		notify.WithRetries(3, time.Millisecond),
+		notify.WithPrivateCallbacks(true),
+	}, options...)
+	n := notify.NewNotifier(&logging.DisabledLog{}, status.NewMemoryStore(status.DefTTL), options...)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		_ = n.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	return n
+}
+
+func send(t *testing.T, n *notify.Notifier, email *pkg.TemplgridEmailEntity, state status.State) {
+	if err := n.Create(email.ID, email.TemplateName, email.KeyName); err != nil {
+		t.Fatal(err)
+	}
+	n.Watch(email)
+	if err := n.SetState(email.ID, state, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// waitDeliveries waits until the delivery log of the message has count attempts.
+func waitDeliveries(t *testing.T, n *notify.Notifier, messageID string, count int) []notify.Delivery {
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		deliveries := n.Deliveries(messageID, 100)
+		if len(deliveries) >= count {
+			return deliveries
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d deliveries of %s, want %d", len(deliveries), messageID, count)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestDeliverySigned(t *testing.T) {
+	r := newReceiver(t)
+	n := newNotifier(t, notify.WithEndpoints(map[string]notify.Endpoint{
+		"shop": {URL: r.URL, Secret: "key-secret"},
+	}))
+
+	send(t, n, &pkg.TemplgridEmailEntity{ID: "m1", TemplateName: "welcome", KeyName: "shop"}, status.Sent)
+	waitDeliveries(t, n, "m1", 1)
+
+	req, body := r.requests[0], r.bodies[0]
+	if got := req.Header.Get(notify.EventHeader); got != "message.sent" {
+		t.Errorf("event header = %q, want message.sent", got)
+	}
+	want := notify.Sign("key-secret", req.Header.Get(notify.TimestampHeader), body)
+	if got := req.Header.Get(notify.SignatureHeader); got != want {
+		t.Errorf("signature = %q, want %q", got, want)
+	}
+	var event notify.Event
+	if err := json.Unmarshal(body, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.MessageID != "m1" || event.TemplateName != "welcome" || event.State != status.Sent {
+		t.Errorf("unexpected event %+v", event)
+	}
+	if req.Header.Get(notify.DeliveryHeader) != event.ID {
+		t.Errorf("delivery header = %q, want event id %q", req.Header.Get(notify.DeliveryHeader), event.ID)
+	}
+}
+
+func TestDeliveryIntermediateStatesSkipped(t *testing.T) {
+	r := newReceiver(t)
+	n := newNotifier(t, notify.WithEndpoints(map[string]notify.Endpoint{"shop": {URL: r.URL}}))
+
+	send(t, n, &pkg.TemplgridEmailEntity{ID: "m1", KeyName: "shop"}, status.Scheduled)
+	if err := n.SetState("m1", status.Sent, nil); err != nil {
+		t.Fatal(err)
+	}
+	deliveries := waitDeliveries(t, n, "m1", 1)
+	if len(deliveries) != 1 || deliveries[0].Type != "message.sent" {
+		t.Errorf("unexpected deliveries %+v", deliveries)
+	}
+}
+
+func TestDeliveryRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		codes     []int
+		attempts  int
+		delivered bool
+	}{
+		{name: "server errors are retried", codes: []int{500, 503}, attempts: 3, delivered: true},
+		{name: "rate limit is retried", codes: []int{429}, attempts: 2, delivered: true},
+		{name: "client error is permanent", codes: []int{400}, attempts: 1},
+		{name: "retries are limited", codes: []int{500, 500, 500, 500, 500}, attempts: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newReceiver(t, tt.codes...)
+			n := newNotifier(t)
+
+			send(t, n, &pkg.TemplgridEmailEntity{ID: "m1", CallbackURL: r.URL}, status.Failed)
+			deliveries := waitDeliveries(t, n, "m1", tt.attempts)
+			time.Sleep(20 * time.Millisecond)
+			if got := r.count(); got != tt.attempts {
+				t.Fatalf("got %d attempts, want %d", got, tt.attempts)
+			}
+			if deliveries[0].Delivered != tt.delivered {
+				t.Errorf("last attempt delivered = %v, want %v", deliveries[0].Delivered, tt.delivered)
+			}
+			if deliveries[0].Attempt != tt.attempts {
+				t.Errorf("last attempt = %d, want %d", deliveries[0].Attempt, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestCallbackPrecedence(t *testing.T) {
+	keyHook := newReceiver(t)
+	callback := newReceiver(t)
+	n := newNotifier(t, notify.WithEndpoints(map[string]notify.Endpoint{"shop": {URL: keyHook.URL}}))
+
+	send(t, n, &pkg.TemplgridEmailEntity{ID: "m1", KeyName: "shop", CallbackURL: callback.URL}, status.Sent)
+	send(t, n, &pkg.TemplgridEmailEntity{ID: "m2", KeyName: "shop"}, status.Sent)
+	send(t, n, &pkg.TemplgridEmailEntity{ID: "m3", KeyName: "other"}, status.Sent)
+	waitDeliveries(t, n, "m1", 1)
+	waitDeliveries(t, n, "m2", 1)
+
+	if callback.count() != 1 || keyHook.count() != 1 {
+		t.Fatalf("callback got %d events, key webhook got %d, want 1 and 1", callback.count(), keyHook.count())
+	}
+	if !strings.Contains(string(callback.bodies[0]), `"message_id":"m1"`) {
+		t.Errorf("callback got %s, want event of m1", callback.bodies[0])
+	}
+	// callback of a key without own secret is signed by the notifications secret.
+	req := callback.requests[0]
+	if req.Header.Get(notify.SignatureHeader) != notify.Sign("global-secret", req.Header.Get(notify.TimestampHeader), callback.bodies[0]) {
+		t.Error("callback is not signed by the notifications secret")
+	}
+	if len(n.Deliveries("m3", 100)) != 0 {
+		t.Error("message of key without webhook is notified")
+	}
+}
+
+func TestCallbackPrivateAddressRejected(t *testing.T) {
+	r := newReceiver(t)
+	n := newNotifier(t, notify.WithPrivateCallbacks(false))
+
+	send(t, n, &pkg.TemplgridEmailEntity{ID: "m1", CallbackURL: r.URL}, status.Sent)
+	deliveries := waitDeliveries(t, n, "m1", 1)
+	time.Sleep(20 * time.Millisecond)
+	if r.count() != 0 {
+		t.Fatal("callback on loopback address is called")
+	}
+	if len(n.Deliveries("m1", 100)) != 1 || !strings.Contains(deliveries[0].Error, notify.ErrForbiddenAddress.Error()) {
+		t.Errorf("unexpected deliveries %+v", deliveries)
+	}
+}
+
+func TestClosePendingRestored(t *testing.T) {
+	down := newReceiver(t, 500, 500, 500, 500)
+	n := newNotifier(t, notify.WithRetries(3, time.Hour))
+
+	send(t, n, &pkg.TemplgridEmailEntity{ID: "m1", KeyName: "shop", CallbackURL: down.URL}, status.Bounced)
+	waitDeliveries(t, n, "m1", 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	pending := n.Close(ctx)
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		t.Fatal("close returned before the retry")
+	}
+	if len(pending) != 1 || pending[0].Event.MessageID != "m1" || pending[0].CallbackURL != down.URL || pending[0].KeyName != "shop" {
+		t.Fatalf("unexpected pending events %+v", pending)
+	}
+
+	up := newReceiver(t)
+	pending[0].CallbackURL = up.URL
+	restarted := newNotifier(t)
+	restarted.Restore(pending)
+	waitDeliveries(t, restarted, "m1", 1)
+	if up.count() != 1 || !strings.Contains(string(up.bodies[0]), `"state":"bounced"`) {
+		t.Errorf("restored event is not delivered")
+	}
+}
+
+func TestCloseDeliversBacklog(t *testing.T) {
+	r := newReceiver(t)
+	n := newNotifier(t, notify.WithConcurrency(1))
+
+	// the first delivery makes sure workers are started.
+	send(t, n, &pkg.TemplgridEmailEntity{ID: "m0", CallbackURL: r.URL}, status.Sent)
+	waitDeliveries(t, n, "m0", 1)
+	for _, id := range []string{"m1", "m2", "m3"} {
+		send(t, n, &pkg.TemplgridEmailEntity{ID: id, CallbackURL: r.URL}, status.Sent)
+	}
+	if pending := n.Close(context.Background()); len(pending) != 0 {
+		t.Fatalf("got %d pending events, want 0", len(pending))
+	}
+	if r.count() != 4 {
+		t.Errorf("got %d events, want 4", r.count())
+	}
+}
@@ -0,0 +1,56 @@
+package notify
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// SaveFile writes events to path as JSON lines, so they can be restored by LoadFile.
+func SaveFile(path string, pending []Pending) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("can't open webhook events file: %w ", err)
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, p := range pending {
+		if err = enc.Encode(p); err != nil {
+			_ = f.Close()
+			return fmt.Errorf("can't write webhook events file: %w ", err)
+		}
+	}
+	if err = w.Flush(); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("can't write webhook events file: %w ", err)
+	}
+
+	return f.Close()
+}
+
+// LoadFile reads events saved by SaveFile, missing file means no events.
+func LoadFile(path string) ([]Pending, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't open webhook events file: %w ", err)
+	}
+	defer f.Close()
+
+	var pending []Pending
+	dec := json.NewDecoder(f)
+	for dec.More() {
+		var p Pending
+		if err = dec.Decode(&p); err != nil {
+			return pending, fmt.Errorf("can't read webhook events file: %w ", err)
+		}
+		pending = append(pending, p)
+	}
+
+	return pending, nil
+}
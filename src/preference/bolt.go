@@ -0,0 +1,64 @@
+package preference
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var preferencesBucket = []byte("preferences")
+
+// BoltStore keeps preferences in bbolt file.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+func NewBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("can't open preference db: %w ", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(preferencesBucket)
+		return err
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("can't create preference bucket: %w ", err)
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+func (s *BoltStore) Get(address string) (*Preferences, error) {
+	prefs := &Preferences{Address: address}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(preferencesBucket).Get([]byte(address))
+		if v == nil {
+			return nil
+		}
+		return json.Unmarshal(v, prefs)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("can't get preferences: %w ", err)
+	}
+
+	return prefs, nil
+}
+
+func (s *BoltStore) Set(prefs Preferences) error {
+	v, err := json.Marshal(prefs)
+	if err != nil {
+		return fmt.Errorf("can't marshal preferences: %w ", err)
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(preferencesBucket).Put([]byte(prefs.Address), v)
+	})
+}
+
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,39 @@
+package preference
+
+import (
+	"sync"
+)
+
+// MemoryStore keeps preferences in memory, they are lost on restart.
+type MemoryStore struct {
+	mu    sync.RWMutex
+	prefs map[string]Preferences
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{prefs: map[string]Preferences{}}
+}
+
+func (s *MemoryStore) Get(address string) (*Preferences, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	prefs, ok := s.prefs[address]
+	if !ok {
+		return &Preferences{Address: address}, nil
+	}
+	prefs.OptedOut = append([]string(nil), prefs.OptedOut...)
+
+	return &prefs, nil
+}
+
+func (s *MemoryStore) Set(prefs Preferences) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prefs[prefs.Address] = prefs
+
+	return nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,143 @@
+package preference
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pralolik/templgrid/pkg"
+	"github.com/pralolik/templgrid/src/suppression"
+	"github.com/pralolik/templgrid/src/templatemanager"
+)
+
+// ErrInvalid is returned for preferences without address or with unknown categories.
+var ErrInvalid = errors.New("invalid preferences")
+
+// Category is the group of templates recipients may opt out of,
+// transactional categories are always sent.
+type Category struct {
+	Name          string `json:"name"`
+	Title         string `json:"title"`
+	Transactional bool   `json:"transactional"`
+}
+
+// Preferences holds categories the recipient opted out of.
+type Preferences struct {
+	Address   string    `json:"address"`
+	OptedOut  []string  `json:"opted_out"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Allows reports whether the recipient receives emails of the category.
+func (p *Preferences) Allows(category string) bool {
+	return !slices.Contains(p.OptedOut, category)
+}
+
+// Store represents storage of preferences keyed by normalized address.
+type Store interface {
+	// Get returns preferences of the address, empty preferences if there are none.
+	Get(address string) (*Preferences, error)
+	// Set replaces preferences of the address.
+	Set(prefs Preferences) error
+	Close() error
+}
+
+// Center holds configured categories and applies them to preferences.
+type Center struct {
+	store      Store
+	categories []Category
+}
+
+func NewCenter(store Store, categories []Category) *Center {
+	return &Center{store: store, categories: categories}
+}
+
+// Categories returns configured categories in the order of configuration.
+func (c *Center) Categories() []Category {
+	return c.categories
+}
+
+// Optional reports whether the category is configured and not transactional.
+func (c *Center) Optional(category string) bool {
+	for _, cat := range c.categories {
+		if cat.Name == category {
+			return !cat.Transactional
+		}
+	}
+
+	return false
+}
+
+// Transactional reports whether the category is configured as transactional.
+func (c *Center) Transactional(category string) bool {
+	for _, cat := range c.categories {
+		if cat.Name == category {
+			return cat.Transactional
+		}
+	}
+
+	return false
+}
+
+// Get returns preferences of the address.
+func (c *Center) Get(address string) (*Preferences, error) {
+	return c.store.Get(suppression.NormalizeAddress(address))
+}
+
+// Update stores opt-outs of the address, transactional and unknown categories are rejected.
+func (c *Center) Update(address string, optedOut []string) error {
+	address = suppression.NormalizeAddress(address)
+	if address == "" || !strings.Contains(address, "@") {
+		return fmt.Errorf("%w: incorrect address", ErrInvalid)
+	}
+	for _, category := range optedOut {
+		if !c.Optional(category) {
+			return fmt.Errorf("%w: category %s can't be opted out", ErrInvalid, category)
+		}
+	}
+	optedOut = slices.Clone(optedOut)
+	slices.Sort(optedOut)
+
+	return c.store.Set(Preferences{
+		Address:   address,
+		OptedOut:  slices.Compact(optedOut),
+		UpdatedAt: time.Now().UTC(),
+	})
+}
+
+// OptOut adds the category to opt-outs of the address.
+func (c *Center) OptOut(address, category string) error {
+	prefs, err := c.Get(address)
+	if err != nil {
+		return err
+	}
+
+	return c.Update(address, append(prefs.OptedOut, category))
+}
+
+// Filter drops recipients opted out of the template category, implements sendgrid.RecipientFilter.
+type Filter struct {
+	center  *Center
+	storage *templatemanager.EmailStorage
+}
+
+func NewFilter(center *Center, storage *templatemanager.EmailStorage) *Filter {
+	return &Filter{center: center, storage: storage}
+}
+
+// Allow returns drop reason if the address opted out of the template category.
+// Addresses are allowed if the store fails, so preference outage doesn't stop sending.
+func (f *Filter) Allow(email *pkg.TemplgridEmailEntity, address string) string {
+	category := f.storage.Options(email.TemplateName).Category
+	if !f.center.Optional(category) {
+		return ""
+	}
+	prefs, err := f.center.Get(address)
+	if err != nil || prefs.Allows(category) {
+		return ""
+	}
+
+	return "opted out of " + category
+}
@@ -0,0 +1,134 @@
+package preference_test
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/pralolik/templgrid/pkg"
+	"github.com/pralolik/templgrid/src/preference"
+	"github.com/pralolik/templgrid/src/templatemanager"
+)
+
+var categories = []preference.Category{
+	{Name: "newsletters", Title: "Newsletters"},
+	{Name: "tips", Title: "Tips"},
+	{Name: "receipts", Title: "Receipts", Transactional: true},
+}
+
+// stores runs the test against every store implementation.
+func stores(t *testing.T, test func(t *testing.T, s preference.Store)) {
+	t.Run("memory", func(t *testing.T) {
+		test(t, preference.NewMemoryStore())
+	})
+	t.Run("bolt", func(t *testing.T) {
+		s, err := preference.NewBoltStore(filepath.Join(t.TempDir(), "preferences.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = s.Close() })
+		test(t, s)
+	})
+}
+
+func TestStore(t *testing.T) {
+	stores(t, func(t *testing.T, s preference.Store) {
+		prefs, err := s.Get("john@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if prefs.Address != "john@example.com" || len(prefs.OptedOut) != 0 {
+			t.Errorf("preferences of unknown address = %+v, want empty", prefs)
+		}
+
+		if err = s.Set(preference.Preferences{Address: "john@example.com", OptedOut: []string{"tips"}}); err != nil {
+			t.Fatal(err)
+		}
+		prefs, err = s.Get("john@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(prefs.OptedOut, []string{"tips"}) || prefs.Allows("tips") || !prefs.Allows("newsletters") {
+			t.Errorf("preferences = %+v, want opted out of tips", prefs)
+		}
+		// returned preferences are copies.
+		prefs.OptedOut[0] = "newsletters"
+		if prefs, _ = s.Get("john@example.com"); prefs.OptedOut[0] != "tips" {
+			t.Errorf("stored preferences are changed through the copy: %+v", prefs)
+		}
+	})
+}
+
+func TestCenterUpdate(t *testing.T) {
+	stores(t, func(t *testing.T, s preference.Store) {
+		center := preference.NewCenter(s, categories)
+		if err := center.Update(" John@Example.com ", []string{"tips", "newsletters", "tips"}); err != nil {
+			t.Fatal(err)
+		}
+		prefs, err := center.Get("john@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(prefs.OptedOut, []string{"newsletters", "tips"}) || prefs.UpdatedAt.IsZero() {
+			t.Errorf("preferences = %+v, want sorted opt-outs of normalized address", prefs)
+		}
+
+		for name, optedOut := range map[string][]string{
+			"transactional": {"tips", "receipts"},
+			"unknown":       {"promotions"},
+		} {
+			if err := center.Update("john@example.com", optedOut); !errors.Is(err, preference.ErrInvalid) {
+				t.Errorf("opt-out of %s category error = %v, want ErrInvalid", name, err)
+			}
+		}
+		if err := center.Update("john", nil); !errors.Is(err, preference.ErrInvalid) {
+			t.Errorf("incorrect address error = %v, want ErrInvalid", err)
+		}
+		if err := center.OptOut("john@example.com", "receipts"); !errors.Is(err, preference.ErrInvalid) {
+			t.Errorf("opt-out of transactional category error = %v, want ErrInvalid", err)
+		}
+		if prefs, _ = center.Get("john@example.com"); !slices.Equal(prefs.OptedOut, []string{"newsletters", "tips"}) {
+			t.Errorf("rejected updates changed preferences: %+v", prefs)
+		}
+
+		if err := center.Update("jane@example.org", nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := center.OptOut("jane@example.org", "tips"); err != nil {
+			t.Fatal(err)
+		}
+		if prefs, _ = center.Get("jane@example.org"); !slices.Equal(prefs.OptedOut, []string{"tips"}) {
+			t.Errorf("preferences after opt-out = %+v, want tips", prefs)
+		}
+	})
+}
+
+func TestFilter(t *testing.T) {
+	storage := templatemanager.NewEmailStorage()
+	storage.AddOptions("news", templatemanager.TemplateOptions{Category: "newsletters"})
+	storage.AddOptions("receipt", templatemanager.TemplateOptions{Category: "receipts"})
+	center := preference.NewCenter(preference.NewMemoryStore(), categories)
+	if err := center.Update("john@example.com", []string{"newsletters"}); err != nil {
+		t.Fatal(err)
+	}
+	filter := preference.NewFilter(center, storage)
+
+	tests := []struct {
+		template string
+		address  string
+		want     string
+	}{
+		{"news", "John@Example.com", "opted out of newsletters"},
+		{"news", "jane@example.org", ""},
+		{"receipt", "john@example.com", ""},
+		// templates without category are always sent.
+		{"welcome", "john@example.com", ""},
+	}
+	for _, tt := range tests {
+		email := &pkg.TemplgridEmailEntity{TemplateName: tt.template}
+		if got := filter.Allow(email, tt.address); got != tt.want {
+			t.Errorf("%s to %s = %q, want %q", tt.template, tt.address, got, tt.want)
+		}
+	}
+}
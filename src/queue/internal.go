@@ -2,25 +2,192 @@ package queue
 
 import (
 	"context"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/pralolik/templgrid/pkg"
 	"github.com/pralolik/templgrid/src/logging"
+	"github.com/pralolik/templgrid/src/metrics"
 )
 
+const (
+	DefCapacity    = 10000
+	DefPushTimeout = 100 * time.Millisecond
+)
+
+// DefWeights are shares of dequeued emails of each priority while all lanes are busy.
+var DefWeights = map[pkg.Priority]int{
+	pkg.PriorityHigh:   8,
+	pkg.PriorityNormal: 4,
+	pkg.PriorityBulk:   1,
+}
+
+// InternalQueue keeps a lane per priority. Dispatcher moves emails from lanes
+// to the consumer channel by smooth weighted round-robin, so bulk emails
+// never block high priority ones and still make progress.
 type InternalQueue struct {
 	log          logging.Logger
+	lanes        []*lane
 	queueChannel chan *pkg.TemplgridEmailEntity
+	capacity     int
+	weights      map[pkg.Priority]int
+	pushTimeout  time.Duration
+	metrics      *metrics.Metrics
+
+	// slots limits emails waiting in all lanes and held by dispatcher to capacity, a slot is taken by Push
+	// and freed when the email is taken by consumers.
+	slots chan struct{}
+
+	// mu guards closed, so nothing is pushed to lanes after Close started.
+	mu      sync.RWMutex
+	closed  bool
+	closing chan struct{}
+	// stop aborts dispatcher, done is closed when dispatcher exits.
+	stop chan struct{}
+	done chan struct{}
+	// held is taken from a lane, but not by consumers yet.
+	held    atomic.Pointer[pkg.TemplgridEmailEntity]
+	holding atomic.Int32
+}
+
+type lane struct {
+	priority pkg.Priority
+	weight   int
+	current  int
+	ch       chan *pkg.TemplgridEmailEntity
 }
 
-func NewInternalQueue(log logging.Logger) *InternalQueue {
-	return &InternalQueue{
+func NewInternalQueue(log logging.Logger, options ...Option) *InternalQueue {
+	q := &InternalQueue{
 		log:          log,
 		queueChannel: make(chan *pkg.TemplgridEmailEntity),
+		capacity:     DefCapacity,
+		weights:      DefWeights,
+		pushTimeout:  DefPushTimeout,
+		closing:      make(chan struct{}),
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	for _, option := range options {
+		option(q)
+	}
+	q.slots = make(chan struct{}, q.capacity)
+	for _, priority := range pkg.Priorities {
+		weight := q.weights[priority]
+		if weight <= 0 {
+			weight = DefWeights[priority]
+		}
+		q.lanes = append(q.lanes, &lane{
+			priority: priority,
+			weight:   weight,
+			ch:       make(chan *pkg.TemplgridEmailEntity, q.capacity),
+		})
+	}
+	go q.dispatch()
+
+	return q
+}
+
+type Option func(q *InternalQueue)
+
+// WithMetrics reports enqueued emails.
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(q *InternalQueue) {
+		q.metrics = m
 	}
 }
 
+// WithCapacity sets max count of emails waiting in all priority lanes.
+func WithCapacity(capacity int) Option {
+	return func(q *InternalQueue) {
+		if capacity > 0 {
+			q.capacity = capacity
+		}
+	}
+}
+
+// WithWeights sets shares of dequeued emails per priority, missing priorities use DefWeights.
+func WithWeights(weights map[pkg.Priority]int) Option {
+	return func(q *InternalQueue) {
+		if len(weights) > 0 {
+			q.weights = weights
+		}
+	}
+}
+
+// WithPushTimeout sets how long Push waits for free space in the full lane,
+// 0 rejects immediately.
+func WithPushTimeout(timeout time.Duration) Option {
+	return func(q *InternalQueue) {
+		if timeout >= 0 {
+			q.pushTimeout = timeout
+		}
+	}
+}
+
+// Push adds email to the lane of its priority,
+// returns ErrFull if the queue has no space within push timeout.
 func (q *InternalQueue) Push(entity *pkg.TemplgridEmailEntity) error {
-	go func() { q.queueChannel <- entity }()
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return ErrClosed
+	}
+
+	if !q.takeSlot() {
+		return ErrFull
+	}
+	// every lane fits capacity, so the slot guarantees space in the lane.
+	q.lane(entity.Priority).ch <- entity
+	q.metrics.Enqueued()
+
+	return nil
+}
+
+// takeSlot takes a slot within push timeout, returns false if the queue is full.
+func (q *InternalQueue) takeSlot() bool {
+	select {
+	case q.slots <- struct{}{}:
+		return true
+	default:
+	}
+	if q.pushTimeout == 0 {
+		return false
+	}
+	timer := time.NewTimer(q.pushTimeout)
+	defer timer.Stop()
+	select {
+	case q.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// Len returns count of emails waiting to be taken from the queue.
+func (q *InternalQueue) Len() int {
+	n := int(q.holding.Load())
+	for _, l := range q.lanes {
+		n += len(l.ch)
+	}
+
+	return n
+}
+
+// Cap returns max count of emails waiting in all priority lanes.
+func (q *InternalQueue) Cap() int {
+	return q.capacity
+}
+
+// Health returns ErrClosed after the queue is closed.
+func (q *InternalQueue) Health(_ context.Context) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return ErrClosed
+	}
+
 	return nil
 }
 
@@ -30,6 +197,135 @@ func (q *InternalQueue) GetChannel() (chan *pkg.TemplgridEmailEntity, error) {
 
 func (q *InternalQueue) Run(ctx context.Context) error {
 	<-ctx.Done()
-	close(q.queueChannel)
 	return nil
 }
+
+// Close stops accepting emails and waits until queued emails are taken by consumers.
+// Emails not taken before ctx is done are returned. The channel is closed afterwards,
+// so consumers stop once they finish in-flight emails.
+func (q *InternalQueue) Close(ctx context.Context) []*pkg.TemplgridEmailEntity {
+	// waits for blocked pushes, which are bounded by push timeout.
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return nil
+	}
+	q.closed = true
+	close(q.closing)
+	q.mu.Unlock()
+
+	var leftovers []*pkg.TemplgridEmailEntity
+	select {
+	case <-q.done:
+	case <-ctx.Done():
+		close(q.stop)
+		<-q.done
+		if held := q.held.Load(); held != nil {
+			leftovers = append(leftovers, held)
+		}
+		leftovers = append(leftovers, q.takeAll()...)
+	}
+	close(q.queueChannel)
+
+	return leftovers
+}
+
+// dispatch moves emails from lanes to the consumer channel until lanes are drained
+// after Close or dispatching is stopped.
+func (q *InternalQueue) dispatch() {
+	defer close(q.done)
+	for {
+		entity, ok := q.next()
+		if !ok {
+			return
+		}
+		select {
+		case q.queueChannel <- entity:
+			q.holding.Store(0)
+			<-q.slots
+		case <-q.stop:
+			q.held.Store(entity)
+			q.holding.Store(0)
+			return
+		}
+	}
+}
+
+// next takes email from the lane picked by weighted round-robin,
+// waits for an email if all lanes are empty. Returns false if the queue
+// is closed and drained or dispatching is stopped.
+func (q *InternalQueue) next() (*pkg.TemplgridEmailEntity, bool) {
+	for {
+		if l := q.pick(); l != nil {
+			return q.taken(<-l.ch), true
+		}
+		select {
+		case <-q.closing:
+			// nothing is pushed after closing, so empty lanes mean the queue is drained.
+			if q.pick() == nil {
+				return nil, false
+			}
+		case <-q.stop:
+			return nil, false
+		// lanes follow pkg.Priorities.
+		case entity := <-q.lanes[0].ch:
+			return q.taken(entity), true
+		case entity := <-q.lanes[1].ch:
+			return q.taken(entity), true
+		case entity := <-q.lanes[2].ch:
+			return q.taken(entity), true
+		}
+	}
+}
+
+// taken marks email taken from its lane as held by dispatcher.
+func (q *InternalQueue) taken(entity *pkg.TemplgridEmailEntity) *pkg.TemplgridEmailEntity {
+	q.holding.Store(1)
+
+	return entity
+}
+
+// pick returns non-empty lane by smooth weighted round-robin, nil if all lanes are empty.
+func (q *InternalQueue) pick() *lane {
+	var picked *lane
+	total := 0
+	for _, l := range q.lanes {
+		if len(l.ch) == 0 {
+			continue
+		}
+		l.current += l.weight
+		total += l.weight
+		if picked == nil || l.current > picked.current {
+			picked = l
+		}
+	}
+	if picked != nil {
+		picked.current -= total
+	}
+
+	return picked
+}
+
+func (q *InternalQueue) lane(priority pkg.Priority) *lane {
+	for _, l := range q.lanes {
+		if l.priority == priority {
+			return l
+		}
+	}
+
+	// empty priority and priorities from older persisted emails.
+	return q.lane(pkg.PriorityNormal)
+}
+
+// takeAll takes emails left in lanes, should be called after dispatcher is stopped.
+func (q *InternalQueue) takeAll() []*pkg.TemplgridEmailEntity {
+	var leftovers []*pkg.TemplgridEmailEntity
+	for _, l := range q.lanes {
+		for len(l.ch) > 0 {
+			leftovers = append(leftovers, <-l.ch)
+			<-q.slots
+		}
+	}
+
+	return leftovers
+}
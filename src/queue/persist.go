@@ -0,0 +1,58 @@
+package queue
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/pralolik/templgrid/pkg"
+)
+
+// SaveFile writes emails to path as JSON lines, so they can be restored by LoadFile.
+func SaveFile(path string, emails []*pkg.TemplgridEmailEntity) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("can't open queue file: %w ", err)
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, email := range emails {
+		if err = enc.Encode(email); err != nil {
+			_ = f.Close()
+			return fmt.Errorf("can't write queue file: %w ", err)
+		}
+	}
+	if err = w.Flush(); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("can't write queue file: %w ", err)
+	}
+
+	return f.Close()
+}
+
+// LoadFile reads emails saved by SaveFile, missing file means no emails.
+func LoadFile(path string) ([]*pkg.TemplgridEmailEntity, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't open queue file: %w ", err)
+	}
+	defer f.Close()
+
+	var emails []*pkg.TemplgridEmailEntity
+	dec := json.NewDecoder(f)
+	for dec.More() {
+		var email pkg.TemplgridEmailEntity
+		if err = dec.Decode(&email); err != nil {
+			return emails, fmt.Errorf("can't read queue file: %w ", err)
+		}
+		emails = append(emails, &email)
+	}
+
+	return emails, nil
+}
@@ -2,12 +2,53 @@ package queue
 
 import (
 	"context"
+	"errors"
+	"fmt"
 
 	"github.com/pralolik/templgrid/pkg"
 )
 
+var (
+	// ErrClosed is returned when the queue is stopped.
+	ErrClosed = errors.New("queue is closed")
+	// ErrFull is returned by Push when the queue has no space.
+	ErrFull = errors.New("queue is full")
+	// ErrTooDeep is returned by DepthChecker when the queue is over the threshold.
+	ErrTooDeep = errors.New("queue is over depth threshold")
+)
+
 type Interface interface {
 	Push(entity *pkg.TemplgridEmailEntity) error
 	GetChannel() (chan *pkg.TemplgridEmailEntity, error)
 	Run(ctx context.Context) error
+	// Len returns count of emails waiting in the queue.
+	Len() int
+	// Health returns error if the queue backend is not reachable.
+	Health(ctx context.Context) error
+	// Close stops accepting emails and drains the queue until ctx is done.
+	// Returns emails left in the queue, which should be persisted by the caller.
+	Close(ctx context.Context) []*pkg.TemplgridEmailEntity
+}
+
+// NewDepthChecker returns health checker of the queue reachability and depth.
+// maxDepth 0 disables depth check.
+func NewDepthChecker(q Interface, maxDepth int) *DepthChecker {
+	return &DepthChecker{queue: q, maxDepth: maxDepth}
+}
+
+// DepthChecker checks that queue is reachable and not stuck.
+type DepthChecker struct {
+	queue    Interface
+	maxDepth int
+}
+
+func (c *DepthChecker) Health(ctx context.Context) error {
+	if err := c.queue.Health(ctx); err != nil {
+		return err
+	}
+	if depth := c.queue.Len(); c.maxDepth > 0 && depth > c.maxDepth {
+		return fmt.Errorf("%w: %d > %d", ErrTooDeep, depth, c.maxDepth)
+	}
+
+	return nil
 }
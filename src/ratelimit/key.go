@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const idleTTL = 10 * time.Minute
+
+// KeyLimiter holds token bucket per key.
+type KeyLimiter struct {
+	mu          sync.Mutex
+	limit       rate.Limit
+	burst       int
+	buckets     map[string]*bucket
+	lastCleanup time.Time
+}
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// NewKeyLimiter returns limiter allowing rps requests per second per key with burst.
+func NewKeyLimiter(rps float64, burst int) *KeyLimiter {
+	return &KeyLimiter{
+		limit:   rate.Limit(rps),
+		burst:   burst,
+		buckets: map[string]*bucket{},
+	}
+}
+
+// Allow takes token from the key bucket.
+// Returns false and time until the next token if the bucket is empty.
+func (l *KeyLimiter) Allow(key string) (bool, time.Duration) {
+	return l.AllowN(key, 1)
+}
+
+// AllowN takes n tokens from the key bucket, none are taken if there are less than n.
+// Returns false and time until n tokens are available, a second if n exceeds the burst.
+func (l *KeyLimiter) AllowN(key string, n int) (bool, time.Duration) {
+	now := time.Now()
+	b := l.get(key, now)
+
+	res := b.limiter.ReserveN(now, n)
+	if !res.OK() {
+		return false, time.Second
+	}
+	if delay := res.DelayFrom(now); delay > 0 {
+		res.CancelAt(now)
+		return false, delay
+	}
+
+	return true, 0
+}
+
+func (l *KeyLimiter) get(key string, now time.Time) *bucket {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastCleanup) > idleTTL {
+		l.lastCleanup = now
+		for k, b := range l.buckets {
+			if now.Sub(b.lastSeen) > idleTTL {
+				delete(l.buckets, k)
+			}
+		}
+	}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.buckets[key] = b
+	}
+	b.lastSeen = now
+
+	return b
+}
@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pralolik/templgrid/pkg"
+)
+
+// ReasonRecipientLimit is the drop reason of recipients over the limit.
+const ReasonRecipientLimit = "recipient rate limit exceeded"
+
+// RecipientLimiter allows at most limit emails of the template
+// to the address within sliding window.
+type RecipientLimiter struct {
+	mu          sync.Mutex
+	limit       int
+	window      time.Duration
+	sent        map[string][]time.Time
+	lastCleanup time.Time
+}
+
+func NewRecipientLimiter(limit int, window time.Duration) *RecipientLimiter {
+	return &RecipientLimiter{
+		limit:  limit,
+		window: window,
+		sent:   map[string][]time.Time{},
+	}
+}
+
+// Allow implements sendgrid.RecipientFilter.
+// Counts the email if the recipient is under the limit, the count is refunded if the email isn't sent.
+func (l *RecipientLimiter) Allow(email *pkg.TemplgridEmailEntity, address string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastCleanup) > l.window {
+		l.lastCleanup = now
+		for key, times := range l.sent {
+			if len(l.prune(times, now)) == 0 {
+				delete(l.sent, key)
+			}
+		}
+	}
+
+	key := recipientKey(email, address)
+	times := l.prune(l.sent[key], now)
+	if len(times) >= l.limit {
+		l.sent[key] = times
+		return ReasonRecipientLimit
+	}
+	l.sent[key] = append(times, now)
+
+	return ""
+}
+
+// Refund implements sendgrid.RefundFilter, it uncounts the latest email to the recipient.
+func (l *RecipientLimiter) Refund(email *pkg.TemplgridEmailEntity, address string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := recipientKey(email, address)
+	if times := l.sent[key]; len(times) > 0 {
+		l.sent[key] = times[:len(times)-1]
+	}
+}
+
+func recipientKey(email *pkg.TemplgridEmailEntity, address string) string {
+	return fmt.Sprintf("%s|%s", email.TemplateName, strings.ToLower(address))
+}
+
+func (l *RecipientLimiter) prune(times []time.Time, now time.Time) []time.Time {
+	i := 0
+	for i < len(times) && now.Sub(times[i]) > l.window {
+		i++
+	}
+
+	return times[i:]
+}
@@ -0,0 +1,59 @@
+package redact
+
+import (
+	"fmt"
+
+	"github.com/pralolik/templgrid/src/logging"
+)
+
+// Log redacts messages and fields before passing them to the wrapped logger.
+type Log struct {
+	log      logging.Logger
+	redactor *Redactor
+}
+
+// NewLog returns logger redacting everything logged by r.
+func NewLog(log logging.Logger, r *Redactor) *Log {
+	return &Log{log: log, redactor: r}
+}
+
+// Debug and Info format and redact messages only if the wrapped logger prints them,
+// so disabled debug logging on the send path costs nothing.
+func (l *Log) Debug(format string, v ...interface{}) {
+	if l.Enabled(logging.DBG) {
+		l.log.Debug("%s", l.message(format, v))
+	}
+}
+
+func (l *Log) Info(format string, v ...interface{}) {
+	if l.Enabled(logging.INF) {
+		l.log.Info("%s", l.message(format, v))
+	}
+}
+
+func (l *Log) Error(format string, v ...interface{}) {
+	if l.Enabled(logging.ERR) {
+		l.log.Error("%s", l.message(format, v))
+	}
+}
+
+func (l *Log) Enabled(level logging.Level) bool {
+	return logging.Enabled(l.log, level)
+}
+
+func (l *Log) With(keyvals ...interface{}) logging.Logger {
+	redacted := make([]interface{}, len(keyvals))
+	for i, kv := range keyvals {
+		if i%2 == 0 {
+			redacted[i] = kv
+			continue
+		}
+		redacted[i] = l.redactor.String(fmt.Sprint(kv))
+	}
+
+	return &Log{log: logging.With(l.log, redacted...), redactor: l.redactor}
+}
+
+func (l *Log) message(format string, v []interface{}) string {
+	return l.redactor.String(fmt.Sprintf(format, v...))
+}
@@ -0,0 +1,36 @@
+package redact
+
+import (
+	"testing"
+
+	"github.com/pralolik/templgrid/src/logging"
+)
+
+// countedArg counts how many times it is formatted.
+type countedArg struct{ formatted *int }
+
+func (a countedArg) String() string {
+	*a.formatted++
+	return "john@example.com"
+}
+
+func TestLogSkipsDisabledLevels(t *testing.T) {
+	formatted := 0
+	log := NewLog(logging.NewStdLog(logging.ERR), New(nil, nil))
+
+	log.Debug("response for %s", countedArg{&formatted})
+	log.Info("sent to %s", countedArg{&formatted})
+	if formatted != 0 {
+		t.Errorf("disabled messages are formatted %d times", formatted)
+	}
+	log.Error("failed for %s", countedArg{&formatted})
+	if formatted != 1 {
+		t.Errorf("error message is formatted %d times, want 1", formatted)
+	}
+
+	withFields := logging.With(log, logging.MessageIDKey, "m1")
+	withFields.Debug("response for %s", countedArg{&formatted})
+	if formatted != 1 {
+		t.Error("disabled message of logger with fields is formatted")
+	}
+}
@@ -0,0 +1,92 @@
+package redact
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const Mask = "[REDACTED]"
+
+var (
+	emailRe = regexp.MustCompile(`([A-Za-z0-9._%+\-])[A-Za-z0-9._%+\-]*@([A-Za-z0-9.\-]+\.[A-Za-z]{2,})`)
+	// bearerRe and sendGridKeyRe catch credentials which are not configured, e.g. of other services.
+	bearerRe      = regexp.MustCompile(`(?i)(bearer\s+)[^\s"',}\]]+`)
+	sendGridKeyRe = regexp.MustCompile(`SG\.[A-Za-z0-9_\-]{8,}\.[A-Za-z0-9_\-]{8,}`)
+)
+
+// Redactor masks personal data and secrets.
+type Redactor struct {
+	fields  map[string]struct{}
+	fieldRe *regexp.Regexp
+	secrets []string
+}
+
+// New returns Redactor masking email addresses, values of the parameter fields and secrets.
+// Field names are compared case-insensitively.
+func New(fields, secrets []string) *Redactor {
+	r := &Redactor{fields: make(map[string]struct{}, len(fields))}
+	quoted := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if field == "" {
+			continue
+		}
+		r.fields[strings.ToLower(field)] = struct{}{}
+		quoted = append(quoted, regexp.QuoteMeta(field))
+	}
+	if len(quoted) > 0 {
+		// matches `"field":"value"`, `field=value` and `field:value` of %v formatted maps.
+		r.fieldRe = regexp.MustCompile(`(?i)("?\b(?:` + strings.Join(quoted, "|") + `)"?\s*[:=]\s*)("(?:[^"\\]|\\.)*"|[^\s,}\]]+)`)
+	}
+	for _, secret := range secrets {
+		if secret != "" {
+			r.secrets = append(r.secrets, secret)
+		}
+	}
+	// longer secrets first, so secret containing another one is masked whole.
+	sort.Slice(r.secrets, func(i, j int) bool { return len(r.secrets[i]) > len(r.secrets[j]) })
+
+	return r
+}
+
+// String returns s with secrets, parameter fields and email addresses masked.
+// Email is masked to the first letter and domain, so logs still can be correlated.
+func (r *Redactor) String(s string) string {
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, Mask)
+	}
+	s = sendGridKeyRe.ReplaceAllString(s, Mask)
+	s = bearerRe.ReplaceAllString(s, "${1}"+Mask)
+	if r.fieldRe != nil {
+		s = r.fieldRe.ReplaceAllString(s, "${1}"+Mask)
+	}
+
+	return emailRe.ReplaceAllString(s, "${1}***@${2}")
+}
+
+// Params returns copy of decoded JSON parameters with configured fields masked
+// and email addresses in strings masked.
+func (r *Redactor) Params(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			if _, ok := r.fields[strings.ToLower(key)]; ok {
+				res[key] = Mask
+				continue
+			}
+			res[key] = r.Params(item)
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(value))
+		for i, item := range value {
+			res[i] = r.Params(item)
+		}
+		return res
+	case string:
+		return emailRe.ReplaceAllString(value, "${1}***@${2}")
+	default:
+		return v
+	}
+}
@@ -1,6 +1,7 @@
 package resources
 
 type TemplateResource struct {
-	Name          string
-	EmailTemplate string
+	Name             string
+	EmailTemplate    string
+	ParametersSchema string
 }
@@ -0,0 +1,149 @@
+package scheduler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/pralolik/templgrid/pkg"
+)
+
+var (
+	emailsBucket = []byte("scheduled")
+	dueBucket    = []byte("due")
+)
+
+// BoltStore keeps scheduled emails in bbolt file, so they survive restarts.
+// Emails are indexed by send time in the due bucket.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+func NewBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("can't open scheduler db: %w ", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(emailsBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(dueBucket)
+		return err
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("can't create scheduler buckets: %w ", err)
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+func (s *BoltStore) Add(email *pkg.TemplgridEmailEntity) error {
+	v, err := json.Marshal(email)
+	if err != nil {
+		return fmt.Errorf("can't marshal scheduled email: %w ", err)
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(emailsBucket).Put([]byte(email.ID), v); err != nil {
+			return err
+		}
+		return tx.Bucket(dueBucket).Put(dueKey(*email.SendAt, email.ID), []byte(email.ID))
+	})
+}
+
+func (s *BoltStore) Get(id string) (*pkg.TemplgridEmailEntity, error) {
+	var email *pkg.TemplgridEmailEntity
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		email, err = getEmail(tx, id)
+		return err
+	})
+
+	return email, err
+}
+
+func (s *BoltStore) Remove(id string) (*pkg.TemplgridEmailEntity, error) {
+	var email *pkg.TemplgridEmailEntity
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		if email, err = getEmail(tx, id); err != nil {
+			return err
+		}
+		if err = tx.Bucket(emailsBucket).Delete([]byte(id)); err != nil {
+			return err
+		}
+		return tx.Bucket(dueBucket).Delete(dueKey(*email.SendAt, id))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return email, nil
+}
+
+func (s *BoltStore) Due(now time.Time, limit int) ([]string, error) {
+	var ids []string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(dueBucket).Cursor()
+		end := dueKey(now, "")
+		for k, v := c.First(); k != nil && bytes.Compare(k[:8], end) <= 0 && len(ids) < limit; k, v = c.Next() {
+			ids = append(ids, string(v))
+		}
+		return nil
+	})
+
+	return ids, err
+}
+
+func (s *BoltStore) Len() int {
+	var n int
+	_ = s.db.View(func(tx *bolt.Tx) error {
+		n = tx.Bucket(emailsBucket).Stats().KeyN
+		return nil
+	})
+
+	return n
+}
+
+func (s *BoltStore) Walk(fn func(email *pkg.TemplgridEmailEntity) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(emailsBucket).ForEach(func(_, v []byte) error {
+			var email pkg.TemplgridEmailEntity
+			if err := json.Unmarshal(v, &email); err != nil {
+				return fmt.Errorf("can't unmarshal scheduled email: %w ", err)
+			}
+			return fn(&email)
+		})
+	})
+}
+
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+func getEmail(tx *bolt.Tx, id string) (*pkg.TemplgridEmailEntity, error) {
+	v := tx.Bucket(emailsBucket).Get([]byte(id))
+	if v == nil {
+		return nil, ErrNotFound
+	}
+	var email pkg.TemplgridEmailEntity
+	if err := json.Unmarshal(v, &email); err != nil {
+		return nil, fmt.Errorf("can't unmarshal scheduled email: %w ", err)
+	}
+
+	return &email, nil
+}
+
+// dueKey orders emails by send time, id makes keys unique.
+func dueKey(at time.Time, id string) []byte {
+	key := make([]byte, 8, 8+len(id))
+	binary.BigEndian.PutUint64(key, uint64(at.UnixNano()))
+
+	return append(key, id...)
+}
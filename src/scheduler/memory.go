@@ -0,0 +1,112 @@
+package scheduler
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pralolik/templgrid/pkg"
+)
+
+// MemoryStore keeps scheduled emails in memory, they are lost on restart
+// unless taken by Drain and persisted.
+type MemoryStore struct {
+	mu     sync.Mutex
+	emails map[string]*pkg.TemplgridEmailEntity
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{emails: map[string]*pkg.TemplgridEmailEntity{}}
+}
+
+func (s *MemoryStore) Add(email *pkg.TemplgridEmailEntity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.emails[email.ID] = email
+
+	return nil
+}
+
+func (s *MemoryStore) Get(id string) (*pkg.TemplgridEmailEntity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	email, ok := s.emails[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return email, nil
+}
+
+func (s *MemoryStore) Remove(id string) (*pkg.TemplgridEmailEntity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	email, ok := s.emails[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	delete(s.emails, id)
+
+	return email, nil
+}
+
+func (s *MemoryStore) Due(now time.Time, limit int) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	due := make([]*pkg.TemplgridEmailEntity, 0)
+	for _, email := range s.emails {
+		if !email.SendAt.After(now) {
+			due = append(due, email)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].SendAt.Before(*due[j].SendAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	ids := make([]string, 0, len(due))
+	for _, email := range due {
+		ids = append(ids, email.ID)
+	}
+
+	return ids, nil
+}
+
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.emails)
+}
+
+func (s *MemoryStore) Walk(fn func(email *pkg.TemplgridEmailEntity) error) error {
+	s.mu.Lock()
+	emails := make([]*pkg.TemplgridEmailEntity, 0, len(s.emails))
+	for _, email := range s.emails {
+		emails = append(emails, email)
+	}
+	s.mu.Unlock()
+	for _, email := range emails {
+		if err := fn(email); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Drain removes and returns all scheduled emails.
+func (s *MemoryStore) Drain() []*pkg.TemplgridEmailEntity {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	emails := make([]*pkg.TemplgridEmailEntity, 0, len(s.emails))
+	for id, email := range s.emails {
+		emails = append(emails, email)
+		delete(s.emails, id)
+	}
+
+	return emails
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
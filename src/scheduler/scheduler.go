@@ -0,0 +1,194 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pralolik/templgrid/pkg"
+	"github.com/pralolik/templgrid/src/logging"
+	"github.com/pralolik/templgrid/src/queue"
+	"github.com/pralolik/templgrid/src/status"
+)
+
+const (
+	DefInterval = time.Second
+	DefMaxDelay = 30 * 24 * time.Hour
+
+	releaseBatch = 100
+)
+
+var (
+	// ErrNotFound is returned when there is no scheduled email with the id,
+	// it may be already sent or cancelled.
+	ErrNotFound = errors.New("scheduled email not found")
+	// ErrTooLate is returned when the send time exceeds max delay.
+	ErrTooLate = errors.New("send time exceeds max schedule delay")
+)
+
+// Store holds scheduled emails until they are due.
+type Store interface {
+	// Add stores the email until its SendAt.
+	Add(email *pkg.TemplgridEmailEntity) error
+	// Get returns scheduled email or ErrNotFound.
+	Get(id string) (*pkg.TemplgridEmailEntity, error)
+	// Remove deletes scheduled email and returns it or ErrNotFound.
+	Remove(id string) (*pkg.TemplgridEmailEntity, error)
+	// Due returns ids of up to limit emails due at now, the earliest first.
+	Due(now time.Time, limit int) ([]string, error)
+	// Len returns count of scheduled emails.
+	Len() int
+	// Walk calls fn for every scheduled email until fn returns error.
+	Walk(fn func(email *pkg.TemplgridEmailEntity) error) error
+	Close() error
+}
+
+// Scheduler holds emails in the store and pushes them to the queue when they are due.
+type Scheduler struct {
+	log      logging.Logger
+	store    Store
+	statuses status.Store
+	interval time.Duration
+	maxDelay time.Duration
+}
+
+func New(log logging.Logger, store Store, options ...Option) *Scheduler {
+	s := &Scheduler{
+		log:      log,
+		store:    store,
+		statuses: status.NewMemoryStore(status.DefTTL),
+		interval: DefInterval,
+		maxDelay: DefMaxDelay,
+	}
+	for _, option := range options {
+		option(s)
+	}
+
+	return s
+}
+
+type Option func(s *Scheduler)
+
+// WithInterval sets how often due emails are checked.
+func WithInterval(interval time.Duration) Option {
+	return func(s *Scheduler) {
+		if interval > 0 {
+			s.interval = interval
+		}
+	}
+}
+
+// WithMaxDelay sets how far in the future emails may be scheduled.
+func WithMaxDelay(maxDelay time.Duration) Option {
+	return func(s *Scheduler) {
+		if maxDelay > 0 {
+			s.maxDelay = maxDelay
+		}
+	}
+}
+
+func WithStatusStore(statuses status.Store) Option {
+	return func(s *Scheduler) {
+		s.statuses = statuses
+	}
+}
+
+// Check returns ErrTooLate if due exceeds max delay.
+func (s *Scheduler) Check(due time.Time) error {
+	if time.Until(due) > s.maxDelay {
+		return fmt.Errorf("%w %s", ErrTooLate, s.maxDelay)
+	}
+
+	return nil
+}
+
+// Schedule holds the email until its SendAt.
+func (s *Scheduler) Schedule(email *pkg.TemplgridEmailEntity) error {
+	if email.SendAt == nil {
+		return errors.New("email has no send time")
+	}
+	if err := s.store.Add(email); err != nil {
+		return fmt.Errorf("can't store scheduled email: %w ", err)
+	}
+
+	return nil
+}
+
+// Get returns scheduled email or ErrNotFound.
+func (s *Scheduler) Get(id string) (*pkg.TemplgridEmailEntity, error) {
+	return s.store.Get(id)
+}
+
+// Cancel removes scheduled email, returns ErrNotFound if it is already released.
+func (s *Scheduler) Cancel(id string) error {
+	if _, err := s.store.Remove(id); err != nil {
+		return err
+	}
+	if err := s.statuses.SetState(id, status.Cancelled, nil); err != nil && !errors.Is(err, status.ErrNotFound) {
+		s.log.Error("can't set status of cancelled email %s: %v ", id, err)
+	}
+
+	return nil
+}
+
+// Len returns count of scheduled emails.
+func (s *Scheduler) Len() int {
+	return s.store.Len()
+}
+
+// Walk calls fn for every scheduled email, it is used to restore state of emails kept by the store.
+func (s *Scheduler) Walk(fn func(email *pkg.TemplgridEmailEntity) error) error {
+	return s.store.Walk(fn)
+}
+
+// Run pushes due emails to q until ctx is done.
+func (s *Scheduler) Run(ctx context.Context, q queue.Interface) error {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			s.release(q, time.Now())
+		}
+	}
+}
+
+// release pushes emails due at now to q. Emails rejected by the queue stay scheduled
+// and are retried on the next tick.
+func (s *Scheduler) release(q queue.Interface, now time.Time) {
+	for {
+		ids, err := s.store.Due(now, releaseBatch)
+		if err != nil {
+			s.log.Error("can't get due emails: %v ", err)
+			return
+		}
+		for _, id := range ids {
+			// removing first makes cancellation and release exclusive.
+			email, err := s.store.Remove(id)
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				s.log.Error("can't remove due email %s: %v ", id, err)
+				return
+			}
+			if err = q.Push(email); err != nil {
+				logging.With(s.log, logging.MessageIDKey, id).Error("can't push due email %s: %v ", id, err)
+				if err = s.store.Add(email); err != nil {
+					s.log.Error("can't reschedule email %s, it is lost: %v ", id, err)
+					_ = s.statuses.SetState(id, status.Failed, err)
+				}
+				return
+			}
+			if err = s.statuses.SetState(id, status.Queued, nil); err != nil && !errors.Is(err, status.ErrNotFound) {
+				s.log.Error("can't set status of due email %s: %v ", id, err)
+			}
+		}
+		if len(ids) < releaseBatch {
+			return
+		}
+	}
+}
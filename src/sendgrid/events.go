@@ -0,0 +1,281 @@
+package sendgrid
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sendgrid/sendgrid-go/helpers/eventwebhook"
+
+	"github.com/pralolik/templgrid/src/logging"
+	"github.com/pralolik/templgrid/src/status"
+	"github.com/pralolik/templgrid/src/suppression"
+)
+
+// CustomArgMessageID is the custom arg added to sent emails,
+// SendGrid returns it in events to map them to templgrid message id.
+const CustomArgMessageID = "templgrid_message_id"
+
+// Event types of SendGrid Event Webhook handled by templgrid.
+const (
+	EventDelivered  = "delivered"
+	EventBounce     = "bounce"
+	EventDropped    = "dropped"
+	EventOpen       = "open"
+	EventClick      = "click"
+	EventSpamReport = "spamreport"
+
+	// bounceTypeBlocked is temporary rejection, address is not suppressed.
+	bounceTypeBlocked = "blocked"
+
+	// seenTTL is how long event ids are remembered, SendGrid retries failed posts for 24 hours.
+	seenTTL         = 24 * time.Hour
+	cleanupInterval = time.Minute
+)
+
+// stateOrder ranks states changed by events, states move only forward, so late and repeated events
+// don't override later states. States set before sending have zero rank.
+var stateOrder = map[status.State]int{
+	status.Delivered:  1,
+	status.Bounced:    2,
+	status.Failed:     2,
+	status.Complained: 3,
+}
+
+var (
+	// ErrInvalidSignature is returned when events are not signed by SendGrid key or signature is too old.
+	ErrInvalidSignature = errors.New("invalid event webhook signature")
+	// ErrInvalidPublicKey is returned for verification key which isn't base64 ECDSA public key.
+	ErrInvalidPublicKey = errors.New("invalid event webhook public key")
+)
+
+// Event is a SendGrid Event Webhook event, custom args are top level fields.
+type Event struct {
+	Email     string `json:"email"`
+	Timestamp int64  `json:"timestamp"`
+	Event     string `json:"event"`
+	// Type is bounce or blocked for bounce events.
+	Type        string `json:"type,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+	URL         string `json:"url,omitempty"`
+	SGEventID   string `json:"sg_event_id,omitempty"`
+	SGMessageID string `json:"sg_message_id,omitempty"`
+	MessageID   string `json:"templgrid_message_id,omitempty"`
+}
+
+// ParseEvents parses body of Event Webhook request.
+func ParseEvents(body []byte) ([]Event, error) {
+	var events []Event
+	if err := json.Unmarshal(body, &events); err != nil {
+		return nil, fmt.Errorf("can't parse events: %w ", err)
+	}
+
+	return events, nil
+}
+
+// EventVerifier verifies ECDSA signature of Event Webhook requests.
+type EventVerifier struct {
+	publicKey *ecdsa.PublicKey
+	maxAge    time.Duration
+}
+
+// NewEventVerifier creates verifier of the base64 public key from SendGrid settings,
+// signatures older than maxAge are rejected, 0 disables the check.
+func NewEventVerifier(publicKey string, maxAge time.Duration) (*EventVerifier, error) {
+	der, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPublicKey, err)
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPublicKey, err)
+	}
+	ecdsaKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: not ECDSA key", ErrInvalidPublicKey)
+	}
+
+	return &EventVerifier{publicKey: ecdsaKey, maxAge: maxAge}, nil
+}
+
+// Verify checks signature and timestamp headers of the raw request body.
+func (v *EventVerifier) Verify(body []byte, signature, timestamp string, now time.Time) error {
+	if signature == "" || timestamp == "" {
+		return fmt.Errorf("%w: missing %s or %s header",
+			ErrInvalidSignature, eventwebhook.VerificationHTTPHeader, eventwebhook.TimestampHTTPHeader)
+	}
+	if v.maxAge > 0 {
+		sec, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%w: incorrect timestamp", ErrInvalidSignature)
+		}
+		if age := now.Sub(time.Unix(sec, 0)); age > v.maxAge || age < -v.maxAge {
+			return fmt.Errorf("%w: timestamp is out of %s window", ErrInvalidSignature, v.maxAge)
+		}
+	}
+	ok, err := eventwebhook.VerifySignature(v.publicKey, body, signature, timestamp)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+	}
+	if !ok {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// EventProcessor updates message statuses by events and suppresses
+// hard bounced and complained addresses. Events are deduplicated by sg_event_id.
+type EventProcessor struct {
+	log          logging.Logger
+	statuses     status.Store
+	suppressions suppression.Store
+
+	// mu serializes requests, so state is read and changed atomically.
+	mu          sync.Mutex
+	seen        map[string]time.Time
+	lastCleanup time.Time
+}
+
+// NewEventProcessor creates processor, suppressions may be nil.
+func NewEventProcessor(log logging.Logger, statuses status.Store, suppressions suppression.Store) *EventProcessor {
+	return &EventProcessor{log: log, statuses: statuses, suppressions: suppressions, seen: map[string]time.Time{}}
+}
+
+// Process handles events in order, events of unknown messages only feed suppressions.
+func (p *EventProcessor) Process(events []Event) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	p.cleanup(now)
+	for _, event := range events {
+		if event.SGEventID != "" {
+			if _, ok := p.seen[event.SGEventID]; ok {
+				p.log.Debug("sendgrid event %s %s is already processed", event.Event, event.SGEventID)
+				continue
+			}
+			p.seen[event.SGEventID] = now
+		}
+		p.suppress(event)
+		if event.MessageID == "" {
+			p.log.Debug("sendgrid event %s %s without message id skipped", event.Event, event.SGEventID)
+			continue
+		}
+		log := logging.With(p.log, logging.MessageIDKey, event.MessageID)
+		err := p.statuses.AddEvent(event.MessageID, status.Event{
+			Type:      event.Event,
+			Address:   event.Email,
+			Reason:    event.Reason,
+			URL:       event.URL,
+			Timestamp: time.Unix(event.Timestamp, 0).UTC(),
+		})
+		if errors.Is(err, status.ErrNotFound) {
+			log.Debug("sendgrid event %s of unknown message %s skipped", event.Event, event.MessageID)
+			continue
+		}
+		if err != nil {
+			log.Error("can't record sendgrid event %s: %v ", event.Event, err)
+			continue
+		}
+		if state, reason, ok := eventState(event); ok {
+			p.advance(log, event, state, reason)
+		}
+	}
+}
+
+// advance moves state of the event recipient and the message forward, the message has the most advanced state
+// of its recipients.
+func (p *EventProcessor) advance(log logging.Logger, event Event, state status.State, reason string) {
+	st, err := p.statuses.Get(event.MessageID)
+	if err != nil {
+		log.Error("can't get status to apply sendgrid event %s: %v ", event.Event, err)
+		return
+	}
+	address := suppression.NormalizeAddress(event.Email)
+	for _, recipient := range st.Recipients {
+		if recipient.Address == address && stateOrder[recipient.State] >= stateOrder[state] {
+			log.Debug("sendgrid event %s of %s skipped, recipient is already %s", event.Event, address, recipient.State)
+			return
+		}
+	}
+	err = p.statuses.SetRecipientState(event.MessageID, status.RecipientState{
+		Address:   address,
+		State:     state,
+		Reason:    reason,
+		UpdatedAt: time.Unix(event.Timestamp, 0).UTC(),
+	})
+	if err != nil {
+		log.Error("can't set recipient status %s by sendgrid event: %v ", state, err)
+		return
+	}
+	if stateOrder[st.State] >= stateOrder[state] {
+		return
+	}
+	var stateErr error
+	if reason != "" {
+		stateErr = errors.New(reason)
+	}
+	if err = p.statuses.SetState(event.MessageID, state, stateErr); err != nil {
+		log.Error("can't set status %s by sendgrid event: %v ", state, err)
+	}
+}
+
+func (p *EventProcessor) cleanup(now time.Time) {
+	if now.Sub(p.lastCleanup) < cleanupInterval {
+		return
+	}
+	p.lastCleanup = now
+	for id, at := range p.seen {
+		if now.Sub(at) > seenTTL {
+			delete(p.seen, id)
+		}
+	}
+}
+
+func (p *EventProcessor) suppress(event Event) {
+	if p.suppressions == nil {
+		return
+	}
+	var reason suppression.Reason
+	switch {
+	case event.Event == EventBounce && event.Type != bounceTypeBlocked:
+		reason = suppression.ReasonBounce
+	case event.Event == EventSpamReport:
+		reason = suppression.ReasonComplaint
+	default:
+		return
+	}
+	err := p.suppressions.Add(suppression.Entry{
+		Address:   event.Email,
+		Reason:    reason,
+		CreatedAt: time.Unix(event.Timestamp, 0).UTC(),
+	})
+	if err != nil {
+		p.log.Error("can't suppress %s by sendgrid %s event: %v ", event.Email, event.Event, err)
+		return
+	}
+	p.log.Info("%s suppressed by sendgrid %s event", event.Email, event.Event)
+}
+
+// eventState returns message state changed by the event and failure reason,
+// opens and clicks don't change state.
+func eventState(event Event) (status.State, string, bool) {
+	switch event.Event {
+	case EventDelivered:
+		return status.Delivered, "", true
+	case EventBounce:
+		return status.Bounced, event.Reason, true
+	case EventDropped:
+		return status.Failed, "dropped by sendgrid: " + event.Reason, true
+	case EventSpamReport:
+		return status.Complained, "", true
+	}
+
+	return "", "", false
+}
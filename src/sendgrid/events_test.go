@@ -0,0 +1,198 @@
+package sendgrid
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pralolik/templgrid/src/logging"
+	"github.com/pralolik/templgrid/src/status"
+	"github.com/pralolik/templgrid/src/suppression"
+)
+
+const (
+	// messages of testdata/events.json, the first is sent to john and jane, the second to max.
+	multiRecipientMessage = "6e5c02a1033566da7b24e89c87d37dd8"
+	bouncedMessage        = "3719dd934793b8109d1bd036e0e529a2"
+)
+
+// signedFixture is Event Webhook request body with signature headers recorded with the public key.
+type signedFixture struct {
+	body      []byte
+	PublicKey string `json:"public_key"`
+	Timestamp string `json:"timestamp"`
+	Signature string `json:"signature"`
+}
+
+func loadFixture(t *testing.T, bodyPath, signaturePath string) signedFixture {
+	t.Helper()
+	var f signedFixture
+	raw, err := os.ReadFile(signaturePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = json.Unmarshal(raw, &f); err != nil {
+		t.Fatal(err)
+	}
+	if f.body, err = os.ReadFile(bodyPath); err != nil {
+		t.Fatal(err)
+	}
+
+	return f
+}
+
+func TestEventVerifier(t *testing.T) {
+	fixtures := map[string]signedFixture{
+		"recorded": loadFixture(t, "testdata/events.json", "testdata/events.signature.json"),
+		"example": loadFixture(t,
+			"../../examples/webhooks/sendgrid-events.json", "../../examples/webhooks/sendgrid-events.signature.json"),
+	}
+	for name, f := range fixtures {
+		t.Run(name, func(t *testing.T) {
+			verifier, err := NewEventVerifier(f.PublicKey, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = verifier.Verify(f.body, f.Signature, f.Timestamp, time.Now()); err != nil {
+				t.Errorf("recorded request is rejected: %v", err)
+			}
+
+			tampered := append([]byte{}, f.body...)
+			tampered[len(tampered)-2] = ' '
+			if err = verifier.Verify(tampered, f.Signature, f.Timestamp, time.Now()); !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("tampered body error = %v, want ErrInvalidSignature", err)
+			}
+			if err = verifier.Verify(f.body, f.Signature, "1760882601", time.Now()); !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("another timestamp error = %v, want ErrInvalidSignature", err)
+			}
+		})
+	}
+}
+
+func TestEventVerifierMaxAge(t *testing.T) {
+	f := loadFixture(t, "testdata/events.json", "testdata/events.signature.json")
+	verifier, err := NewEventVerifier(f.PublicKey, 5*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recordedAt := time.Unix(1760882600, 0)
+	if err = verifier.Verify(f.body, f.Signature, f.Timestamp, recordedAt.Add(time.Minute)); err != nil {
+		t.Errorf("fresh request is rejected: %v", err)
+	}
+	if err = verifier.Verify(f.body, f.Signature, f.Timestamp, recordedAt.Add(time.Hour)); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("replayed request error = %v, want ErrInvalidSignature", err)
+	}
+}
+
+func TestEventProcessor(t *testing.T) {
+	f := loadFixture(t, "testdata/events.json", "testdata/events.signature.json")
+	events, err := ParseEvents(f.body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := status.NewMemoryStore(status.DefTTL)
+	for _, id := range []string{multiRecipientMessage, bouncedMessage} {
+		if err = statuses.Create(id, "welcome", "shop"); err != nil {
+			t.Fatal(err)
+		}
+		if err = statuses.SetState(id, status.Sent, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	suppressions := suppression.NewMemoryStore()
+	p := NewEventProcessor(&logging.DisabledLog{}, statuses, suppressions)
+
+	p.Process(events)
+	// SendGrid retries the whole batch if the response is lost.
+	p.Process(events)
+
+	multi, err := statuses.Get(multiRecipientMessage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the repeated open is deduplicated by sg_event_id.
+	if len(multi.Events) != 5 {
+		t.Errorf("got %d events of the message, want 5", len(multi.Events))
+	}
+	// late delivered event of john doesn't override the complaint.
+	if multi.State != status.Complained {
+		t.Errorf("message state = %s, want %s", multi.State, status.Complained)
+	}
+	wantRecipients := map[string]status.State{
+		"john@example.com": status.Complained,
+		"jane@example.org": status.Delivered,
+	}
+	if len(multi.Recipients) != len(wantRecipients) {
+		t.Fatalf("got recipients %+v, want %v", multi.Recipients, wantRecipients)
+	}
+	for _, recipient := range multi.Recipients {
+		if wantRecipients[recipient.Address] != recipient.State {
+			t.Errorf("recipient %s state = %s, want %s", recipient.Address, recipient.State, wantRecipients[recipient.Address])
+		}
+	}
+
+	bounced, err := statuses.Get(bouncedMessage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bounced.State != status.Bounced || bounced.Error == "" {
+		t.Errorf("bounced message state = %s %q, want %s with reason", bounced.State, bounced.Error, status.Bounced)
+	}
+	if len(bounced.Recipients) != 1 || bounced.Recipients[0].State != status.Bounced {
+		t.Errorf("bounced message recipients = %+v", bounced.Recipients)
+	}
+
+	for address, reason := range map[string]suppression.Reason{
+		"john@example.com": suppression.ReasonComplaint,
+		"max@example.net":  suppression.ReasonBounce,
+	} {
+		entry, err := suppressions.Get(address, "")
+		if err != nil {
+			t.Errorf("%s is not suppressed: %v", address, err)
+			continue
+		}
+		if entry.Reason != reason {
+			t.Errorf("%s suppression reason = %s, want %s", address, entry.Reason, reason)
+		}
+	}
+	if _, err = suppressions.Get("jane@example.org", ""); err == nil {
+		t.Error("delivered recipient is suppressed")
+	}
+}
+
+func TestEventStateOrder(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []string
+		want   status.State
+	}{
+		{name: "delivered", events: []string{EventDelivered}, want: status.Delivered},
+		{name: "bounce after delivered", events: []string{EventDelivered, EventBounce}, want: status.Bounced},
+		{name: "delivered after bounce", events: []string{EventBounce, EventDelivered}, want: status.Bounced},
+		{name: "complaint after delivered", events: []string{EventDelivered, EventSpamReport}, want: status.Complained},
+		{name: "opens and clicks", events: []string{EventDelivered, EventOpen, EventClick}, want: status.Delivered},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statuses := status.NewMemoryStore(status.DefTTL)
+			if err := statuses.Create("m1", "welcome", "shop"); err != nil {
+				t.Fatal(err)
+			}
+			p := NewEventProcessor(&logging.DisabledLog{}, statuses, nil)
+			var events []Event
+			for i, event := range tt.events {
+				events = append(events, Event{Email: "john@example.com", Event: event, Timestamp: int64(i), MessageID: "m1"})
+			}
+			p.Process(events)
+			st, err := statuses.Get("m1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if st.State != tt.want {
+				t.Errorf("state = %s, want %s", st.State, tt.want)
+			}
+		})
+	}
+}
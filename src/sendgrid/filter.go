@@ -0,0 +1,131 @@
+package sendgrid
+
+import (
+	"fmt"
+
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+
+	"github.com/pralolik/templgrid/pkg"
+	"github.com/pralolik/templgrid/src/status"
+)
+
+// ErrAllRecipientsDropped is returned when filters removed every recipient.
+var ErrAllRecipientsDropped = fmt.Errorf("%w: all recipients dropped", ErrPermanent)
+
+// RefundFilter is RecipientFilter which counts allowed recipients, like rate limits.
+// Recipients of emails which are not sent are refunded.
+type RefundFilter interface {
+	RecipientFilter
+	// Refund uncounts the address allowed for the email.
+	Refund(email *pkg.TemplgridEmailEntity, address string)
+}
+
+// reasonTooManyPersonalizations is reason of dropping bcc recipients whose personalizations exceed the limit.
+const reasonTooManyPersonalizations = "too many personalizations"
+
+// RecipientFilter decides whether the recipient may receive the email.
+type RecipientFilter interface {
+	// Allow returns empty string if the address may receive the email
+	// or the reason of dropping it.
+	Allow(email *pkg.TemplgridEmailEntity, address string) string
+}
+
+// applyFilters removes recipients rejected by filters from personalizations
+// and records them in the message status.
+// SendGrid requires `to` recipient, so if every `to` recipient is dropped, the first cc recipient
+// takes its place and bcc recipients without cc get a personalization each, so they don't see each other.
+// Personalization without recipients left is removed.
+func (sg *SendGrid) applyFilters(email *pkg.TemplgridEmailEntity) error {
+	if len(sg.filters) == 0 {
+		return nil
+	}
+
+	personalizations := make([]*mail.Personalization, 0, len(email.SendGridParameters.Personalizations))
+	for _, ps := range email.SendGridParameters.Personalizations {
+		ps.To = sg.filterAddresses(email, ps.To)
+		ps.CC = sg.filterAddresses(email, ps.CC)
+		ps.BCC = sg.filterAddresses(email, ps.BCC)
+		switch {
+		case len(ps.To) > 0:
+			personalizations = append(personalizations, ps)
+		case len(ps.CC) > 0:
+			ps.To, ps.CC = ps.CC[:1], ps.CC[1:]
+			personalizations = append(personalizations, ps)
+		default:
+			for _, bcc := range ps.BCC {
+				personalizations = append(personalizations, withRecipient(ps, bcc))
+			}
+		}
+	}
+	if len(personalizations) > pkg.MaxPersonalizationPerRequest {
+		for _, ps := range personalizations[pkg.MaxPersonalizationPerRequest:] {
+			sg.drop(email, ps.To[0].Address, reasonTooManyPersonalizations)
+			sg.refundAddress(email, ps.To[0].Address, len(sg.filters))
+		}
+		personalizations = personalizations[:pkg.MaxPersonalizationPerRequest]
+	}
+	email.SendGridParameters.Personalizations = personalizations
+	if len(personalizations) == 0 {
+		return ErrAllRecipientsDropped
+	}
+
+	return nil
+}
+
+func (sg *SendGrid) filterAddresses(email *pkg.TemplgridEmailEntity, addresses []*mail.Email) []*mail.Email {
+	if len(addresses) == 0 {
+		return addresses
+	}
+	allowed := make([]*mail.Email, 0, len(addresses))
+	for _, address := range addresses {
+		if reason := sg.allow(email, address.Address); reason != "" {
+			sg.drop(email, address.Address, reason)
+			continue
+		}
+		allowed = append(allowed, address)
+	}
+
+	return allowed
+}
+
+// drop records the recipient removed from the email in the message status.
+func (sg *SendGrid) drop(email *pkg.TemplgridEmailEntity, address, reason string) {
+	sg.emailLog(email).Info("recipient dropped from email %s %s: %s", email.TemplateName, email.ID, reason)
+	dropped := status.DroppedRecipient{Address: address, Reason: reason}
+	if err := sg.statuses.AddDropped(email.ID, dropped); err != nil {
+		sg.emailLog(email).Debug("can't record dropped recipient for email %s: %v ", email.ID, err)
+	}
+}
+
+// allow returns reason of the first filter rejecting the address,
+// the address is refunded to filters which allowed it before.
+func (sg *SendGrid) allow(email *pkg.TemplgridEmailEntity, address string) string {
+	for i, filter := range sg.filters {
+		if reason := filter.Allow(email, address); reason != "" {
+			sg.refundAddress(email, address, i)
+			return reason
+		}
+	}
+
+	return ""
+}
+
+// refund uncounts recipients of the email which is not sent.
+func (sg *SendGrid) refund(email *pkg.TemplgridEmailEntity) {
+	for _, ps := range email.SendGridParameters.Personalizations {
+		for _, addresses := range [][]*mail.Email{ps.To, ps.CC, ps.BCC} {
+			for _, address := range addresses {
+				sg.refundAddress(email, address.Address, len(sg.filters))
+			}
+		}
+	}
+}
+
+// refundAddress uncounts the address in the first n filters.
+func (sg *SendGrid) refundAddress(email *pkg.TemplgridEmailEntity, address string, n int) {
+	for _, filter := range sg.filters[:n] {
+		if rf, ok := filter.(RefundFilter); ok {
+			rf.Refund(email, address)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package sendgrid
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pralolik/templgrid/pkg"
+	"github.com/pralolik/templgrid/src/history"
+	"github.com/pralolik/templgrid/src/logging"
+	"github.com/pralolik/templgrid/src/preference"
+	"github.com/pralolik/templgrid/src/ratelimit"
+	"github.com/pralolik/templgrid/src/status"
+	"github.com/pralolik/templgrid/src/templatemanager"
+)
+
+// blockFilter drops listed addresses.
+type blockFilter map[string]bool
+
+func (f blockFilter) Allow(_ *pkg.TemplgridEmailEntity, address string) string {
+	if f[address] {
+		return "blocked"
+	}
+	return ""
+}
+
+func TestApplyFiltersKeepsCopies(t *testing.T) {
+	statuses := status.NewMemoryStore(status.DefTTL)
+	if err := statuses.Create("m1", "welcome", "shop"); err != nil {
+		t.Fatal(err)
+	}
+	sg := NewSendGrid("", true, &logging.DisabledLog{}, templatemanager.NewEmailStorage(),
+		WithStatusStore(statuses),
+		WithRecipientFilter(blockFilter{"john@example.com": true, "max@example.net": true}))
+
+	email := pkg.TemplgridEmailEntity{ID: "m1", TemplateName: "welcome"}
+	err := json.Unmarshal([]byte(`{"personalizations": [
+		{"to": [{"email": "john@example.com"}], "cc": [{"email": "jane@example.org"}, {"email": "ann@example.org"}]},
+		{"to": [{"email": "max@example.net"}], "bcc": [{"email": "boss@example.net"}, {"email": "audit@example.net"}]}
+	]}`), &email.SendGridParameters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = sg.applyFilters(&email); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, ps := range email.SendGridParameters.Personalizations {
+		if len(ps.To) != 1 {
+			t.Fatalf("personalization is sent to %v, want one `to` recipient", ps.To)
+		}
+		got = append(got, ps.To[0].Address)
+		if len(ps.BCC) != 0 {
+			t.Errorf("%s personalization keeps bcc %v", ps.To[0].Address, ps.BCC)
+		}
+	}
+	want := []string{"jane@example.org", "boss@example.net", "audit@example.net"}
+	if len(got) != len(want) {
+		t.Fatalf("got `to` recipients %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got `to` recipients %v, want %v", got, want)
+			break
+		}
+	}
+	if cc := email.SendGridParameters.Personalizations[0].CC; len(cc) != 1 || cc[0].Address != "ann@example.org" {
+		t.Errorf("cc = %v, want ann@example.org", cc)
+	}
+	st, err := statuses.Get("m1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(st.Dropped) != 2 {
+		t.Errorf("dropped = %+v, want john and max", st.Dropped)
+	}
+}
+
+func TestRecipientLimitRefund(t *testing.T) {
+	limiter := ratelimit.NewRecipientLimiter(1, time.Hour)
+	// the template isn't loaded, so every email fails to build after filters.
+	sg := NewSendGrid("", true, &logging.DisabledLog{}, templatemanager.NewEmailStorage(),
+		WithRecipientFilter(limiter))
+	email := pkg.TemplgridEmailEntity{ID: "m1", TemplateName: "welcome"}
+	email.SendGridParameters.Personalizations = personalizations("john@example.com")
+
+	if err := sg.sendEmail(context.Background(), &email, &history.Record{}); err == nil {
+		t.Fatal("email of unknown template is sent")
+	}
+	if reason := limiter.Allow(&email, "john@example.com"); reason != "" {
+		t.Errorf("recipient of the failed email is counted: %s", reason)
+	}
+	if reason := limiter.Allow(&email, "john@example.com"); reason != ratelimit.ReasonRecipientLimit {
+		t.Errorf("second email reason = %q, want %q", reason, ratelimit.ReasonRecipientLimit)
+	}
+}
+
+func TestRecipientLimitRefundOnLaterFilter(t *testing.T) {
+	limiter := ratelimit.NewRecipientLimiter(1, time.Hour)
+	sg := NewSendGrid("", true, &logging.DisabledLog{}, templatemanager.NewEmailStorage(),
+		WithRecipientFilter(limiter), WithRecipientFilter(blockFilter{"john@example.com": true}))
+	email := pkg.TemplgridEmailEntity{ID: "m1", TemplateName: "welcome"}
+
+	if reason := sg.allow(&email, "john@example.com"); reason != "blocked" {
+		t.Fatalf("reason = %q, want blocked", reason)
+	}
+	if reason := limiter.Allow(&email, "john@example.com"); reason != "" {
+		t.Errorf("recipient blocked by the later filter is counted: %s", reason)
+	}
+}
+
+func TestPreferenceFilter(t *testing.T) {
+	storage := templatemanager.NewEmailStorage()
+	storage.AddOptions("news", templatemanager.TemplateOptions{Category: "newsletters"})
+	storage.AddOptions("receipt", templatemanager.TemplateOptions{Category: "receipts"})
+	center := preference.NewCenter(preference.NewMemoryStore(), []preference.Category{
+		{Name: "newsletters", Title: "Newsletters"},
+		{Name: "receipts", Title: "Receipts", Transactional: true},
+	})
+	if err := center.Update("john@example.com", []string{"newsletters"}); err != nil {
+		t.Fatal(err)
+	}
+	statuses := status.NewMemoryStore(status.DefTTL)
+	sg := NewSendGrid("", true, &logging.DisabledLog{}, storage,
+		WithStatusStore(statuses), WithRecipientFilter(preference.NewFilter(center, storage)))
+
+	for _, id := range []string{"m1", "m2", "m3"} {
+		if err := statuses.Create(id, "news", "shop"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	news := pkg.TemplgridEmailEntity{ID: "m1", TemplateName: "news"}
+	news.SendGridParameters.Personalizations = personalizations("john@example.com", "jane@example.org")
+	if err := sg.applyFilters(&news); err != nil {
+		t.Fatal(err)
+	}
+	if got := addresses(news.SendGridParameters.Personalizations); got != "jane@example.org" {
+		t.Errorf("newsletter is sent to %s, want jane@example.org", got)
+	}
+	st, err := statuses.Get("m1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(st.Dropped) != 1 || st.Dropped[0].Address != "john@example.com" || st.Dropped[0].Reason != "opted out of newsletters" {
+		t.Errorf("dropped = %+v, want john opted out", st.Dropped)
+	}
+
+	only := pkg.TemplgridEmailEntity{ID: "m2", TemplateName: "news"}
+	only.SendGridParameters.Personalizations = personalizations("john@example.com")
+	if err := sg.applyFilters(&only); !errors.Is(err, ErrAllRecipientsDropped) {
+		t.Errorf("newsletter to opted out recipient error = %v, want ErrAllRecipientsDropped", err)
+	}
+
+	// transactional categories ignore opt-outs.
+	receipt := pkg.TemplgridEmailEntity{ID: "m3", TemplateName: "receipt"}
+	receipt.SendGridParameters.Personalizations = personalizations("john@example.com")
+	if err := sg.applyFilters(&receipt); err != nil {
+		t.Fatal(err)
+	}
+	if got := addresses(receipt.SendGridParameters.Personalizations); got != "john@example.com" {
+		t.Errorf("receipt is sent to %q, want john@example.com", got)
+	}
+}
@@ -0,0 +1,58 @@
+package sendgrid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/sendgrid/rest"
+	"github.com/sendgrid/sendgrid-go"
+)
+
+var (
+	ErrNotRunning         = errors.New("sender is not running")
+	ErrInvalidCredentials = errors.New("sendgrid credentials are invalid")
+)
+
+// Health returns error if no send worker is running.
+func (sg *SendGrid) Health(_ context.Context) error {
+	if sg.workers.Load() == 0 {
+		return ErrNotRunning
+	}
+
+	return nil
+}
+
+// CredentialsChecker checks that SendGrid accepts the api key.
+// Makes a request per call, so it should be cached.
+type CredentialsChecker struct {
+	sg *SendGrid
+}
+
+// CredentialsChecker returns health checker of the api key.
+func (sg *SendGrid) CredentialsChecker() *CredentialsChecker {
+	return &CredentialsChecker{sg: sg}
+}
+
+func (c *CredentialsChecker) Health(ctx context.Context) error {
+	req := c.sg.client.Request
+	req.Method = rest.Get
+	req.Body = nil
+	// scopes endpoint is available for any valid key.
+	req.BaseURL = strings.TrimSuffix(req.BaseURL, "/mail/send") + "/scopes"
+
+	res, err := sendgrid.MakeRequestWithContext(ctx, req)
+	if err != nil {
+		return fmt.Errorf("can't check sendgrid credentials: %w", err)
+	}
+	switch {
+	case res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("%w: %d response", ErrInvalidCredentials, res.StatusCode)
+	case res.StatusCode >= http.StatusBadRequest:
+		return fmt.Errorf("can't check sendgrid credentials: %d response", res.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package sendgrid
+
+import (
+	"time"
+
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+
+	"github.com/pralolik/templgrid/pkg"
+	"github.com/pralolik/templgrid/src/helper"
+	"github.com/pralolik/templgrid/src/status"
+)
+
+// EventSplit is recorded in status of the email whose personalizations in quiet hours
+// are split to another email, the reason holds id of that email.
+const EventSplit = "split"
+
+// deferQuiet reschedules non-urgent email to the end of template quiet hours if now is within them
+// for recipients of every personalization, returns false if the email should be sent now.
+// Personalizations in quiet hours are split to a new email if others may be sent now.
+// Deferred personalizations wait for the earliest end of their quiet hours and are checked again on release.
+func (sg *SendGrid) deferQuiet(email *pkg.TemplgridEmailEntity, now time.Time) bool {
+	quietHours := sg.storage.Options(email.TemplateName).QuietHours
+	if email.Urgent || quietHours == nil {
+		return false
+	}
+	var deferred []int
+	var until time.Time
+	for i := range email.SendGridParameters.Personalizations {
+		end := quietHours.Until(now, location(email.Timezone(i)))
+		if end.IsZero() {
+			continue
+		}
+		deferred = append(deferred, i)
+		if until.IsZero() || end.Before(until) {
+			until = end
+		}
+	}
+	if len(deferred) == 0 {
+		return false
+	}
+	if sg.scheduler == nil {
+		sg.emailLog(email).Error("email %s is in quiet hours, but scheduler is disabled, sending now", email.TemplateName)
+		return false
+	}
+	if len(deferred) < len(email.SendGridParameters.Personalizations) {
+		sg.splitQuiet(email, deferred, until, now)
+		return false
+	}
+
+	if err := sg.statuses.SetState(email.ID, status.Scheduled, nil); err != nil {
+		sg.emailLog(email).Debug("can't set status %s for email %s: %v ", status.Scheduled, email.ID, err)
+	}
+	sendAt := email.SendAt
+	email.SendAt = &until
+	if err := sg.scheduler.Schedule(email); err != nil {
+		email.SendAt = sendAt
+		sg.emailLog(email).Error("can't defer email %s in quiet hours, sending now: %v ", email.TemplateName, err)
+		return false
+	}
+
+	return true
+}
+
+// splitQuiet schedules personalizations of the email with given indexes as a new email until the time
+// and removes them from the email. They are kept in the email if scheduling fails.
+// Status and webhook target of the new email are created like for accepted emails.
+func (sg *SendGrid) splitQuiet(email *pkg.TemplgridEmailEntity, deferred []int, until, now time.Time) {
+	log := sg.emailLog(email)
+	split := *email
+	split.ID = helper.NewID()
+	split.SendAt = &until
+	split.SendGridParameters.Personalizations = nil
+	split.PersonalizationTimezones = nil
+	var kept []*mail.Personalization
+	var keptTimezones []string
+	next := 0
+	for i, ps := range email.SendGridParameters.Personalizations {
+		if next < len(deferred) && deferred[next] == i {
+			next++
+			split.SendGridParameters.Personalizations = append(split.SendGridParameters.Personalizations, ps)
+			split.PersonalizationTimezones = append(split.PersonalizationTimezones, email.Timezone(i))
+			continue
+		}
+		kept = append(kept, ps)
+		keptTimezones = append(keptTimezones, email.Timezone(i))
+	}
+
+	if err := sg.scheduler.Schedule(&split); err != nil {
+		log.Error("can't defer personalizations of email %s in quiet hours, sending now: %v ", email.TemplateName, err)
+		return
+	}
+	if err := sg.statuses.Create(split.ID, split.TemplateName, split.KeyName); err != nil {
+		log.Error("can't create status of email %s split from %s: %v ", split.ID, email.ID, err)
+	}
+	if sg.notifier != nil {
+		sg.notifier.Watch(&split)
+	}
+	if err := sg.statuses.SetState(split.ID, status.Scheduled, nil); err != nil {
+		log.Debug("can't set status %s for email %s: %v ", status.Scheduled, split.ID, err)
+	}
+
+	email.SendGridParameters.Personalizations = kept
+	email.PersonalizationTimezones = keptTimezones
+	event := status.Event{Type: EventSplit, Reason: split.ID, Timestamp: now.UTC()}
+	if err := sg.statuses.AddEvent(email.ID, event); err != nil {
+		log.Debug("can't record split of email %s: %v ", email.ID, err)
+	}
+	log.Info("%d personalizations of email %s %s deferred by quiet hours until %s as %s",
+		len(deferred), email.TemplateName, email.ID, until, split.ID)
+}
+
+// location returns the timezone, nil if it's empty or gone since validation,
+// so location of quiet hours is used.
+func location(timezone string) *time.Location {
+	if timezone == "" {
+		return nil
+	}
+	loc, _ := time.LoadLocation(timezone)
+
+	return loc
+}
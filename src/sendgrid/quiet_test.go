@@ -0,0 +1,137 @@
+package sendgrid
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+
+	"github.com/pralolik/templgrid/pkg"
+	"github.com/pralolik/templgrid/src/logging"
+	"github.com/pralolik/templgrid/src/scheduler"
+	"github.com/pralolik/templgrid/src/status"
+	"github.com/pralolik/templgrid/src/templatemanager"
+)
+
+func TestDeferQuietMixedTimezones(t *testing.T) {
+	log := &logging.DisabledLog{}
+	storage := templatemanager.NewEmailStorage()
+	storage.AddOptions("digest", templatemanager.TemplateOptions{
+		QuietHours: &templatemanager.QuietHours{Start: 22 * time.Hour, End: 8 * time.Hour, Location: time.UTC},
+	})
+	statuses := status.NewMemoryStore(status.DefTTL)
+	sched := scheduler.New(log, scheduler.NewMemoryStore())
+	sg := NewSendGrid("", true, log, storage, WithStatusStore(statuses), WithScheduler(sched))
+
+	var email pkg.TemplgridEmailEntity
+	err := json.Unmarshal([]byte(`{
+		"template_name": "digest",
+		"send_grid_parameters": {
+			"personalizations": [
+				{"to": [{"email": "berlin@example.com"}], "timezone": "Europe/Berlin"},
+				{"to": [{"email": "ny@example.com"}], "timezone": "America/New_York"},
+				{"to": [{"email": "utc@example.com"}]},
+				{"to": [{"email": "tokyo@example.com"}], "timezone": "Asia/Tokyo"}
+			]
+		}
+	}`), &email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = email.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	email.ID = "m1"
+	if err = statuses.Create(email.ID, email.TemplateName, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// 00:00 in Berlin, 19:00 in New York, 08:00 in Tokyo.
+	now := time.Date(2026, 10, 19, 23, 0, 0, 0, time.UTC)
+	if sg.deferQuiet(&email, now) {
+		t.Fatal("email with personalizations out of quiet hours is deferred")
+	}
+	if got := addresses(email.SendGridParameters.Personalizations); got != "ny@example.com,tokyo@example.com" {
+		t.Errorf("sent now to %s, want ny and tokyo", got)
+	}
+
+	st, err := statuses.Get(email.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(st.Events) != 1 || st.Events[0].Type != EventSplit {
+		t.Fatalf("status events = %+v, want split event", st.Events)
+	}
+	split, err := sched.Get(st.Events[0].Reason)
+	if err != nil {
+		t.Fatalf("split email is not scheduled: %v", err)
+	}
+	if got := addresses(split.SendGridParameters.Personalizations); got != "berlin@example.com,utc@example.com" {
+		t.Errorf("deferred to %s, want berlin and utc", got)
+	}
+	// the earliest end of quiet hours is 08:00 in Berlin.
+	if want := time.Date(2026, 10, 20, 6, 0, 0, 0, time.UTC); split.SendAt == nil || !split.SendAt.Equal(want) {
+		t.Errorf("split email send at %v, want %s", split.SendAt, want)
+	}
+	if split.Timezone(0) != "Europe/Berlin" || split.Timezone(1) != "" {
+		t.Errorf("split timezones = %v", split.PersonalizationTimezones)
+	}
+	if splitSt, err := statuses.Get(split.ID); err != nil || splitSt.State != status.Scheduled {
+		t.Errorf("split email status = %+v, %v, want scheduled", splitSt, err)
+	}
+
+	// on release at 06:00 UTC the utc recipient is still in quiet hours.
+	if sg.deferQuiet(split, *split.SendAt) {
+		t.Fatal("split email is deferred again as a whole")
+	}
+	if got := addresses(split.SendGridParameters.Personalizations); got != "berlin@example.com" {
+		t.Errorf("released split email is sent to %s, want berlin", got)
+	}
+}
+
+func TestDeferQuietAllPersonalizations(t *testing.T) {
+	log := &logging.DisabledLog{}
+	storage := templatemanager.NewEmailStorage()
+	storage.AddOptions("digest", templatemanager.TemplateOptions{
+		QuietHours: &templatemanager.QuietHours{Start: 22 * time.Hour, End: 8 * time.Hour, Location: time.UTC},
+	})
+	sched := scheduler.New(log, scheduler.NewMemoryStore())
+	sg := NewSendGrid("", true, log, storage, WithScheduler(sched))
+	email := pkg.TemplgridEmailEntity{ID: "m1", TemplateName: "digest", RecipientTimezone: "Europe/Berlin"}
+	email.SendGridParameters.Personalizations = personalizations("a@example.com", "b@example.com")
+
+	if !sg.deferQuiet(&email, time.Date(2026, 10, 19, 23, 0, 0, 0, time.UTC)) {
+		t.Fatal("email in quiet hours is sent")
+	}
+	if _, err := sched.Get("m1"); err != nil {
+		t.Errorf("email is not scheduled: %v", err)
+	}
+	if len(email.SendGridParameters.Personalizations) != 2 {
+		t.Errorf("deferred email has %d personalizations, want 2", len(email.SendGridParameters.Personalizations))
+	}
+}
+
+func personalizations(addresses ...string) []*mail.Personalization {
+	var list []*mail.Personalization
+	for _, address := range addresses {
+		ps := mail.NewPersonalization()
+		ps.AddTos(mail.NewEmail("", address))
+		list = append(list, ps)
+	}
+
+	return list
+}
+
+// addresses joins `to` addresses of personalizations.
+func addresses(list []*mail.Personalization) string {
+	var joined []string
+	for _, ps := range list {
+		for _, to := range ps.To {
+			joined = append(joined, to.Address)
+		}
+	}
+
+	return strings.Join(joined, ",")
+}
@@ -2,95 +2,467 @@ package sendgrid
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/sendgrid/rest"
 	"github.com/sendgrid/sendgrid-go"
 	"github.com/sendgrid/sendgrid-go/helpers/mail"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/pralolik/templgrid/pkg"
+	"github.com/pralolik/templgrid/src/history"
 	"github.com/pralolik/templgrid/src/logging"
+	"github.com/pralolik/templgrid/src/metrics"
+	"github.com/pralolik/templgrid/src/notify"
 	"github.com/pralolik/templgrid/src/queue"
+	"github.com/pralolik/templgrid/src/scheduler"
+	"github.com/pralolik/templgrid/src/status"
 	"github.com/pralolik/templgrid/src/templatemanager"
+	"github.com/pralolik/templgrid/src/tracing"
+	"github.com/pralolik/templgrid/src/tracking"
+	"github.com/pralolik/templgrid/src/unsubscribe"
 )
 
+const (
+	DefMaxRetries   = 3
+	DefRetryBackoff = time.Second
+
+	// messageIDHeader holds id of the message accepted by SendGrid.
+	messageIDHeader = "X-Message-Id"
+)
+
+// ErrPermanent marks send errors which won't be fixed by another attempt.
+var ErrPermanent = errors.New("permanent send error")
+
+// errDeferred is returned when the email is rescheduled instead of sending.
+var errDeferred = errors.New("email deferred")
+
 type SendGrid struct {
 	log       logging.Logger
 	storage   *templatemanager.EmailStorage
 	client    *sendgrid.Client
 	isSandBox bool
+	statuses  status.Store
+	filters   []RecipientFilter
+	metrics   *metrics.Metrics
+	scheduler *scheduler.Scheduler
+	// unsubscribe is nil if unsubscribe links are disabled.
+	unsubscribe *unsubscribe.Signer
+	// tracker is nil if open and click tracking is disabled.
+	tracker *tracking.Tracker
+	// history is nil if send history is disabled.
+	history *history.History
+	// notifier is nil if notifications are disabled.
+	notifier *notify.Notifier
+
+	concurrency  int
+	workers      atomic.Int32
+	sent         atomic.Int64
+	failed       atomic.Int64
+	dropped      atomic.Int64
+	throttle     *throttle
+	maxRetries   int
+	retryBackoff time.Duration
 }
 
-func NewSendGrid(apiKey string, isSandBox bool, log logging.Logger, storage *templatemanager.EmailStorage) *SendGrid {
-	return &SendGrid{
+func NewSendGrid(
+	apiKey string,
+	isSandBox bool,
+	log logging.Logger,
+	storage *templatemanager.EmailStorage,
+	options ...Option) *SendGrid {
+	sg := &SendGrid{
 		log:       log,
 		storage:   storage,
 		client:    sendgrid.NewSendClient(apiKey),
 		isSandBox: isSandBox,
+		statuses:  status.NewMemoryStore(status.DefTTL),
+
+		concurrency:  1,
+		throttle:     newThrottle(0),
+		maxRetries:   DefMaxRetries,
+		retryBackoff: DefRetryBackoff,
+	}
+	for _, option := range options {
+		option(sg)
+	}
+
+	return sg
+}
+
+type Option func(sg *SendGrid)
+
+func WithStatusStore(statuses status.Store) Option {
+	return func(sg *SendGrid) {
+		sg.statuses = statuses
 	}
 }
 
+// WithConcurrency sets count of workers sending emails in parallel.
+func WithConcurrency(workers int) Option {
+	return func(sg *SendGrid) {
+		if workers > 0 {
+			sg.concurrency = workers
+		}
+	}
+}
+
+// WithRateLimit limits outbound requests per second of all workers, 0 means unlimited.
+func WithRateLimit(rps float64) Option {
+	return func(sg *SendGrid) {
+		sg.throttle = newThrottle(rps)
+	}
+}
+
+// WithRetries sets max retries of temporary failures and initial backoff between them.
+func WithRetries(maxRetries int, backoff time.Duration) Option {
+	return func(sg *SendGrid) {
+		sg.maxRetries = maxRetries
+		if backoff > 0 {
+			sg.retryBackoff = backoff
+		}
+	}
+}
+
+// WithMetrics reports render, provider and retry metrics.
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(sg *SendGrid) {
+		sg.metrics = m
+	}
+}
+
+// WithScheduler reschedules emails deferred by template quiet hours,
+// they are sent immediately if nil.
+func WithScheduler(s *scheduler.Scheduler) Option {
+	return func(sg *SendGrid) {
+		sg.scheduler = s
+	}
+}
+
+// WithUnsubscribe fills unsubscribe links of recipients and one-click headers of marketing emails.
+func WithUnsubscribe(signer *unsubscribe.Signer) Option {
+	return func(sg *SendGrid) {
+		sg.unsubscribe = signer
+	}
+}
+
+// WithTracker rewrites links and adds open pixel of templates with tracking enabled.
+func WithTracker(tracker *tracking.Tracker) Option {
+	return func(sg *SendGrid) {
+		sg.tracker = tracker
+	}
+}
+
+// WithHistory records every sent, failed and dropped email.
+func WithHistory(h *history.History) Option {
+	return func(sg *SendGrid) {
+		sg.history = h
+	}
+}
+
+// WithNotifier posts status changes of personalizations split from emails by quiet hours.
+func WithNotifier(n *notify.Notifier) Option {
+	return func(sg *SendGrid) {
+		sg.notifier = n
+	}
+}
+
+// WithRecipientFilter adds filter applied to every recipient before sending.
+// Filters are applied in the order of adding.
+func WithRecipientFilter(filter RecipientFilter) Option {
+	return func(sg *SendGrid) {
+		sg.filters = append(sg.filters, filter)
+	}
+}
+
+// Stats holds counts of processed emails.
+type Stats struct {
+	Sent    int64
+	Failed  int64
+	Dropped int64
+}
+
+// Stats returns counts of emails processed since start.
+func (sg *SendGrid) Stats() Stats {
+	return Stats{
+		Sent:    sg.sent.Load(),
+		Failed:  sg.failed.Load(),
+		Dropped: sg.dropped.Load(),
+	}
+}
+
+// Run sends emails until the queue channel is closed, in-flight emails are finished.
+// Cancelling ctx aborts in-flight sends, so it should be cancelled only on shutdown deadline.
 func (sg *SendGrid) Run(ctx context.Context, q queue.Interface) error {
 	queueChannel, err := q.GetChannel()
 	if err != nil {
 		return err
 	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < sg.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sg.work(ctx, queueChannel)
+		}()
+	}
+	wg.Wait()
+
+	return nil
+}
+
+func (sg *SendGrid) work(ctx context.Context, queueChannel chan *pkg.TemplgridEmailEntity) {
+	sg.workers.Add(1)
+	defer sg.workers.Add(-1)
 	for {
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
-		case email := <-queueChannel:
-			err = sg.sendEmail(email)
+			return
+		case email, ok := <-queueChannel:
+			if !ok {
+				return
+			}
+			sg.metrics.Dequeued()
+			log := sg.emailLog(email)
+			var rec history.Record
+			err := sg.process(ctx, email, &rec)
+			if errors.Is(err, errDeferred) {
+				log.Info("email %s deferred by quiet hours until %s", email.TemplateName, email.SendAt)
+				continue
+			}
+			sg.setStatus(email, err)
+			sg.record(email, &rec, err)
+			sg.count(err)
+			if errors.Is(err, ErrAllRecipientsDropped) {
+				log.Info("email %s %s not sent: %v ", email.TemplateName, email.ID, err)
+				continue
+			}
+			if errors.Is(err, ErrPermanent) {
+				sg.metrics.DeadLettered(metrics.ReasonPermanent)
+				log.Error("email %s dropped: %v ", email.TemplateName, err)
+				continue
+			}
 			if err != nil {
-				sg.log.Error("error send email %s: %v ", email.TemplateName, err)
+				if errors.Is(err, ErrTemporary) {
+					sg.metrics.DeadLettered(metrics.ReasonRetriesExhausted)
+				}
+				log.Error("error send email %s: %v ", email.TemplateName, err)
 				continue
 			}
-			sg.log.Info("email sent %s", email.TemplateName)
+			log.Info("email sent %s", email.TemplateName)
 		}
 	}
 }
 
-func (sg *SendGrid) sendEmail(email *pkg.TemplgridEmailEntity) error {
-	var err error
+func (sg *SendGrid) count(err error) {
+	switch {
+	case err == nil:
+		sg.sent.Add(1)
+	case errors.Is(err, ErrAllRecipientsDropped):
+		sg.dropped.Add(1)
+	default:
+		sg.failed.Add(1)
+	}
+}
+
+// process sends email within consumer span continuing trace stored in the email,
+// rec gets subject, body and provider id of the sent email.
+func (sg *SendGrid) process(ctx context.Context, email *pkg.TemplgridEmailEntity, rec *history.Record) error {
+	ctx, span := tracing.Tracer().Start(tracing.Extract(ctx, email), "templgrid.send",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(tracing.MessageIDKey.String(email.ID), tracing.TemplateNameKey.String(email.TemplateName)))
+	defer span.End()
+
+	if sg.deferQuiet(email, time.Now()) {
+		span.AddEvent("deferred by quiet hours")
+		return errDeferred
+	}
+	err := sg.sendEmail(ctx, email, rec)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return err
+}
+
+func (sg *SendGrid) sendEmail(ctx context.Context, email *pkg.TemplgridEmailEntity, rec *history.Record) (err error) {
 	var subject, emailHTML string
+	log := sg.emailLog(email)
+
+	if err = sg.applyFilters(email); err != nil {
+		return err
+	}
+	// recipients are counted by filters once the email is accepted by SendGrid.
+	defer func() {
+		if err != nil {
+			sg.refund(email)
+		}
+	}()
 
-	if subject, emailHTML, err = sg.buildEmail(email); err != nil {
+	if subject, emailHTML, err = sg.buildEmail(ctx, email); err != nil {
 		return err
 	}
-	sg.log.Debug(
-		"email %s for locale %s built subject: %s content: %s",
-		email.TemplateName,
-		email.Locale,
-		subject,
-		emailHTML)
+	emailHTML = sg.track(email, emailHTML)
+	rec.Subject, rec.Body = subject, emailHTML
+	debugRender := sg.storage.Options(email.TemplateName).DebugRender
+	if debugRender {
+		log.Debug(
+			"email %s for locale %s built subject: %s content: %s",
+			email.TemplateName,
+			email.Locale,
+			subject,
+			emailHTML)
+	} else {
+		log.Debug("email %s for locale %s built", email.TemplateName, email.Locale)
+	}
 
 	sgMail := &email.SendGridParameters
 	sgMail.Subject = subject
 	sgMail.AddContent(mail.NewContent("text/html", emailHTML))
 	sg.setSandBox(sgMail)
-	sg.log.Debug("email object prepared %v", sgMail)
+	sgMail.SetCustomArg(CustomArgMessageID, email.ID)
+	sg.setUnsubscribe(email, emailHTML)
+	if debugRender {
+		log.Debug("email object prepared %v", sgMail)
+	}
 
-	var res *rest.Response
-	if res, err = sg.client.Send(sgMail); err != nil {
-		return err
+	backoff := sg.retryBackoff
+	for attempt := 0; ; attempt++ {
+		rec.ProviderID, err = sg.send(ctx, sgMail)
+		if err == nil || !errors.Is(err, ErrTemporary) || attempt >= sg.maxRetries {
+			return err
+		}
+		sg.metrics.Retried()
+
+		var rlErr *rateLimitedError
+		if errors.As(err, &rlErr) {
+			log.Info("sendgrid rate limit exceeded, sending paused for %s", rlErr.pause)
+			sg.throttle.pause(rlErr.pause)
+		} else {
+			log.Info("retry email %s %s in %s: %v ", email.TemplateName, email.ID, backoff, err)
+			if err = sleep(ctx, backoff); err != nil {
+				return err
+			}
+			backoff *= 2
+		}
+	}
+}
+
+// send returns message id assigned by SendGrid.
+func (sg *SendGrid) send(ctx context.Context, sgMail *mail.SGMailV3) (string, error) {
+	if err := sg.throttle.wait(ctx); err != nil {
+		return "", err
+	}
+
+	ctx, span := tracing.Tracer().Start(ctx, "sendgrid.send", trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+
+	start := time.Now()
+	res, err := sg.client.SendWithContext(ctx, sgMail)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		sg.metrics.ObserveProvider(0, time.Since(start))
+		if ctx.Err() != nil {
+			return "", err
+		}
+		return "", fmt.Errorf("%w: %w", ErrTemporary, err)
 	}
 
+	sg.metrics.ObserveProvider(res.StatusCode, time.Since(start))
+	span.SetAttributes(attribute.Int("http.response.status_code", res.StatusCode))
+	if res.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, http.StatusText(res.StatusCode))
+	}
 	sg.log.Debug("response from sendgrid :%v ", res)
-	if err = sg.processResponse(res); err != nil {
-		return err
+
+	return providerMessageID(res), sg.processResponse(res)
+}
+
+// track returns content with tracked links and open pixel, original content if tracking fails.
+func (sg *SendGrid) track(email *pkg.TemplgridEmailEntity, emailHTML string) string {
+	if sg.tracker == nil {
+		return emailHTML
+	}
+	opts := sg.storage.Options(email.TemplateName)
+	tracked, err := sg.tracker.Rewrite(emailHTML, email.ID, opts.TrackClicks, opts.TrackOpens)
+	if err != nil {
+		sg.emailLog(email).Error("can't track email %s, sending untracked: %v ", email.TemplateName, err)
+		return emailHTML
 	}
 
-	return nil
+	return tracked
 }
 
-func (sg *SendGrid) buildEmail(email *pkg.TemplgridEmailEntity) (string, string, error) {
+func (sg *SendGrid) buildEmail(ctx context.Context, email *pkg.TemplgridEmailEntity) (string, string, error) {
+	_, span := tracing.Tracer().Start(ctx, "templgrid.render")
+	defer span.End()
+
+	start := time.Now()
 	subject, emailHTML, err := sg.storage.BuildEmail(email.TemplateName, email.Locale, email.EmailParameters)
+	sg.metrics.ObserveRender(email.TemplateName, time.Since(start), err)
 	if err != nil {
-		return "", "", err
+		span.SetStatus(codes.Error, err.Error())
+		return "", "", fmt.Errorf("%w: %w", ErrPermanent, err)
 	}
 	return subject, emailHTML, nil
 }
 
+// sendState returns status of the email processed with sendErr.
+func sendState(sendErr error) status.State {
+	switch {
+	case errors.Is(sendErr, ErrAllRecipientsDropped):
+		return status.Dropped
+	case sendErr != nil:
+		return status.Failed
+	}
+
+	return status.Sent
+}
+
+func (sg *SendGrid) setStatus(email *pkg.TemplgridEmailEntity, sendErr error) {
+	state := sendState(sendErr)
+	if err := sg.statuses.SetState(email.ID, state, sendErr); err != nil {
+		sg.emailLog(email).Debug("can't set status %s for email %s: %v ", state, email.ID, err)
+	}
+}
+
+// record adds history record of the processed email with recipients left after filters.
+func (sg *SendGrid) record(email *pkg.TemplgridEmailEntity, rec *history.Record, sendErr error) {
+	if sg.history == nil {
+		return
+	}
+	rec.ID = email.ID
+	rec.TemplateName = email.TemplateName
+	rec.Locale = email.Locale
+	rec.KeyName = email.KeyName
+	rec.State = sendState(sendErr)
+	if sendErr != nil {
+		rec.Error = sendErr.Error()
+	}
+	rec.SentAt = time.Now().UTC()
+	for _, ps := range email.SendGridParameters.Personalizations {
+		for _, addresses := range [][]*mail.Email{ps.To, ps.CC, ps.BCC} {
+			for _, address := range addresses {
+				rec.Recipients = append(rec.Recipients, address.Address)
+			}
+		}
+	}
+	sg.history.Record(*rec)
+}
+
+// emailLog returns logger with message id and template fields of the email.
+func (sg *SendGrid) emailLog(email *pkg.TemplgridEmailEntity) logging.Logger {
+	return logging.With(sg.log, logging.MessageIDKey, email.ID, logging.TemplateKey, email.TemplateName)
+}
+
 func (sg *SendGrid) setSandBox(sgMail *mail.SGMailV3) {
 	isSandBox := sg.isSandBox
 	if sgMail.MailSettings == nil {
@@ -99,9 +471,23 @@ func (sg *SendGrid) setSandBox(sgMail *mail.SGMailV3) {
 	sgMail.MailSettings.SetSandboxMode(&mail.Setting{Enable: &isSandBox})
 }
 
+// providerMessageID reads id of the accepted message, empty for rejected ones.
+func providerMessageID(res *rest.Response) string {
+	if res.StatusCode >= http.StatusBadRequest {
+		return ""
+	}
+
+	return http.Header(res.Headers).Get(messageIDHeader)
+}
+
 func (sg *SendGrid) processResponse(response *rest.Response) error {
-	if response.StatusCode >= 400 {
-		return fmt.Errorf("%d response, body: %s", response.StatusCode, response.Body)
+	switch {
+	case response.StatusCode == http.StatusTooManyRequests:
+		return &rateLimitedError{pause: rateLimitPause(response), body: response.Body}
+	case response.StatusCode >= http.StatusInternalServerError:
+		return fmt.Errorf("%w: %d response, body: %s", ErrTemporary, response.StatusCode, response.Body)
+	case response.StatusCode >= http.StatusBadRequest:
+		return fmt.Errorf("%w: %d response, body: %s", ErrPermanent, response.StatusCode, response.Body)
 	}
 
 	return nil
@@ -0,0 +1,124 @@
+package sendgrid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sendgrid/rest"
+	"golang.org/x/time/rate"
+)
+
+const (
+	rateLimitResetHeader = "X-Ratelimit-Reset"
+	retryAfterHeader     = "Retry-After"
+	defRateLimitPause    = time.Second
+	maxRateLimitPause    = time.Minute
+)
+
+// ErrTemporary marks send errors which may succeed on another attempt.
+var ErrTemporary = errors.New("temporary send error")
+
+// rateLimitedError is returned on 429 response, holds time until the provider accepts requests again.
+type rateLimitedError struct {
+	pause time.Duration
+	body  string
+}
+
+func (e *rateLimitedError) Error() string {
+	return fmt.Sprintf("429 response, paused for %s, body: %s", e.pause, e.body)
+}
+
+func (e *rateLimitedError) Unwrap() error { return ErrTemporary }
+
+// throttle limits outbound requests of all workers and pauses them
+// when the provider reports exceeded rate limit.
+type throttle struct {
+	limiter *rate.Limiter
+
+	mu          sync.Mutex
+	pausedUntil time.Time
+}
+
+func newThrottle(rps float64) *throttle {
+	limit := rate.Inf
+	if rps > 0 {
+		limit = rate.Limit(rps)
+	}
+
+	return &throttle{limiter: rate.NewLimiter(limit, 1)}
+}
+
+// wait blocks until the request is allowed.
+// Pause set while waiting for the limiter is respected as well.
+func (t *throttle) wait(ctx context.Context) error {
+	if err := t.waitPause(ctx); err != nil {
+		return err
+	}
+	if err := t.limiter.Wait(ctx); err != nil {
+		return err
+	}
+
+	return t.waitPause(ctx)
+}
+
+func (t *throttle) waitPause(ctx context.Context) error {
+	for {
+		t.mu.Lock()
+		pause := time.Until(t.pausedUntil)
+		t.mu.Unlock()
+		if pause <= 0 {
+			return nil
+		}
+		if err := sleep(ctx, pause); err != nil {
+			return err
+		}
+	}
+}
+
+// pause stops all requests for d.
+func (t *throttle) pause(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if until := time.Now().Add(d); until.After(t.pausedUntil) {
+		t.pausedUntil = until
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// rateLimitPause reads time to wait from the provider rate limit headers.
+func rateLimitPause(res *rest.Response) time.Duration {
+	header := http.Header(res.Headers)
+	pause := defRateLimitPause
+	if reset, err := strconv.ParseInt(header.Get(rateLimitResetHeader), 10, 64); err == nil {
+		pause = time.Until(time.Unix(reset, 0))
+	} else if seconds, err := strconv.Atoi(header.Get(retryAfterHeader)); err == nil {
+		pause = time.Duration(seconds) * time.Second
+	}
+
+	switch {
+	case pause <= 0:
+		return defRateLimitPause
+	case pause > maxRateLimitPause:
+		return maxRateLimitPause
+	default:
+		return pause
+	}
+}
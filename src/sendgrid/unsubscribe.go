@@ -0,0 +1,109 @@
+package sendgrid
+
+import (
+	"maps"
+	"strings"
+
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+
+	"github.com/pralolik/templgrid/pkg"
+	"github.com/pralolik/templgrid/src/templatemanager"
+	"github.com/pralolik/templgrid/src/unsubscribe"
+)
+
+// setUnsubscribe replaces unsubscribe and preferences tags of the content with links signed for the recipient
+// of every personalization, marketing emails get RFC 8058 one-click headers with the same link.
+// Personalizations with several `to` recipients are split, so every link unsubscribes only its recipient.
+// Recipients of personalizations with cc or bcc share the message, they get no links.
+func (sg *SendGrid) setUnsubscribe(email *pkg.TemplgridEmailEntity, emailHTML string) {
+	if sg.unsubscribe == nil {
+		return
+	}
+	opts := sg.storage.Options(email.TemplateName)
+	tagged := strings.Contains(emailHTML, templatemanager.UnsubscribeTag)
+	prefsTagged := strings.Contains(emailHTML, templatemanager.PreferencesTag)
+	if !tagged && !prefsTagged && !opts.Marketing {
+		return
+	}
+	sgMail := &email.SendGridParameters
+	sgMail.Personalizations = splitPersonalizations(sgMail.Personalizations)
+	for _, ps := range sgMail.Personalizations {
+		link, prefsLink := "", ""
+		if len(ps.To) == 1 && len(ps.CC) == 0 && len(ps.BCC) == 0 {
+			link = sg.unsubscribe.URL(ps.To[0].Address, opts.Category)
+			prefsLink = sg.unsubscribe.PreferencesURL(ps.To[0].Address)
+		} else {
+			sg.emailLog(email).Info("email %s %s personalization has shared recipients, unsubscribe links are omitted",
+				email.TemplateName, email.ID)
+		}
+		if tagged {
+			setSubstitution(ps, templatemanager.UnsubscribeTag, link)
+		}
+		if prefsTagged {
+			setSubstitution(ps, templatemanager.PreferencesTag, prefsLink)
+		}
+		if opts.Marketing && link != "" {
+			setHeader(ps, unsubscribe.ListHeader, "<"+link+">")
+			setHeader(ps, unsubscribe.OneClickHeader, unsubscribe.OneClickValue)
+		}
+	}
+}
+
+// splitPersonalizations returns personalizations with one `to` recipient each, personalizations with cc or bcc
+// are kept, because their recipients would get copies of every split. Personalizations are kept if splitting
+// exceeds pkg.MaxPersonalizationPerRequest.
+func splitPersonalizations(personalizations []*mail.Personalization) []*mail.Personalization {
+	count := 0
+	for _, ps := range personalizations {
+		if len(ps.CC) == 0 && len(ps.BCC) == 0 {
+			count += max(len(ps.To), 1)
+		} else {
+			count++
+		}
+	}
+	if count == len(personalizations) || count > pkg.MaxPersonalizationPerRequest {
+		return personalizations
+	}
+	split := make([]*mail.Personalization, 0, count)
+	for _, ps := range personalizations {
+		if len(ps.To) <= 1 || len(ps.CC) > 0 || len(ps.BCC) > 0 {
+			split = append(split, ps)
+			continue
+		}
+		for _, to := range ps.To {
+			split = append(split, withRecipient(ps, to))
+		}
+	}
+
+	return split
+}
+
+// withRecipient returns copy of the personalization sent only to the recipient.
+func withRecipient(ps *mail.Personalization, to *mail.Email) *mail.Personalization {
+	psCopy := *ps
+	psCopy.To = []*mail.Email{to}
+	psCopy.CC = nil
+	psCopy.BCC = nil
+	psCopy.Headers = maps.Clone(ps.Headers)
+	psCopy.Substitutions = maps.Clone(ps.Substitutions)
+	psCopy.CustomArgs = maps.Clone(ps.CustomArgs)
+	psCopy.DynamicTemplateData = maps.Clone(ps.DynamicTemplateData)
+
+	return &psCopy
+}
+
+// setSubstitution sets substitution of personalization decoded from the request, its map may be nil.
+func setSubstitution(ps *mail.Personalization, key, value string) {
+	if ps.Substitutions == nil {
+		ps.Substitutions = map[string]string{}
+	}
+	ps.SetSubstitution(key, value)
+}
+
+// setHeader sets header of personalization decoded from the request, its map may be nil.
+func setHeader(ps *mail.Personalization, key, value string) {
+	if ps.Headers == nil {
+		ps.Headers = map[string]string{}
+	}
+	ps.SetHeader(key, value)
+}
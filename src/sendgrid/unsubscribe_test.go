@@ -0,0 +1,68 @@
+package sendgrid
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/pralolik/templgrid/pkg"
+	"github.com/pralolik/templgrid/src/logging"
+	"github.com/pralolik/templgrid/src/templatemanager"
+	"github.com/pralolik/templgrid/src/unsubscribe"
+)
+
+func TestSetUnsubscribe(t *testing.T) {
+	storage := templatemanager.NewEmailStorage()
+	storage.AddOptions("news", templatemanager.TemplateOptions{Marketing: true, Category: "newsletters"})
+	signer := unsubscribe.NewSigner("secret", "https://mail.example.com")
+	sg := NewSendGrid("", true, &logging.DisabledLog{}, storage, WithUnsubscribe(signer))
+
+	var email pkg.TemplgridEmailEntity
+	err := json.Unmarshal([]byte(`{
+		"template_name": "news",
+		"send_grid_parameters": {
+			"personalizations": [
+				{"to": [{"email": "john@example.com"}, {"email": "jane@example.org"}], "custom_args": {"campaign": "fall"}},
+				{"to": [{"email": "max@example.net"}], "cc": [{"email": "boss@example.net"}]}
+			]
+		}
+	}`), &email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sg.setUnsubscribe(&email, "<a href=\""+templatemanager.UnsubscribeTag+"\">unsubscribe</a>")
+
+	personalizations := email.SendGridParameters.Personalizations
+	if len(personalizations) != 3 {
+		t.Fatalf("got %d personalizations, want 3", len(personalizations))
+	}
+	for i, address := range []string{"john@example.com", "jane@example.org"} {
+		ps := personalizations[i]
+		if len(ps.To) != 1 || ps.To[0].Address != address {
+			t.Fatalf("personalization %d is sent to %v, want %s", i, ps.To, address)
+		}
+		link := signer.URL(address, "newsletters")
+		if ps.Substitutions[templatemanager.UnsubscribeTag] != link {
+			t.Errorf("%s link = %q, want %q", address, ps.Substitutions[templatemanager.UnsubscribeTag], link)
+		}
+		if ps.Headers[unsubscribe.ListHeader] != "<"+link+">" || ps.Headers[unsubscribe.OneClickHeader] == "" {
+			t.Errorf("%s one-click headers = %v", address, ps.Headers)
+		}
+		if ps.CustomArgs["campaign"] != "fall" {
+			t.Errorf("%s custom args are lost: %v", address, ps.CustomArgs)
+		}
+	}
+
+	shared := personalizations[2]
+	if len(shared.To) != 1 || len(shared.CC) != 1 {
+		t.Fatalf("personalization with cc is changed: %+v", shared)
+	}
+	if link, ok := shared.Substitutions[templatemanager.UnsubscribeTag]; !ok || link != "" {
+		t.Errorf("shared personalization link = %q, want empty substitution", link)
+	}
+	for header := range shared.Headers {
+		if strings.HasPrefix(header, "List-Unsubscribe") {
+			t.Errorf("shared personalization has %s header", header)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package status
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// DefTTL is how long statuses are kept by default.
+	DefTTL          = 24 * time.Hour
+	cleanupInterval = time.Minute
+)
+
+// MemoryStore keeps statuses in memory for ttl after last update,
+// statuses of scheduled messages are kept until they are released.
+type MemoryStore struct {
+	mu          sync.RWMutex
+	ttl         time.Duration
+	statuses    map[string]*Status
+	lastCleanup time.Time
+}
+
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	return &MemoryStore{
+		ttl:      ttl,
+		statuses: map[string]*Status{},
+	}
+}
+
+func (s *MemoryStore) Create(id, templateName, keyName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	s.cleanup(now)
+	s.statuses[id] = &Status{
+		ID:           id,
+		TemplateName: templateName,
+		KeyName:      keyName,
+		State:        Queued,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) SetState(id string, state State, err error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok := s.statuses[id]
+	if !ok {
+		return ErrNotFound
+	}
+	st.State = state
+	st.Error = ""
+	if err != nil {
+		st.Error = err.Error()
+	}
+	st.UpdatedAt = time.Now().UTC()
+
+	return nil
+}
+
+func (s *MemoryStore) AddDropped(id string, recipient DroppedRecipient) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok := s.statuses[id]
+	if !ok {
+		return ErrNotFound
+	}
+	st.Dropped = append(st.Dropped, recipient)
+	st.UpdatedAt = time.Now().UTC()
+
+	return nil
+}
+
+func (s *MemoryStore) AddEvent(id string, event Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok := s.statuses[id]
+	if !ok {
+		return ErrNotFound
+	}
+	st.Events = append(st.Events, event)
+	st.UpdatedAt = time.Now().UTC()
+
+	return nil
+}
+
+func (s *MemoryStore) SetRecipientState(id string, recipient RecipientState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok := s.statuses[id]
+	if !ok {
+		return ErrNotFound
+	}
+	st.UpdatedAt = time.Now().UTC()
+	if recipient.UpdatedAt.IsZero() {
+		recipient.UpdatedAt = st.UpdatedAt
+	}
+	for i := range st.Recipients {
+		if st.Recipients[i].Address == recipient.Address {
+			st.Recipients[i] = recipient
+			return nil
+		}
+	}
+	st.Recipients = append(st.Recipients, recipient)
+
+	return nil
+}
+
+func (s *MemoryStore) Get(id string) (*Status, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	st, ok := s.statuses[id]
+	if !ok || s.expired(st, time.Now()) {
+		return nil, ErrNotFound
+	}
+	res := *st
+	res.Dropped = append([]DroppedRecipient(nil), st.Dropped...)
+	res.Events = append([]Event(nil), st.Events...)
+	res.Recipients = append([]RecipientState(nil), st.Recipients...)
+
+	return &res, nil
+}
+
+func (s *MemoryStore) cleanup(now time.Time) {
+	if now.Sub(s.lastCleanup) < cleanupInterval {
+		return
+	}
+	s.lastCleanup = now
+	for id, st := range s.statuses {
+		if s.expired(st, now) {
+			delete(s.statuses, id)
+		}
+	}
+}
+
+// expired reports whether status is not updated for ttl, scheduled messages may be held longer.
+func (s *MemoryStore) expired(st *Status, now time.Time) bool {
+	return st.State != Scheduled && now.Sub(st.UpdatedAt) > s.ttl
+}
@@ -0,0 +1,89 @@
+package status
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNotFound indicates that there is no status for the message id.
+var ErrNotFound = errors.New("message status not found")
+
+// State represents delivery state of the message.
+type State string
+
+const (
+	// Queued means the message is accepted and waits in the queue.
+	Queued State = "queued"
+	// Sent means the message is accepted by the provider.
+	Sent State = "sent"
+	// Failed means the message won't be sent.
+	Failed State = "failed"
+	// Dropped means all recipients were filtered out before sending.
+	Dropped State = "dropped"
+	// Scheduled means the message is held until its send time.
+	Scheduled State = "scheduled"
+	// Cancelled means the scheduled message was cancelled before sending.
+	Cancelled State = "cancelled"
+	// Delivered means the provider reported delivery to the recipient server.
+	Delivered State = "delivered"
+	// Bounced means the recipient server rejected the message.
+	Bounced State = "bounced"
+	// Complained means the recipient reported the message as spam.
+	Complained State = "complained"
+)
+
+// DroppedRecipient is the recipient removed from the message before sending.
+type DroppedRecipient struct {
+	Address string `json:"address"`
+	Reason  string `json:"reason"`
+}
+
+// Event is delivery event of the message reported by the provider or tracking,
+// URL is set for clicks.
+type Event struct {
+	Type      string    `json:"type"`
+	Address   string    `json:"address"`
+	Reason    string    `json:"reason,omitempty"`
+	URL       string    `json:"url,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// RecipientState is delivery state of the message reported by the provider for one recipient.
+type RecipientState struct {
+	Address   string    `json:"address"`
+	State     State     `json:"state"`
+	Reason    string    `json:"reason,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Status holds delivery state of the message.
+type Status struct {
+	ID           string `json:"id"`
+	TemplateName string `json:"template_name"`
+	// KeyName is the API key which sent the message, only it and admin keys may read the status.
+	KeyName string             `json:"key_name,omitempty"`
+	State   State              `json:"state"`
+	Error   string             `json:"error,omitempty"`
+	Dropped []DroppedRecipient `json:"dropped,omitempty"`
+	Events  []Event            `json:"events,omitempty"`
+	// Recipients are states of recipients reported by the provider, State is the most advanced of them.
+	Recipients []RecipientState `json:"recipients,omitempty"`
+	CreatedAt  time.Time        `json:"created_at"`
+	UpdatedAt  time.Time        `json:"updated_at"`
+}
+
+// Store represents storage of message statuses.
+type Store interface {
+	// Create stores new message of the API key in Queued state.
+	Create(id, templateName, keyName string) error
+	// SetState changes state of the message, err is stored as failure reason.
+	SetState(id string, state State, err error) error
+	// AddDropped records recipient removed from the message with the reason.
+	AddDropped(id string, recipient DroppedRecipient) error
+	// AddEvent records provider event of the message.
+	AddEvent(id string, event Event) error
+	// SetRecipientState changes state of the message recipient reported by the provider.
+	SetRecipientState(id string, recipient RecipientState) error
+	// Get returns status of the message or ErrNotFound.
+	Get(id string) (*Status, error)
+}
@@ -0,0 +1,122 @@
+package suppression
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var suppressionsBucket = []byte("suppressions")
+
+// BoltStore keeps suppressions in bbolt file.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+func NewBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("can't open suppression db: %w ", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(suppressionsBucket)
+		return err
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("can't create suppression bucket: %w ", err)
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+func (s *BoltStore) Add(entry Entry) error {
+	if err := entry.Validate(); err != nil {
+		return err
+	}
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now().UTC()
+	}
+	v, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("can't marshal suppression: %w ", err)
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(suppressionsBucket).Put([]byte(key(entry.Address, entry.Category)), v)
+	})
+}
+
+func (s *BoltStore) Remove(address, category string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(suppressionsBucket)
+		k := []byte(key(address, category))
+		if b.Get(k) == nil {
+			return ErrNotFound
+		}
+		return b.Delete(k)
+	})
+}
+
+func (s *BoltStore) Get(address, category string) (*Entry, error) {
+	var entry *Entry
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(suppressionsBucket)
+		v := b.Get([]byte(key(address, "")))
+		if v == nil && category != "" {
+			v = b.Get([]byte(key(address, category)))
+		}
+		if v == nil {
+			return ErrNotFound
+		}
+		entry = &Entry{}
+		return json.Unmarshal(v, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+func (s *BoltStore) List(cursor string, limit int) ([]Entry, string, error) {
+	after, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	entries := make([]Entry, 0, limit)
+	next := ""
+	err = s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(suppressionsBucket).Cursor()
+		k, v := c.First()
+		if after != "" {
+			k, v = c.Seek([]byte(after))
+			if k != nil && string(k) == after {
+				k, v = c.Next()
+			}
+		}
+		for ; k != nil; k, v = c.Next() {
+			if len(entries) == limit {
+				next = encodeCursor(entries[len(entries)-1].key())
+				return nil
+			}
+			var entry Entry
+			if err := json.Unmarshal(v, &entry); err != nil {
+				return fmt.Errorf("can't unmarshal suppression: %w ", err)
+			}
+			entries = append(entries, entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return entries, next, nil
+}
+
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,87 @@
+package suppression
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps suppressions in memory, they are lost on restart.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	entries map[string]Entry
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: map[string]Entry{}}
+}
+
+func (s *MemoryStore) Add(entry Entry) error {
+	if err := entry.Validate(); err != nil {
+		return err
+	}
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now().UTC()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key(entry.Address, entry.Category)] = entry
+
+	return nil
+}
+
+func (s *MemoryStore) Remove(address, category string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k := key(address, category)
+	if _, ok := s.entries[k]; !ok {
+		return ErrNotFound
+	}
+	delete(s.entries, k)
+
+	return nil
+}
+
+func (s *MemoryStore) Get(address, category string) (*Entry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if entry, ok := s.entries[key(address, "")]; ok {
+		return &entry, nil
+	}
+	if entry, ok := s.entries[key(address, category)]; ok && category != "" {
+		return &entry, nil
+	}
+
+	return nil, ErrNotFound
+}
+
+func (s *MemoryStore) List(cursor string, limit int) ([]Entry, string, error) {
+	after, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]string, 0, len(s.entries))
+	for k := range s.entries {
+		if k > after {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	next := ""
+	if len(keys) > limit {
+		keys = keys[:limit]
+		next = encodeCursor(keys[limit-1])
+	}
+	entries := make([]Entry, 0, len(keys))
+	for _, k := range keys {
+		entries = append(entries, s.entries[k])
+	}
+
+	return entries, next, nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
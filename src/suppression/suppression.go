@@ -0,0 +1,121 @@
+package suppression
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pralolik/templgrid/pkg"
+	"github.com/pralolik/templgrid/src/templatemanager"
+)
+
+const (
+	DefListLimit = 100
+	MaxListLimit = 1000
+)
+
+var (
+	// ErrNotFound is returned when there is no suppression of the address in the scope.
+	ErrNotFound = errors.New("suppression not found")
+	// ErrInvalid is returned for suppression without address or with unknown reason.
+	ErrInvalid = errors.New("invalid suppression")
+)
+
+// Reason is why the address is suppressed.
+type Reason string
+
+const (
+	ReasonBounce      Reason = "bounce"
+	ReasonComplaint   Reason = "complaint"
+	ReasonUnsubscribe Reason = "unsubscribe"
+	ReasonManual      Reason = "manual"
+)
+
+// Entry suppresses sending to the address, templates of the category only if it's set.
+type Entry struct {
+	Address   string    `json:"address"`
+	Reason    Reason    `json:"reason"`
+	Category  string    `json:"category,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Validate normalizes address and checks the reason.
+func (e *Entry) Validate() error {
+	e.Address = NormalizeAddress(e.Address)
+	if e.Address == "" || !strings.Contains(e.Address, "@") {
+		return fmt.Errorf("%w: incorrect address", ErrInvalid)
+	}
+	switch e.Reason {
+	case ReasonBounce, ReasonComplaint, ReasonUnsubscribe, ReasonManual:
+	default:
+		return fmt.Errorf("%w: unknown reason %s", ErrInvalid, e.Reason)
+	}
+
+	return nil
+}
+
+// NormalizeAddress makes addresses of the same mailbox equal.
+func NormalizeAddress(address string) string {
+	return strings.ToLower(strings.TrimSpace(address))
+}
+
+// Store represents storage of suppressions.
+type Store interface {
+	// Add stores the entry, replacing suppression of the address in the same scope.
+	Add(entry Entry) error
+	// Remove deletes suppression of the address in the category scope or ErrNotFound.
+	Remove(address, category string) error
+	// Get returns suppression of the address for templates of the category,
+	// suppression of all templates is returned first, ErrNotFound if there is none.
+	Get(address, category string) (*Entry, error)
+	// List returns up to limit entries ordered by address after the cursor,
+	// and cursor of the next page, empty if there are no more entries.
+	List(cursor string, limit int) ([]Entry, string, error)
+	Close() error
+}
+
+// Filter drops suppressed recipients before sending, implements sendgrid.RecipientFilter.
+type Filter struct {
+	store   Store
+	storage *templatemanager.EmailStorage
+}
+
+func NewFilter(store Store, storage *templatemanager.EmailStorage) *Filter {
+	return &Filter{store: store, storage: storage}
+}
+
+// Allow returns drop reason if the address is suppressed for the template category.
+// Addresses are allowed if the store fails, so suppression outage doesn't stop sending.
+func (f *Filter) Allow(email *pkg.TemplgridEmailEntity, address string) string {
+	entry, err := f.store.Get(address, f.storage.Options(email.TemplateName).Category)
+	if err != nil {
+		return ""
+	}
+
+	return "suppressed: " + string(entry.Reason)
+}
+
+func (e *Entry) key() string {
+	return key(e.Address, e.Category)
+}
+
+// key orders entries by address, category separates scopes of the same address.
+func key(address, category string) string {
+	return NormalizeAddress(address) + "\x00" + category
+}
+
+// encodeCursor makes cursor of the last listed key safe for URLs.
+func encodeCursor(k string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(k))
+}
+
+func decodeCursor(cursor string) (string, error) {
+	k, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", fmt.Errorf("%w: incorrect cursor", ErrInvalid)
+	}
+
+	return string(k), nil
+}
@@ -15,6 +15,14 @@ import (
 const MnBlck = "email"
 const SbjBlck = "subject"
 
+// UnsubscribeTag is rendered by unsubscribeURL function and replaced with signed link
+// of each recipient by the sender. It contains "=" to keep attribute quotes after minifying.
+const UnsubscribeTag = "-templgrid-unsubscribe-url=-"
+
+// PreferencesTag is rendered by preferencesURL function and replaced with signed link
+// of the recipient preference page by the sender.
+const PreferencesTag = "-templgrid-preferences-url=-"
+
 func getHTMLFromTemplate(
 	tmplt *template.Template,
 	components []string,
@@ -46,16 +54,25 @@ func getHTMLFromTemplate(
 	return
 }
 
-func getDefaultFunctionsMap(i10n map[string]string) template.FuncMap {
+func getDefaultFunctionsMap(i10n map[string]string, opts TemplateOptions) template.FuncMap {
 	return template.FuncMap{
 		"args": args,
 		"__": func(name string, input ...interface{}) (string, error) {
 			if format, ok := i10n[name]; ok {
 				return fmt.Sprintf(format, input...), nil
 			}
+			if opts.Strict {
+				return "", fmt.Errorf("no translation for key %s", name)
+			}
 			return "", nil
 		},
 		"unescape": html.UnescapeString,
+		"unsubscribeURL": func() template.URL {
+			return UnsubscribeTag
+		},
+		"preferencesURL": func() template.URL {
+			return PreferencesTag
+		},
 	}
 }
 
@@ -75,9 +92,12 @@ func args(keyValues ...interface{}) (map[string]interface{}, error) {
 	return paramsMap, nil
 }
 
-func createTemplate(txt, bn string, i10n map[string]string) (*template.Template, error) {
+func createTemplate(txt, bn string, i10n map[string]string, opts TemplateOptions) (*template.Template, error) {
 	t := template.New(bn)
-	t.Funcs(getDefaultFunctionsMap(i10n))
+	t.Funcs(getDefaultFunctionsMap(i10n, opts))
+	if opts.Strict {
+		t.Option("missingkey=error")
+	}
 	t, err := t.Parse(txt)
 	if err != nil {
 		return nil, fmt.Errorf("error with templatemanager parsing: %w ", err)
@@ -0,0 +1,69 @@
+package templatemanager
+
+import (
+	"time"
+
+	"github.com/pralolik/templgrid/pkg"
+)
+
+// DefLocale is the default locale used in strict mode for emails without locale and for missing translations.
+const DefLocale = "en"
+
+// TemplateOptions holds settings applied while building an email from template.
+type TemplateOptions struct {
+	// Strict makes missing parameters and unknown translation keys fail the build
+	// instead of being rendered as empty values.
+	Strict bool
+	// DebugRender allows logging built subject and content at debug level.
+	// Off by default as rendered emails contain personal data.
+	DebugRender bool
+	// QuietHours defers non-urgent emails, nil means no quiet hours.
+	QuietHours *QuietHours
+	// Priority is used for emails without priority in the request.
+	Priority pkg.Priority
+	// Category groups templates, like marketing or billing, for suppression scopes.
+	Category string
+	// Marketing emails get one-click List-Unsubscribe headers.
+	Marketing bool
+	// TrackClicks rewrites links to redirects recording clicks.
+	TrackClicks bool
+	// TrackOpens adds pixel recording opens.
+	TrackOpens bool
+}
+
+// QuietHours is the range of recipient local time when non-urgent emails are deferred,
+// the range may pass midnight like 22:00-08:00.
+type QuietHours struct {
+	// Start and End are offsets from local midnight.
+	Start time.Duration
+	End   time.Duration
+	// Location is used when recipient timezone is unknown.
+	Location *time.Location
+}
+
+// Until returns end of quiet hours if t is within them in loc, zero time otherwise.
+// Location of the quiet hours is used if loc is nil.
+func (q *QuietHours) Until(t time.Time, loc *time.Location) time.Time {
+	if q == nil || q.Start == q.End {
+		return time.Time{}
+	}
+	if loc == nil {
+		loc = q.Location
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	local := t.In(loc)
+	offset := time.Duration(local.Hour())*time.Hour + time.Duration(local.Minute())*time.Minute
+	endDay := local.Day()
+	switch {
+	case q.Start < q.End && offset >= q.Start && offset < q.End:
+	case q.Start > q.End && offset < q.End:
+	case q.Start > q.End && offset >= q.Start:
+		endDay++
+	default:
+		return time.Time{}
+	}
+
+	return time.Date(local.Year(), local.Month(), endDay, 0, int(q.End/time.Minute), 0, 0, loc).UTC()
+}
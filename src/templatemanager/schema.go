@@ -0,0 +1,93 @@
+package templatemanager
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/santhosh-tekuri/jsonschema/v6/kind"
+	"golang.org/x/text/message"
+
+	"github.com/pralolik/templgrid/pkg"
+)
+
+const parametersField = "email_parameters"
+
+// ParametersError is returned when email parameters don't satisfy the template schema.
+type ParametersError struct {
+	TemplateName string
+	Fields       []pkg.FieldError
+}
+
+func (e *ParametersError) Error() string {
+	paths := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		paths = append(paths, f.Field)
+	}
+	return fmt.Sprintf("invalid %s for template %s: %s", parametersField, e.TemplateName, strings.Join(paths, ", "))
+}
+
+func compileSchema(name, schema string) (*jsonschema.Schema, error) {
+	doc, err := jsonschema.UnmarshalJSON(strings.NewReader(schema))
+	if err != nil {
+		return nil, fmt.Errorf("can't unmarshal schema for %s: %w ", name, err)
+	}
+	url := name + ".schema.json"
+	c := jsonschema.NewCompiler()
+	if err = c.AddResource(url, doc); err != nil {
+		return nil, fmt.Errorf("can't add schema for %s: %w ", name, err)
+	}
+	sch, err := c.Compile(url)
+	if err != nil {
+		return nil, fmt.Errorf("can't compile schema for %s: %w ", name, err)
+	}
+	return sch, nil
+}
+
+func validateSchema(name string, sch *jsonschema.Schema, parameters interface{}) error {
+	err := sch.Validate(parameters)
+	if err == nil {
+		return nil
+	}
+	var vErr *jsonschema.ValidationError
+	if !errors.As(err, &vErr) {
+		return fmt.Errorf("can't validate %s for %s: %w ", parametersField, name, err)
+	}
+
+	p := message.NewPrinter(message.MatchLanguage("en"))
+	pErr := &ParametersError{TemplateName: name}
+	var collect func(e *jsonschema.ValidationError)
+	collect = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) > 0 {
+			for _, c := range e.Causes {
+				collect(c)
+			}
+			return
+		}
+		if req, ok := e.ErrorKind.(*kind.Required); ok {
+			for _, missing := range req.Missing {
+				pErr.Fields = append(pErr.Fields, pkg.FieldError{
+					Field:   fieldPath(e.InstanceLocation, missing),
+					Message: "required",
+				})
+			}
+			return
+		}
+		pErr.Fields = append(pErr.Fields, pkg.FieldError{
+			Field:   fieldPath(e.InstanceLocation),
+			Message: e.ErrorKind.LocalizedString(p),
+		})
+	}
+	collect(vErr)
+
+	return pErr
+}
+
+func fieldPath(location []string, tail ...string) string {
+	path := make([]string, 0, len(location)+len(tail)+1)
+	path = append(path, parametersField)
+	path = append(path, location...)
+	path = append(path, tail...)
+	return strings.Join(path, ".")
+}
@@ -1,27 +1,65 @@
 package templatemanager
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"maps"
 	"strings"
 
+	"github.com/santhosh-tekuri/jsonschema/v6"
+
 	"github.com/pralolik/templgrid/src/resources"
 )
 
 type EmailStorage struct {
 	templates  map[string]*resources.TemplateResource
+	schemas    map[string]*jsonschema.Schema
+	options    map[string]TemplateOptions
+	defOptions TemplateOptions
 	components []string
 	i10n       map[string]map[string]string
+	defLocale  string
 }
 
 func NewEmailStorage() *EmailStorage {
 	return &EmailStorage{
 		templates:  map[string]*resources.TemplateResource{},
+		schemas:    map[string]*jsonschema.Schema{},
+		options:    map[string]TemplateOptions{},
 		components: []string{},
+		defLocale:  DefLocale,
 	}
 }
 
-func (es *EmailStorage) AddEmail(res *resources.TemplateResource) {
+func (es *EmailStorage) AddEmail(res *resources.TemplateResource) error {
+	if res.ParametersSchema != "" {
+		sch, err := compileSchema(res.Name, res.ParametersSchema)
+		if err != nil {
+			return fmt.Errorf("add email error: %w ", err)
+		}
+		es.schemas[res.Name] = sch
+	}
 	es.templates[res.Name] = res
+
+	return nil
+}
+
+// SetDefaultOptions sets options used for templates without own options.
+func (es *EmailStorage) SetDefaultOptions(opts TemplateOptions) {
+	es.defOptions = opts
+}
+
+// AddOptions sets options for the template with given name.
+func (es *EmailStorage) AddOptions(emailName string, opts TemplateOptions) {
+	es.options[emailName] = opts
+}
+
+// SetDefaultLocale sets locale used in strict mode for emails without locale and for keys missing in the email locale.
+func (es *EmailStorage) SetDefaultLocale(locale string) {
+	if locale != "" {
+		es.defLocale = strings.ToLower(locale)
+	}
 }
 
 func (es *EmailStorage) AddComponents(components []string) {
@@ -32,29 +70,54 @@ func (es *EmailStorage) AddI10n(i10n map[string]map[string]string) {
 	es.i10n = i10n
 }
 
+// Health returns error if no email templates are loaded.
+func (es *EmailStorage) Health(_ context.Context) error {
+	if len(es.templates) == 0 {
+		return errors.New("no email templates loaded")
+	}
+
+	return nil
+}
+
 func (es *EmailStorage) HasEmail(emailName string) error {
 	_, err := es.getTemplate(emailName)
 
 	return err
 }
 
+// ValidateParameters checks email parameters against the template schema.
+// Templates without a schema accept any parameters.
+// Returns *ParametersError with field paths in case of violations.
+func (es *EmailStorage) ValidateParameters(emailName string, parameters interface{}) error {
+	if _, err := es.getTemplate(emailName); err != nil {
+		return err
+	}
+	sch, ok := es.schemas[emailName]
+	if !ok {
+		return nil
+	}
+
+	return validateSchema(emailName, sch, parameters)
+}
+
 func (es *EmailStorage) BuildEmail(emailName string, locale string, parameters interface{}) (string, string, error) {
 	template, err := es.getTemplate(emailName)
 	if err != nil {
 		return "", "", fmt.Errorf("build email error: %w ", err)
 	}
 
-	i10n, err := es.getLocale(locale)
+	opts := es.Options(emailName)
+	i10n, err := es.getLocale(locale, opts.Strict)
 	if err != nil {
 		return "", "", fmt.Errorf("no i10n %s found", locale)
 	}
 
-	subjectTmpl, err := createTemplate(template.EmailTemplate, SbjBlck, i10n)
+	subjectTmpl, err := createTemplate(template.EmailTemplate, SbjBlck, i10n, opts)
 	if err != nil {
 		return "", "", fmt.Errorf("build email error: %w ", err)
 	}
 
-	emailTmpl, err := createTemplate(template.EmailTemplate, MnBlck, i10n)
+	emailTmpl, err := createTemplate(template.EmailTemplate, MnBlck, i10n, opts)
 	if err != nil {
 		return "", "", fmt.Errorf("build email error: %w ", err)
 	}
@@ -80,14 +143,36 @@ func (es *EmailStorage) getTemplate(emailName string) (*resources.TemplateResour
 	return nil, fmt.Errorf("no email template with name %s found", emailName)
 }
 
-func (es *EmailStorage) getLocale(locale string) (map[string]string, error) {
+// Options returns options of the email, default options if not configured.
+func (es *EmailStorage) Options(emailName string) TemplateOptions {
+	if opts, ok := es.options[emailName]; ok {
+		return opts
+	}
+
+	return es.defOptions
+}
+
+// getLocale returns translations of the locale. In strict mode keys missing in it are taken
+// from the default locale and emails without locale get the default locale, so only keys unknown
+// to both fail the build. Otherwise missing keys render empty as before.
+func (es *EmailStorage) getLocale(locale string, strict bool) (map[string]string, error) {
+	defParams := es.i10n[es.defLocale]
 	if locale == "" {
-		return map[string]string{}, nil
+		if !strict || defParams == nil {
+			return map[string]string{}, nil
+		}
+		return defParams, nil
 	}
 	locale = strings.ToLower(locale)
-	if localeParams, ok := es.i10n[locale]; ok {
+	localeParams, ok := es.i10n[locale]
+	if !ok {
+		return nil, fmt.Errorf("no locale with name %s found", locale)
+	}
+	if !strict || locale == es.defLocale || len(defParams) == 0 {
 		return localeParams, nil
 	}
+	merged := maps.Clone(defParams)
+	maps.Copy(merged, localeParams)
 
-	return nil, fmt.Errorf("no locale with name %s found", locale)
+	return merged, nil
 }
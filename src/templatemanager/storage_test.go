@@ -0,0 +1,56 @@
+package templatemanager
+
+import (
+	"testing"
+
+	"github.com/pralolik/templgrid/src/resources"
+)
+
+func TestLocaleFallback(t *testing.T) {
+	es := NewEmailStorage()
+	err := es.AddEmail(&resources.TemplateResource{
+		Name:          "welcome",
+		EmailTemplate: `{{define "subject"}}{{__ "subject"}}{{end}}{{define "email"}}<p>{{__ "greeting"}} {{__ "footer"}}</p>{{end}}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	es.AddI10n(map[string]map[string]string{
+		"en": {"subject": "Welcome", "greeting": "Hello", "footer": "Bye"},
+		"de": {"subject": "Willkommen", "greeting": "Hallo"},
+	})
+
+	tests := []struct {
+		name    string
+		strict  bool
+		locale  string
+		subject string
+		email   string
+	}{
+		// missing keys render empty without strict mode.
+		{"empty locale", false, "", "", "<p>"},
+		{"missing key", false, "de", "Willkommen", "<p>Hallo"},
+		{"strict empty locale", true, "", "Welcome", "<p>Hello Bye"},
+		{"strict missing key", true, "DE", "Willkommen", "<p>Hallo Bye"},
+	}
+	for _, tt := range tests {
+		es.SetDefaultOptions(TemplateOptions{Strict: tt.strict})
+		subject, email, err := es.BuildEmail("welcome", tt.locale, map[string]interface{}{})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if subject != tt.subject || email != tt.email {
+			t.Errorf("%s = %q %q, want %q %q", tt.name, subject, email, tt.subject, tt.email)
+		}
+	}
+
+	// keys unknown to both locales fail strict builds.
+	es.AddI10n(map[string]map[string]string{"en": {"subject": "Welcome"}, "de": {}})
+	es.SetDefaultOptions(TemplateOptions{Strict: true})
+	if _, _, err = es.BuildEmail("welcome", "de", map[string]interface{}{}); err == nil {
+		t.Error("strict build with unknown key succeeded")
+	}
+	if _, _, err = es.BuildEmail("welcome", "fr", map[string]interface{}{}); err == nil {
+		t.Error("build of unknown locale succeeded")
+	}
+}
@@ -0,0 +1,115 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.43.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/pralolik/templgrid/pkg"
+)
+
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+
+	serviceName = "templgrid"
+	tracerName  = "github.com/pralolik/templgrid"
+)
+
+// Attribute keys of templgrid spans.
+const (
+	MessageIDKey    = attribute.Key("templgrid.message.id")
+	TemplateNameKey = attribute.Key("templgrid.template.name")
+)
+
+type Config struct {
+	// Exporter is one of none, stdout, otlp.
+	Exporter string
+	// Endpoint is OTLP gRPC collector address, OTEL_EXPORTER_OTLP_* env is used if empty.
+	Endpoint string
+	Insecure bool
+	// SampleRatio of new traces, parent decision is respected.
+	SampleRatio float64
+}
+
+// Setup installs global tracer provider and W3C trace context propagator.
+// Returned shutdown flushes pending spans.
+func Setup(ctx context.Context, cfg Config) (func(ctx context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.Exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterOTLP:
+		options := []otlptracegrpc.Option{}
+		if cfg.Endpoint != "" {
+			options = append(options, otlptracegrpc.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			options = append(options, otlptracegrpc.WithInsecure())
+		}
+		exporter, err = otlptracegrpc.New(ctx, options...)
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %s", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't create %s exporter: %w ", cfg.Exporter, err)
+	}
+
+	ratio := cfg.SampleRatio
+	if ratio <= 0 {
+		ratio = 1
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// Tracer returns templgrid tracer of the global provider.
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// Inject stores trace context of ctx in the email, so it survives the queue hop.
+// Previous value is replaced.
+func Inject(ctx context.Context, email *pkg.TemplgridEmailEntity) {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		email.TraceContext = nil
+		return
+	}
+	email.TraceContext = carrier
+}
+
+// Extract returns ctx with trace context stored in the email.
+func Extract(ctx context.Context, email *pkg.TemplgridEmailEntity) context.Context {
+	if len(email.TraceContext) == 0 {
+		return ctx
+	}
+
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(email.TraceContext))
+}
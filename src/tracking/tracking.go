@@ -0,0 +1,198 @@
+package tracking
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	// ClickPath redirects to the original link and records click event.
+	ClickPath = "/t/click"
+	// OpenPath serves the open pixel and records open event.
+	OpenPath = "/t/open"
+	// TokenParam is the query parameter holding signed token.
+	TokenParam = "token"
+	// NoTrackAttr excludes the link from rewriting, like <a href="..." data-templgrid-notrack>.
+	NoTrackAttr = "data-templgrid-notrack"
+
+	// Event types recorded in message status.
+	EventOpen  = "open"
+	EventClick = "click"
+)
+
+// ErrInvalidToken is returned for tokens not signed by the secret.
+var ErrInvalidToken = errors.New("invalid tracking token")
+
+// domains separate signatures of click and open tokens.
+var (
+	clickDomain = []byte("click\n")
+	openDomain  = []byte("open\n")
+)
+
+// Tracker rewrites links of rendered emails to signed redirects and adds open pixel.
+type Tracker struct {
+	secret  []byte
+	baseURL string
+	exclude []string
+}
+
+// NewTracker creates tracker of links to baseURL, like https://mail.example.com.
+// Links starting with exclude prefixes and links to baseURL itself are never rewritten.
+func NewTracker(secret, baseURL string, exclude []string) *Tracker {
+	return &Tracker{secret: []byte(secret), baseURL: strings.TrimSuffix(baseURL, "/"), exclude: exclude}
+}
+
+// Rewrite returns html with http links replaced by click redirects of the message
+// and open pixel appended to the body. Only absolute http and https links are rewritten,
+// so unsubscribe and preference tags filled by the sender are kept.
+func (t *Tracker) Rewrite(content, messageID string, clicks, opens bool) (string, error) {
+	if !clicks && !opens {
+		return content, nil
+	}
+	var out bytes.Buffer
+	out.Grow(len(content))
+	pixel := opens
+	z := html.NewTokenizer(strings.NewReader(content))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if errors.Is(z.Err(), io.EOF) {
+				break
+			}
+			return "", fmt.Errorf("can't parse email html: %w ", z.Err())
+		}
+		raw := z.Raw()
+		token := z.Token()
+		switch {
+		case clicks && (tt == html.StartTagToken || tt == html.SelfClosingTagToken) && token.Data == "a":
+			if t.rewriteLink(&token, messageID) {
+				out.WriteString(token.String())
+				continue
+			}
+		case pixel && tt == html.EndTagToken && (token.Data == "body" || token.Data == "html"):
+			out.WriteString(t.pixel(messageID))
+			pixel = false
+		}
+		out.Write(raw)
+	}
+	if pixel {
+		// minified html may have no closing body tag.
+		out.WriteString(t.pixel(messageID))
+	}
+
+	return out.String(), nil
+}
+
+func (t *Tracker) rewriteLink(token *html.Token, messageID string) bool {
+	hrefIdx := -1
+	for i, attr := range token.Attr {
+		switch attr.Key {
+		case NoTrackAttr:
+			return false
+		case "href":
+			hrefIdx = i
+		}
+	}
+	if hrefIdx < 0 || !t.trackable(token.Attr[hrefIdx].Val) {
+		return false
+	}
+	token.Attr[hrefIdx].Val = t.ClickURL(messageID, token.Attr[hrefIdx].Val)
+
+	return true
+}
+
+func (t *Tracker) trackable(link string) bool {
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return false
+	}
+	if t.baseURL != "" && strings.HasPrefix(link, t.baseURL) {
+		return false
+	}
+	for _, prefix := range t.exclude {
+		if strings.HasPrefix(link, prefix) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (t *Tracker) pixel(messageID string) string {
+	return `<img src="` + html.EscapeString(t.OpenURL(messageID)) + `" width="1" height="1" alt="" style="border:0">`
+}
+
+// ClickURL returns signed redirect to the link recording click of the message.
+func (t *Tracker) ClickURL(messageID, link string) string {
+	return t.baseURL + ClickPath + "?" + TokenParam + "=" + t.token([]byte(messageID+"\n"+link), clickDomain)
+}
+
+// OpenURL returns signed pixel URL recording open of the message.
+func (t *Tracker) OpenURL(messageID string) string {
+	return t.baseURL + OpenPath + "?" + TokenParam + "=" + t.token([]byte(messageID), openDomain)
+}
+
+// VerifyClick returns message id and original link of the click token.
+func (t *Tracker) VerifyClick(token string) (string, string, error) {
+	payload, err := t.verify(token, clickDomain)
+	if err != nil {
+		return "", "", err
+	}
+	messageID, link, ok := strings.Cut(string(payload), "\n")
+	if !ok || messageID == "" || link == "" {
+		return "", "", ErrInvalidToken
+	}
+
+	return messageID, link, nil
+}
+
+// VerifyOpen returns message id of the open token.
+func (t *Tracker) VerifyOpen(token string) (string, error) {
+	payload, err := t.verify(token, openDomain)
+	if err != nil {
+		return "", err
+	}
+	if len(payload) == 0 {
+		return "", ErrInvalidToken
+	}
+
+	return string(payload), nil
+}
+
+func (t *Tracker) token(payload, domain []byte) string {
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(t.sign(payload, domain))
+}
+
+func (t *Tracker) verify(token string, domain []byte) ([]byte, error) {
+	encPayload, encMAC, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encPayload)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(encMAC)
+	if err != nil || !hmac.Equal(mac, t.sign(payload, domain)) {
+		return nil, ErrInvalidToken
+	}
+
+	return payload, nil
+}
+
+func (t *Tracker) sign(payload, domain []byte) []byte {
+	mac := hmac.New(sha256.New, t.secret)
+	mac.Write(domain)
+	mac.Write(payload)
+
+	return mac.Sum(nil)
+}
@@ -0,0 +1,174 @@
+package tracking
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const baseURL = "https://mail.example.com"
+
+func tokenOf(t *testing.T, rawURL string) string {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return u.Query().Get(TokenParam)
+}
+
+func TestRewriteLinks(t *testing.T) {
+	tracker := NewTracker("secret", baseURL, []string{"https://docs.example.com/"})
+	tests := []struct {
+		name    string
+		link    string
+		tracked bool
+	}{
+		{name: "http", link: "http://shop.example.com/sale", tracked: true},
+		{name: "https with query", link: "https://shop.example.com/?a=1&b=2", tracked: true},
+		{name: "excluded prefix", link: "https://docs.example.com/guide"},
+		{name: "base url", link: baseURL + "/unsubscribe?token=x"},
+		{name: "mailto", link: "mailto:support@example.com"},
+		{name: "tel", link: "tel:+4930123"},
+		{name: "relative", link: "/account"},
+		{name: "template tag", link: "-templgrid-unsubscribe-url=-"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := `<p><a href="` + tt.link + `">link</a></p>`
+			got, err := tracker.Rewrite(content, "m1", true, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.tracked {
+				if got != content {
+					t.Errorf("link is rewritten: %s", got)
+				}
+				return
+			}
+			prefix := `<p><a href="` + baseURL + ClickPath + "?" + TokenParam + "="
+			if !strings.HasPrefix(got, prefix) {
+				t.Fatalf("link is not rewritten to click redirect: %s", got)
+			}
+			token := strings.TrimSuffix(strings.TrimPrefix(got, prefix), `">link</a></p>`)
+			messageID, link, err := tracker.VerifyClick(token)
+			if err != nil || messageID != "m1" || link != tt.link {
+				t.Errorf("click token = %q %q %v, want m1 %q", messageID, link, err, tt.link)
+			}
+		})
+	}
+}
+
+func TestRewriteNoTrack(t *testing.T) {
+	tracker := NewTracker("secret", baseURL, nil)
+	content := `<a href="https://shop.example.com" data-templgrid-notrack>shop</a>` +
+		`<a data-templgrid-notrack="" href="https://shop.example.com/b">b</a>`
+	got, err := tracker.Rewrite(content, "m1", true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != content {
+		t.Errorf("links with %s are rewritten: %s", NoTrackAttr, got)
+	}
+}
+
+func TestRewritePixel(t *testing.T) {
+	tracker := NewTracker("secret", baseURL, nil)
+	pixel := tracker.pixel("m1")
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "before closing body",
+			content: `<html><body><p>hi</p></body></html>`,
+			want:    `<html><body><p>hi</p>` + pixel + `</body></html>`,
+		},
+		{
+			name:    "before closing html without body",
+			content: `<html><p>hi</p></html>`,
+			want:    `<html><p>hi</p>` + pixel + `</html>`,
+		},
+		{
+			name:    "appended to minified html",
+			content: `<p>hi`,
+			want:    `<p>hi` + pixel,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tracker.Rewrite(tt.content, "m1", false, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+			if strings.Count(got, OpenPath) != 1 {
+				t.Errorf("got %d pixels, want 1", strings.Count(got, OpenPath))
+			}
+		})
+	}
+
+	content := `<body><a href="https://shop.example.com">shop</a></body>`
+	got, err := tracker.Rewrite(content, "m1", false, false)
+	if err != nil || got != content {
+		t.Errorf("disabled tracking changed content: %s %v", got, err)
+	}
+}
+
+func TestVerifyTokens(t *testing.T) {
+	tracker := NewTracker("secret", baseURL, nil)
+	clickToken := tokenOf(t, tracker.ClickURL("m1", "https://shop.example.com"))
+	openToken := tokenOf(t, tracker.OpenURL("m1"))
+
+	if messageID, err := tracker.VerifyOpen(openToken); err != nil || messageID != "m1" {
+		t.Errorf("open token = %q %v, want m1", messageID, err)
+	}
+	if messageID, link, err := tracker.VerifyClick(clickToken); err != nil || messageID != "m1" || link != "https://shop.example.com" {
+		t.Errorf("click token = %q %q %v", messageID, link, err)
+	}
+
+	payload, mac, _ := strings.Cut(clickToken, ".")
+	forged := tokenOf(t, tracker.ClickURL("m1", "https://evil.example.com"))
+	forgedPayload, _, _ := strings.Cut(forged, ".")
+	other := NewTracker("other", baseURL, nil)
+	invalid := map[string]string{
+		"tampered link":     forgedPayload + "." + mac,
+		"tampered mac":      payload + "." + mac[:len(mac)-2] + "AA",
+		"no mac":            payload,
+		"not base64":        "!!." + mac,
+		"empty":             "",
+		"another secret":    tokenOf(t, other.ClickURL("m1", "https://shop.example.com")),
+		"open token":        openToken,
+		"open of other msg": tokenOf(t, tracker.OpenURL("m1\nhttps://shop.example.com")),
+	}
+	for name, token := range invalid {
+		if _, _, err := tracker.VerifyClick(token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("click with %s: error = %v, want ErrInvalidToken", name, err)
+		}
+	}
+
+	openPayload, openMAC, _ := strings.Cut(openToken, ".")
+	invalid = map[string]string{
+		"tampered message": tokenPayload("m2") + "." + openMAC,
+		"tampered mac":     openPayload + "." + openMAC[:len(openMAC)-2] + "AA",
+		"another secret":   tokenOf(t, other.OpenURL("m1")),
+		"click token":      clickToken,
+	}
+	for name, token := range invalid {
+		if _, err := tracker.VerifyOpen(token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("open with %s: error = %v, want ErrInvalidToken", name, err)
+		}
+	}
+}
+
+func tokenPayload(payload string) string {
+	token := NewTracker("", "", nil).token([]byte(payload), openDomain)
+	encoded, _, _ := strings.Cut(token, ".")
+
+	return encoded
+}
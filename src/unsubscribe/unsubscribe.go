@@ -0,0 +1,120 @@
+package unsubscribe
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+)
+
+const (
+	// Path is the public endpoint of unsubscribe links.
+	Path = "/unsubscribe"
+	// PreferencesPath is the public preference page of the recipient.
+	PreferencesPath = "/preferences"
+	// TokenParam is the query parameter holding signed token.
+	TokenParam = "token"
+
+	// OneClickHeader and OneClickValue are RFC 8058 List-Unsubscribe-Post header.
+	OneClickHeader = "List-Unsubscribe-Post"
+	OneClickValue  = "List-Unsubscribe=One-Click"
+	ListHeader     = "List-Unsubscribe"
+)
+
+// ErrInvalidToken is returned for tokens not signed by the secret.
+var ErrInvalidToken = errors.New("invalid unsubscribe token")
+
+// preferencesDomain prefixes signed preference payloads, payloads starting with NUL are rejected.
+var preferencesDomain = []byte("\x00preferences\n")
+
+// Signer creates and verifies unsubscribe links of the recipient and template category.
+// Links don't expire, so recipients can opt out from old emails.
+type Signer struct {
+	secret  []byte
+	baseURL string
+}
+
+// NewSigner creates signer of links to Path of baseURL, like https://mail.example.com.
+func NewSigner(secret, baseURL string) *Signer {
+	return &Signer{secret: []byte(secret), baseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// URL returns signed unsubscribe link, empty category unsubscribes from all emails.
+func (s *Signer) URL(address, category string) string {
+	return s.baseURL + Path + "?" + TokenParam + "=" + url.QueryEscape(s.Token(address, category))
+}
+
+// Token returns base64 address and category signed by HMAC-SHA256.
+func (s *Signer) Token(address, category string) string {
+	payload := []byte(address + "\n" + category)
+
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(s.sign(payload, nil))
+}
+
+// Verify returns address and category of the token.
+func (s *Signer) Verify(token string) (string, string, error) {
+	payload, err := s.verify(token, nil)
+	if err != nil {
+		return "", "", err
+	}
+	address, category, ok := strings.Cut(string(payload), "\n")
+	if !ok || address == "" {
+		return "", "", ErrInvalidToken
+	}
+
+	return address, category, nil
+}
+
+// PreferencesURL returns signed link of the preference page of the address.
+func (s *Signer) PreferencesURL(address string) string {
+	return s.baseURL + PreferencesPath + "?" + TokenParam + "=" + url.QueryEscape(s.PreferencesToken(address))
+}
+
+// PreferencesToken returns base64 address signed separately from unsubscribe tokens,
+// so preference links can't be used to unsubscribe from all emails.
+func (s *Signer) PreferencesToken(address string) string {
+	payload := []byte(address)
+
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(s.sign(payload, preferencesDomain))
+}
+
+// VerifyPreferences returns address of the preference token.
+func (s *Signer) VerifyPreferences(token string) (string, error) {
+	payload, err := s.verify(token, preferencesDomain)
+	if err != nil {
+		return "", err
+	}
+	if len(payload) == 0 {
+		return "", ErrInvalidToken
+	}
+
+	return string(payload), nil
+}
+
+func (s *Signer) verify(token string, domain []byte) ([]byte, error) {
+	encPayload, encMAC, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encPayload)
+	if err != nil || bytes.HasPrefix(payload, []byte{0}) {
+		return nil, ErrInvalidToken
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(encMAC)
+	if err != nil || !hmac.Equal(mac, s.sign(payload, domain)) {
+		return nil, ErrInvalidToken
+	}
+
+	return payload, nil
+}
+
+func (s *Signer) sign(payload, domain []byte) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write(domain)
+	mac.Write(payload)
+
+	return mac.Sum(nil)
+}